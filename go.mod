@@ -9,7 +9,9 @@ require (
 	github.com/MatthiasKunnen/systemctl v1.0.0
 	github.com/a-h/templ v0.3.857
 	github.com/go-json-experiment/json v0.0.0-20250517221953-25912455fbc8
+	github.com/klauspost/compress v1.18.0
 	github.com/spf13/cobra v1.9.1
+	go.etcd.io/bbolt v1.4.3
 )
 
 require (
@@ -0,0 +1,108 @@
+package flipcam
+
+import (
+	"errors"
+	"fmt"
+	"github.com/MatthiasKunnen/flipcam/pkg/flipcamlib/storage"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+	"os"
+	"path"
+)
+
+var backupFormat string
+var backupOutput string
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Backs up the metadata of flipcam",
+	Long: `Writes a copy of the metadata database, containing clips, annotations, comments, the roster,
+and settings, to a file. The json format exports the contents as JSON instead.
+
+The database can't be opened while flipcam runs. Download the backup from /api/backup of the web
+UI instead.`,
+	Example: `flipcam backup --output flipcam.db`,
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		_, err := os.Stat(databasePath)
+		if err != nil {
+			log.Fatalf("No database to back up: %v", err)
+		}
+
+		db, err := storage.Open(databasePath)
+		if errors.Is(err, storage.ErrLocked) {
+			log.Fatalf("%v. Download the backup from /api/backup of the web UI instead.", err)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer db.Close()
+
+		var write func(w io.Writer) error
+		switch backupFormat {
+		case "db":
+			write = db.Backup
+		case "json":
+			write = db.Export
+		default:
+			log.Fatalf("Unknown format %q, must be db or json", backupFormat)
+		}
+
+		if backupOutput == "-" {
+			err = write(os.Stdout)
+		} else {
+			err = writeBackup(backupOutput, write)
+		}
+		if err != nil {
+			log.Fatalf("Failed to write backup: %v", err)
+		}
+	},
+}
+
+// writeBackup writes the backup to a temporary file and moves it into place once it is on
+// disk, so that output is never a partial backup.
+func writeBackup(output string, write func(w io.Writer) error) error {
+	tmp, err := os.CreateTemp(path.Dir(output), "."+path.Base(output)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	err = write(tmp)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", tmp.Name(), err)
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), output)
+}
+
+func init() {
+	addDatabaseFlag(backupCmd, &databasePath)
+	backupCmd.Flags().StringVar(
+		&backupFormat,
+		"format",
+		"db",
+		"Sets the format of the backup, db or json.",
+	)
+	backupCmd.Flags().StringVarP(
+		&backupOutput,
+		"output",
+		"o",
+		"",
+		"Sets the file to write the backup to, - for stdout.",
+	)
+	err := backupCmd.MarkFlagRequired("output")
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -2,6 +2,7 @@ package flipcam
 
 import (
 	_ "embed"
+	"fmt"
 	"github.com/MatthiasKunnen/flipcam/pkg/flipcamlib"
 	"github.com/spf13/cobra"
 	"log"
@@ -21,9 +22,12 @@ var genConfCmd = &cobra.Command{
 	Args:    cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
 		flipcam := flipcamlib.New(flipcamlib.Opts{
+			Cameras:           cameras,
 			HlsOutputDir:      hlsOutputDir,
 			HlsUrlPathPrefix:  hlsUrlPathPrefix,
 			RouterAddr:        routerIp.Prefix(),
+			SntpAddr:          sntpAddr,
+			StreamKeys:        streamKeys.ByCamera(cameras),
 			UiPort:            uiPort,
 			WirelessInterface: wirelessInterface,
 		})
@@ -78,15 +82,23 @@ var genConfCmd = &cobra.Command{
 		if err != nil {
 			log.Fatalf("failed to close %s file: %v", generatedVarsFilename, err)
 		}
+
+		fmt.Println("RTMP URLs to enter in the cameras, e.g. in Quik or a GoPro Labs QR code:")
+		for _, publishUrl := range flipcam.PublishUrls() {
+			fmt.Printf("  %s: %s\n", publishUrl.Camera, publishUrl.Url)
+		}
 	},
 }
 
 func init() {
+	addCamerasFlag(genConfCmd, &cameras)
 	addHlsOutputDirFlag(genConfCmd, &hlsOutputDir)
 	addHlsUrlPathPrefixFlag(genConfCmd, &hlsUrlPathPrefix)
 	addHostnameFlag(genConfCmd, &hostname)
 	addInterfaceFlag(genConfCmd, &wirelessInterface)
 	addIpv4Flag(genConfCmd, &routerIp)
+	addSntpAddrFlag(genConfCmd, &sntpAddr)
+	addStreamKeysFlag(genConfCmd, &streamKeys)
 	addUiPortFlag(genConfCmd, &hlsUrlPathPrefix)
 	addWpaPassphraseFlag(genConfCmd, &wpaPassphrase)
 	genConfCmd.Flags().StringVar(
@@ -12,9 +12,20 @@ import (
 	"time"
 )
 
+var cameras []string
+var staticDir string
+var coachPin string
+var databasePath string
+var streamKeys streamKeysFlag
 var hlsOutputDir string
 var hlsUrlPathPrefix string
+var overlayFontFile string
+var sntpAddr string
+var serveHls bool
 var routerIp = ipv4Flag(netip.MustParsePrefix("192.168.23.1/24"))
+var tlsDir string
+var thumbnailInterval = thumbnailIntervalFlag(5 * time.Second)
+var transcodePolicy = transcodePolicyFlag(flipcamlib.TranscodeAuto)
 var uiPort string
 var wirelessInterface string
 
@@ -27,9 +38,21 @@ var runCmd = &cobra.Command{
 		stopSig := make(chan os.Signal, 1)
 		signal.Notify(stopSig, os.Interrupt, syscall.SIGTERM)
 		flipcam := flipcamlib.New(flipcamlib.Opts{
+			Cameras:           cameras,
+			CoachPin:          coachPin,
+			DatabasePath:      databasePath,
 			HlsOutputDir:      hlsOutputDir,
 			HlsUrlPathPrefix:  hlsUrlPathPrefix,
+			Hostname:          hostname,
+			OverlayFontFile:   overlayFontFile,
 			RouterAddr:        routerIp.Prefix(),
+			ServeHls:          serveHls,
+			SntpAddr:          sntpAddr,
+			StaticDir:         staticDir,
+			StreamKeys:        streamKeys.ByCamera(cameras),
+			ThumbnailInterval: thumbnailInterval.Duration(),
+			TlsDir:            tlsDir,
+			TranscodePolicy:   transcodePolicy.Policy(),
 			UiPort:            uiPort,
 			WirelessInterface: wirelessInterface,
 		})
@@ -61,9 +84,42 @@ var runCmd = &cobra.Command{
 }
 
 func init() {
+	addCamerasFlag(runCmd, &cameras)
+	addCoachPinFlag(runCmd, &coachPin)
+	addDatabaseFlag(runCmd, &databasePath)
 	addHlsOutputDirFlag(runCmd, &hlsOutputDir)
 	addHlsUrlPathPrefixFlag(runCmd, &hlsUrlPathPrefix)
+	addHostnameFlag(runCmd, &hostname)
 	addInterfaceFlag(runCmd, &wirelessInterface)
 	addIpv4Flag(runCmd, &routerIp)
+	addSntpAddrFlag(runCmd, &sntpAddr)
+	addStaticDirFlag(runCmd, &staticDir)
+	addStreamKeysFlag(runCmd, &streamKeys)
+	addTranscodePolicyFlag(runCmd, &transcodePolicy)
 	addUiPortFlag(runCmd, &uiPort)
+	runCmd.Flags().BoolVar(
+		&serveHls,
+		"serve-hls",
+		false,
+		"Serves the video from the UI port instead of through Caddy. The Caddy service is not "+
+			"started, for simple deployments and development.",
+	)
+	runCmd.Flags().StringVar(
+		&tlsDir,
+		"tls-dir",
+		"",
+		"Serves the UI over HTTPS using a local CA that is generated in, and reused from, this "+
+			"directory. Devices trust it by installing /ca.crt. Requires --serve-hls.",
+	)
+	runCmd.Flags().Var(
+		&thumbnailInterval,
+		"thumbnail-interval",
+		"Sets the time between the thumbnails shown when scrubbing the timeline.",
+	)
+	runCmd.Flags().StringVar(
+		&overlayFontFile,
+		"overlay-font",
+		"",
+		"Sets the font file used for the timestamp overlay. Uses the fontconfig default if empty.",
+	)
 }
@@ -1,6 +1,7 @@
 package flipcam
 
 import (
+	"github.com/MatthiasKunnen/flipcam/pkg/flipcamlib"
 	"github.com/spf13/cobra"
 	"log"
 	"net"
@@ -9,6 +10,30 @@ import (
 	"strings"
 )
 
+func addCoachPinFlag(cmd *cobra.Command, stringVar *string) {
+	cmd.Flags().StringVar(
+		stringVar,
+		"coach-pin",
+		"",
+		"Sets the PIN that unlocks the coach role, at least 4 digits. If empty, a PIN is "+
+			"generated and logged on start.",
+	)
+}
+
+func addDatabaseFlag(cmd *cobra.Command, stringVar *string) {
+	defaultPath, err := flipcamlib.DefaultDatabasePath()
+	if err != nil {
+		defaultPath = ""
+	}
+	cmd.Flags().StringVar(
+		stringVar,
+		"database",
+		defaultPath,
+		"Sets the file in which clips, annotations, comments, the roster, and settings are "+
+			"stored. Must not be in the HLS output directory.",
+	)
+}
+
 func addHlsOutputDirFlag(cmd *cobra.Command, stringVar *string) {
 	cmd.Flags().StringVar(
 		stringVar,
@@ -85,6 +110,58 @@ func addIpv4Flag(cmd *cobra.Command, v *ipv4Flag) {
 	)
 }
 
+func addCamerasFlag(cmd *cobra.Command, v *[]string) {
+	cmd.Flags().StringSliceVar(
+		v,
+		"camera",
+		[]string{flipcamlib.DefaultCameraName},
+		"Sets the names of the cameras, repeat or separate by commas for multiple cameras. "+
+			"Every camera publishes to RTMP port 1935 with its stream key or, without keys, "+
+			"its name as stream name.",
+	)
+}
+
+func addSntpAddrFlag(cmd *cobra.Command, stringVar *string) {
+	cmd.Flags().StringVar(
+		stringVar,
+		"sntp-addr",
+		"",
+		"Sets the UDP address of the SNTP server that DHCP clients sync their clock with, e.g. "+
+			":123. Disabled if empty.",
+	)
+}
+
+func addStreamKeysFlag(cmd *cobra.Command, v *streamKeysFlag) {
+	cmd.Flags().Var(
+		v,
+		"stream-key",
+		"Sets a stream key that a camera may publish with, repeat or separate by commas for "+
+			"multiple keys. The key selects the camera, keys without a camera belong "+
+			"to the first camera. The first key of a camera is used in the printed RTMP URLs. "+
+			"Any publisher is accepted if no key is set, otherwise every camera needs a key.",
+	)
+}
+
+func addStaticDirFlag(cmd *cobra.Command, stringVar *string) {
+	cmd.Flags().StringVar(
+		stringVar,
+		"static-dir",
+		"",
+		"Sets the directory from which the files of the UI are served instead of the files "+
+			"embedded in the binary. Useful during development, e.g. ./static. Only the .css "+
+			"and .mjs files in the directory itself are served.",
+	)
+}
+
+func addTranscodePolicyFlag(cmd *cobra.Command, v *transcodePolicyFlag) {
+	cmd.Flags().Var(
+		v,
+		"transcode",
+		"Sets when the video is re-encoded to H.264. auto only transcodes codecs that not every "+
+			"client can play, such as HEVC.",
+	)
+}
+
 func addUiPortFlag(cmd *cobra.Command, stringVar *string) {
 	cmd.Flags().StringVar(
 		stringVar,
@@ -0,0 +1,54 @@
+package flipcam
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/MatthiasKunnen/flipcam/pkg/flipcamlib"
+)
+
+// streamKeysFlag holds the stream keys in the [camera=]key format, see ByCamera.
+type streamKeysFlag []string
+
+// String is used both by fmt.Print and by Cobra in help text
+func (f *streamKeysFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+// Set must have pointer receiver so it doesn't change the value of a copy
+func (f *streamKeysFlag) Set(v string) error {
+	entries := strings.Split(v, ",")
+	for _, entry := range entries {
+		camera, key, found := strings.Cut(entry, "=")
+		if entry == "" || (found && (camera == "" || key == "")) {
+			return errors.New("expected key or camera=key")
+		}
+	}
+
+	*f = append(*f, entries...)
+	return nil
+}
+
+// Type is only used in help text
+func (f *streamKeysFlag) Type() string {
+	return "[camera=]key"
+}
+
+// ByCamera returns the keys per camera name. Keys without a camera belong to the first camera.
+func (f *streamKeysFlag) ByCamera(cameras []string) map[string][]string {
+	firstCamera := flipcamlib.DefaultCameraName
+	if len(cameras) > 0 {
+		firstCamera = cameras[0]
+	}
+
+	keys := make(map[string][]string)
+	for _, entry := range *f {
+		camera, key, found := strings.Cut(entry, "=")
+		if !found {
+			camera, key = firstCamera, entry
+		}
+		keys[camera] = append(keys[camera], key)
+	}
+
+	return keys
+}
@@ -0,0 +1,38 @@
+package flipcam
+
+import (
+	"time"
+
+	"github.com/MatthiasKunnen/flipcam/pkg/flipcamlib"
+)
+
+type thumbnailIntervalFlag time.Duration
+
+// String is used both by fmt.Print and by Cobra in help text
+func (f *thumbnailIntervalFlag) String() string {
+	return time.Duration(*f).String()
+}
+
+// Set must have pointer receiver so it doesn't change the value of a copy
+func (f *thumbnailIntervalFlag) Set(v string) error {
+	interval, err := time.ParseDuration(v)
+	if err != nil {
+		return err
+	}
+	err = flipcamlib.ValidateThumbnailInterval(interval)
+	if err != nil {
+		return err
+	}
+
+	*f = thumbnailIntervalFlag(interval)
+	return nil
+}
+
+// Type is only used in help text
+func (f *thumbnailIntervalFlag) Type() string {
+	return "duration"
+}
+
+func (f *thumbnailIntervalFlag) Duration() time.Duration {
+	return time.Duration(*f)
+}
@@ -0,0 +1,32 @@
+package flipcam
+
+import (
+	"github.com/MatthiasKunnen/flipcam/pkg/flipcamlib"
+)
+
+type transcodePolicyFlag flipcamlib.TranscodePolicy
+
+// String is used both by fmt.Print and by Cobra in help text
+func (f *transcodePolicyFlag) String() string {
+	return string(*f)
+}
+
+// Set must have pointer receiver so it doesn't change the value of a copy
+func (f *transcodePolicyFlag) Set(v string) error {
+	policy, err := flipcamlib.ParseTranscodePolicy(v)
+	if err != nil {
+		return err
+	}
+
+	*f = transcodePolicyFlag(policy)
+	return nil
+}
+
+// Type is only used in help text
+func (f *transcodePolicyFlag) Type() string {
+	return "auto|always|never"
+}
+
+func (f *transcodePolicyFlag) Policy() flipcamlib.TranscodePolicy {
+	return flipcamlib.TranscodePolicy(*f)
+}
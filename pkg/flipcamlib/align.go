@@ -0,0 +1,134 @@
+package flipcamlib
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/MatthiasKunnen/flipcam/pkg/flipcamlib/storage"
+)
+
+// AlignedCamera is the position in the recording of a camera that shows a wall-clock instant.
+type AlignedCamera struct {
+	Camera string `json:"camera"`
+
+	// SessionId and PlaylistPath are empty if the camera has no session that started before
+	// the instant.
+	SessionId    string `json:"sessionId,omitempty"`
+	PlaylistPath string `json:"playlistPath,omitempty"`
+
+	// MediaTime is the currentTime, in seconds, of a video element playing the playlist at
+	// which the instant is shown. Null if the session has no video of the instant, e.g. because
+	// it is not recorded yet.
+	MediaTime *float64 `json:"mediaTime"`
+}
+
+// Alignment maps a wall-clock instant to the recording of every camera.
+type Alignment struct {
+	// Time is the instant as a Unix time in milliseconds.
+	Time    int64           `json:"time"`
+	Cameras []AlignedCamera `json:"cameras"`
+}
+
+// sessionAt returns the session of the camera that was being recorded at t, which is the last
+// session that started before t.
+// Sessions recorded before cameras were named belong to the first camera.
+func (f *FlipCam) sessionAt(c *camera, t time.Time) (Session, bool, error) {
+	var records []SessionRecord
+	err := f.store.View(func(tx storage.Tx) error {
+		var err error
+		records, err = storage.List[SessionRecord](tx, storage.BucketSessions, "")
+		return err
+	})
+	if err != nil {
+		return Session{}, false, err
+	}
+
+	var found *SessionRecord
+	for i, record := range records {
+		camera := record.Camera
+		if camera == "" {
+			camera = f.cameras[0].name
+		}
+		if camera != c.name || record.StartedAt.After(t) {
+			continue
+		}
+		if found == nil || record.StartedAt.After(found.StartedAt) {
+			found = &records[i]
+		}
+	}
+	if found == nil {
+		return Session{}, false, nil
+	}
+
+	session, err := f.getSession(found.Id)
+	switch {
+	case errors.Is(err, ErrSessionNotFound):
+		return Session{}, false, nil
+	case err != nil:
+		return Session{}, false, err
+	}
+
+	return session, true, nil
+}
+
+// align returns the position in the recording of every camera that shows t. The cameras are
+// recorded by separate muxers, so it is the program date time, not the media time, that relates
+// their playlists.
+func (f *FlipCam) align(t time.Time) (Alignment, error) {
+	alignment := Alignment{
+		Time:    t.UnixMilli(),
+		Cameras: make([]AlignedCamera, len(f.cameras)),
+	}
+	for i, c := range f.cameras {
+		aligned := &alignment.Cameras[i]
+		aligned.Camera = c.name
+
+		session, found, err := f.sessionAt(c, t)
+		if err != nil {
+			return Alignment{}, err
+		}
+		if !found {
+			continue
+		}
+		playlist, err := session.readPlaylist()
+		if err != nil {
+			return Alignment{}, err
+		}
+
+		aligned.SessionId = session.Id
+		aligned.PlaylistPath = c.urlPathPrefix + "/" + session.Id + ".m3u8"
+		if mediaTime, ok := playlist.MediaTimeAt(t); ok {
+			seconds := mediaTime.Seconds()
+			aligned.MediaTime = &seconds
+		}
+	}
+
+	return alignment, nil
+}
+
+// handleAlign returns the alignment of the instant given by the t query parameter, a Unix time
+// in milliseconds, or of the current time if it is absent.
+func (f *FlipCam) handleAlign(w http.ResponseWriter, r *http.Request) {
+	t := time.Now()
+	if tStr := r.URL.Query().Get("t"); tStr != "" {
+		ms, err := strconv.ParseInt(tStr, 10, 64)
+		if err != nil {
+			http.Error(w, "t must be a Unix time in milliseconds", http.StatusBadRequest)
+			return
+		}
+		t = time.UnixMilli(ms)
+	}
+
+	alignment, err := f.align(t)
+	if err != nil {
+		log.Printf("web: align: %v\n", err)
+		http.Error(w, "failed to align sessions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJson(w, alignment)
+}
@@ -0,0 +1,230 @@
+package flipcamlib
+
+import (
+	"cmp"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/MatthiasKunnen/flipcam/pkg/flipcamlib/storage"
+	"github.com/go-json-experiment/json"
+)
+
+// AnnotationKind is the shape of an annotation.
+type AnnotationKind string
+
+const (
+	AnnotationAngle    AnnotationKind = "angle"
+	AnnotationCircle   AnnotationKind = "circle"
+	AnnotationFreehand AnnotationKind = "freehand"
+	AnnotationLine     AnnotationKind = "line"
+	AnnotationText     AnnotationKind = "text"
+)
+
+// AnnotationPoint is a position in the video as a fraction of its width and height.
+// 0, 0 is the top left corner.
+type AnnotationPoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Annotation is a drawing on top of the video of a session, e.g. to explain body position.
+type Annotation struct {
+	Id   string         `json:"id"`
+	Kind AnnotationKind `json:"kind"`
+
+	// Time is the media time, in seconds, at which the annotation appears.
+	Time float64 `json:"time"`
+
+	// Duration is the number of seconds that the annotation is shown.
+	Duration float64 `json:"duration"`
+
+	// Points of the shape:
+	//   - angle: the end of the first arm, the vertex, and the end of the second arm.
+	//   - circle: the center and a point on the circle.
+	//   - freehand: the path, at least two points.
+	//   - line: the start and end.
+	//   - text: the top left corner of the text.
+	Points []AnnotationPoint `json:"points"`
+
+	// Text is the text of a text annotation.
+	Text string `json:"text,omitempty"`
+
+	// Color in #rrggbb notation. Defaults to #ff0000.
+	Color string `json:"color"`
+
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+var annotationColorRegexp = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+func (a *Annotation) Validate() error {
+	var points int
+	switch a.Kind {
+	case AnnotationAngle:
+		points = 3
+	case AnnotationCircle, AnnotationLine:
+		points = 2
+	case AnnotationFreehand:
+		if len(a.Points) < 2 || len(a.Points) > 10_000 {
+			return fmt.Errorf("a freehand annotation must have between 2 and 10000 points")
+		}
+		points = len(a.Points)
+	case AnnotationText:
+		if a.Text == "" || len(a.Text) > 500 {
+			return fmt.Errorf("text must be between 1 and 500 characters")
+		}
+		points = 1
+	default:
+		return fmt.Errorf("invalid annotation kind %q", a.Kind)
+	}
+	if len(a.Points) != points {
+		return fmt.Errorf("a %s annotation must have %d points", a.Kind, points)
+	}
+	for _, p := range a.Points {
+		if p.X < 0 || p.X > 1 || p.Y < 0 || p.Y > 1 {
+			return fmt.Errorf("points must be between 0 and 1")
+		}
+	}
+
+	if a.Time < 0 {
+		return fmt.Errorf("time must not be negative")
+	}
+	if a.Duration <= 0 || a.Duration > 10*60 {
+		return fmt.Errorf("duration must be between 0 and 600 seconds")
+	}
+
+	if a.Color == "" {
+		a.Color = "#ff0000"
+	}
+	if !annotationColorRegexp.MatchString(a.Color) {
+		return fmt.Errorf("color must be in the #rrggbb format")
+	}
+
+	return nil
+}
+
+// End returns the media time at which the annotation disappears.
+func (a Annotation) End() float64 {
+	return a.Time + a.Duration
+}
+
+// Degrees returns the angle, between 0 and 180, of an angle annotation in a frame of the given
+// size.
+func (a Annotation) Degrees(width int, height int) float64 {
+	vertex := a.Points[1]
+	first := math.Atan2((a.Points[0].Y-vertex.Y)*float64(height), (a.Points[0].X-vertex.X)*float64(width))
+	second := math.Atan2((a.Points[2].Y-vertex.Y)*float64(height), (a.Points[2].X-vertex.X)*float64(width))
+	degrees := math.Abs(first-second) * 180 / math.Pi
+	if degrees > 180 {
+		degrees = 360 - degrees
+	}
+
+	return degrees
+}
+
+// readAnnotations returns the annotations of the session ordered by time.
+func (f *FlipCam) readAnnotations(session Session) ([]Annotation, error) {
+	var annotations []Annotation
+	err := f.store.View(func(tx storage.Tx) error {
+		var err error
+		annotations, err = storage.List[Annotation](tx, storage.BucketAnnotations, sessionKeyPrefix(session))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortStableFunc(annotations, func(a, b Annotation) int {
+		return cmp.Compare(a.Time, b.Time)
+	})
+	return annotations, nil
+}
+
+// getAnnotations returns the annotations of the session that are visible during the clip.
+func (f *FlipCam) getAnnotations(session Session, clip ClipRange) ([]Annotation, error) {
+	annotations, err := f.readAnnotations(session)
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.DeleteFunc(annotations, func(a Annotation) bool {
+		return a.End() <= clip.Start || a.Time >= clip.End
+	}), nil
+}
+
+func (f *FlipCam) handleGetAnnotations(w http.ResponseWriter, r *http.Request) {
+	session, err := f.getSession(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	annotations, err := f.readAnnotations(session)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-cache")
+	writeJson(w, annotations)
+}
+
+func (f *FlipCam) handleCreateAnnotation(w http.ResponseWriter, r *http.Request) {
+	session, err := f.getSession(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	var annotation Annotation
+	err = json.UnmarshalRead(r.Body, &annotation)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid annotation: %v", err), http.StatusBadRequest)
+		return
+	}
+	err = annotation.Validate()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	annotation.Id = rand.Text()[:10]
+	annotation.CreatedAt = time.Now()
+
+	err = f.store.Update(func(tx storage.Tx) error {
+		return tx.Put(storage.BucketAnnotations, sessionKey(session, annotation.Id), annotation)
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJson(w, annotation)
+}
+
+func (f *FlipCam) handleDeleteAnnotation(w http.ResponseWriter, r *http.Request) {
+	session, err := f.getSession(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	err = f.store.Update(func(tx storage.Tx) error {
+		return tx.Delete(storage.BucketAnnotations, sessionKey(session, r.PathValue("annotationId")))
+	})
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
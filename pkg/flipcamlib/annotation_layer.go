@@ -0,0 +1,289 @@
+package flipcamlib
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// annotationLayer burns the annotations of a clip into the video of an export.
+// Shapes are rasterized to transparent images that are overlaid on the video, text is drawn
+// with drawtext.
+type annotationLayer struct {
+	annotations []Annotation
+	clipStart   float64
+	fontFile    string
+
+	// images are the paths of the rasterized shapes, by index of the annotation.
+	images map[int]string
+
+	// textFiles are the paths of the text for drawtext, by index of the annotation.
+	textFiles map[int]string
+
+	width  int
+	height int
+}
+
+// newAnnotationLayer prepares the annotations of the clip. The images and text files are
+// written to dir.
+func (f *FlipCam) newAnnotationLayer(clip ClipRange, dir string) (*annotationLayer, error) {
+	session, err := f.getSession(clip.SessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	annotations, err := f.getAnnotations(session, clip)
+	if err != nil {
+		return nil, err
+	}
+
+	layer := &annotationLayer{
+		annotations: annotations,
+		clipStart:   clip.Start,
+		fontFile:    f.overlayFontFile,
+		images:      make(map[int]string),
+		textFiles:   make(map[int]string),
+	}
+	if len(annotations) == 0 {
+		return layer, nil
+	}
+
+	playlist, err := session.readPlaylist()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read playlist: %w", err)
+	}
+	initData, err := os.ReadFile(session.uriPath(playlist.MapUri))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read initialization segment: %w", err)
+	}
+	init, err := parseFmp4Init(initData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse initialization segment: %w", err)
+	}
+	if init.Width == 0 || init.Height == 0 {
+		return nil, fmt.Errorf("unknown video size of session %s", session.Id)
+	}
+	layer.width = init.Width
+	layer.height = init.Height
+
+	// The same session can be used by multiple clips of an export
+	dir, err = os.MkdirTemp(dir, session.Id+"_")
+	if err != nil {
+		return nil, err
+	}
+	prefix := path.Join(dir, "annotation_")
+	for i, annotation := range annotations {
+		var text string
+		switch annotation.Kind {
+		case AnnotationText:
+			text = annotation.Text
+		case AnnotationAngle:
+			text = fmt.Sprintf("%.0f°", annotation.Degrees(layer.width, layer.height))
+		}
+		if text != "" {
+			textFile := prefix + strconv.Itoa(i) + ".txt"
+			err := os.WriteFile(textFile, []byte(escapeDrawtext(text)), 0o644)
+			if err != nil {
+				return nil, fmt.Errorf("failed to write annotation text: %w", err)
+			}
+			layer.textFiles[i] = textFile
+		}
+
+		if annotation.Kind == AnnotationText {
+			continue
+		}
+
+		var b bytes.Buffer
+		err := png.Encode(&b, layer.rasterize(annotation))
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode annotation: %w", err)
+		}
+		imageFile := prefix + strconv.Itoa(i) + ".png"
+		err = os.WriteFile(imageFile, b.Bytes(), 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write annotation: %w", err)
+		}
+		layer.images[i] = imageFile
+	}
+
+	return layer, nil
+}
+
+// inputArgs returns the ffmpeg arguments that add the images as inputs.
+func (l *annotationLayer) inputArgs() []string {
+	var args []string
+	for i := range l.annotations {
+		if imageFile, found := l.images[i]; found {
+			args = append(args, "-i", imageFile)
+		}
+	}
+
+	return args
+}
+
+// filter returns the part of the filtergraph that draws the annotations on the in stream and
+// outputs the out stream. firstInput is the input index of the first image of inputArgs.
+// The timestamps of the in stream must start at zero at the start of the clip.
+func (l *annotationLayer) filter(in string, out string, firstInput int) string {
+	if len(l.annotations) == 0 {
+		return fmt.Sprintf("[%s]null[%s]", in, out)
+	}
+
+	var chains []string
+	current := in
+	input := firstInput
+	for i, annotation := range l.annotations {
+		enable := escapeFilterValue(fmt.Sprintf(
+			"between(t,%s,%s)",
+			formatFilterFloat(annotation.Time-l.clipStart),
+			formatFilterFloat(annotation.End()-l.clipStart),
+		))
+
+		if _, found := l.images[i]; found {
+			next := fmt.Sprintf("%s_shape%d", in, i)
+			chains = append(chains, fmt.Sprintf(
+				"[%s][%d:v]overlay=enable=%s[%s]",
+				current,
+				input,
+				enable,
+				next,
+			))
+			current = next
+			input++
+		}
+
+		if textFile, found := l.textFiles[i]; found {
+			// Text is placed at its point, the angle is placed next to the vertex
+			point := annotation.Points[0]
+			if annotation.Kind == AnnotationAngle {
+				point = annotation.Points[1]
+			}
+			options := []string{
+				"textfile=" + escapeFilterValue(textFile),
+				"fontsize=h*0.04",
+				"fontcolor=" + strings.Replace(annotation.Color, "#", "0x", 1),
+				"box=1",
+				"boxcolor=black@0.5",
+				"boxborderw=4",
+				"x=" + strconv.Itoa(int(point.X*float64(l.width))+l.thickness()*3),
+				"y=" + strconv.Itoa(int(point.Y*float64(l.height))),
+				"enable=" + enable,
+			}
+			if l.fontFile != "" {
+				options = append(options, "fontfile="+escapeFilterValue(l.fontFile))
+			}
+
+			next := fmt.Sprintf("%s_text%d", in, i)
+			chains = append(chains, fmt.Sprintf("[%s]drawtext=%s[%s]", current, strings.Join(options, ":"), next))
+			current = next
+		}
+	}
+
+	chains = append(chains, fmt.Sprintf("[%s]null[%s]", current, out))
+	return strings.Join(chains, ";")
+}
+
+// thickness returns the width of the lines in pixels.
+func (l *annotationLayer) thickness() int {
+	return max(2, l.height/180)
+}
+
+// rasterize draws the shape of the annotation on a transparent image the size of the video.
+func (l *annotationLayer) rasterize(a Annotation) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, l.width, l.height))
+	c := parseAnnotationColor(a.Color)
+	thickness := float64(l.thickness())
+	points := make([][2]float64, len(a.Points))
+	for i, p := range a.Points {
+		points[i] = [2]float64{p.X * float64(l.width), p.Y * float64(l.height)}
+	}
+
+	switch a.Kind {
+	case AnnotationCircle:
+		radius := math.Hypot(points[1][0]-points[0][0], points[1][1]-points[0][1])
+		drawRing(img, points[0], radius, thickness, c)
+	case AnnotationAngle, AnnotationFreehand, AnnotationLine:
+		for i := 1; i < len(points); i++ {
+			drawSegment(img, points[i-1], points[i], thickness, c)
+		}
+	}
+
+	return img
+}
+
+func parseAnnotationColor(v string) color.NRGBA {
+	rgb, _ := strconv.ParseUint(strings.TrimPrefix(v, "#"), 16, 32)
+	return color.NRGBA{R: uint8(rgb >> 16), G: uint8(rgb >> 8), B: uint8(rgb), A: 255}
+}
+
+// drawSegment draws an anti-aliased line with round ends.
+func drawSegment(img *image.NRGBA, from [2]float64, to [2]float64, thickness float64, c color.NRGBA) {
+	dx := to[0] - from[0]
+	dy := to[1] - from[1]
+	lengthSq := dx*dx + dy*dy
+	drawShape(
+		img,
+		min(from[0], to[0])-thickness,
+		min(from[1], to[1])-thickness,
+		max(from[0], to[0])+thickness,
+		max(from[1], to[1])+thickness,
+		thickness,
+		c,
+		func(x float64, y float64) float64 {
+			// Distance from the point to the closest point on the segment
+			t := 0.0
+			if lengthSq > 0 {
+				t = min(max(((x-from[0])*dx+(y-from[1])*dy)/lengthSq, 0), 1)
+			}
+			return math.Hypot(x-(from[0]+t*dx), y-(from[1]+t*dy))
+		},
+	)
+}
+
+// drawRing draws an anti-aliased circle outline.
+func drawRing(img *image.NRGBA, center [2]float64, radius float64, thickness float64, c color.NRGBA) {
+	drawShape(
+		img,
+		center[0]-radius-thickness,
+		center[1]-radius-thickness,
+		center[0]+radius+thickness,
+		center[1]+radius+thickness,
+		thickness,
+		c,
+		func(x float64, y float64) float64 {
+			return math.Abs(math.Hypot(x-center[0], y-center[1]) - radius)
+		},
+	)
+}
+
+// drawShape colors the pixels within the bounding box that are closer than thickness/2 to the
+// shape. distance returns the distance from a pixel center to the shape.
+func drawShape(
+	img *image.NRGBA,
+	minX, minY, maxX, maxY float64,
+	thickness float64,
+	c color.NRGBA,
+	distance func(x float64, y float64) float64,
+) {
+	bounds := img.Bounds()
+	x0 := max(int(math.Floor(minX)), bounds.Min.X)
+	y0 := max(int(math.Floor(minY)), bounds.Min.Y)
+	x1 := min(int(math.Ceil(maxX)), bounds.Max.X-1)
+	y1 := min(int(math.Ceil(maxY)), bounds.Max.Y-1)
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			coverage := min(max(thickness/2+0.5-distance(float64(x)+0.5, float64(y)+0.5), 0), 1)
+			alpha := uint8(coverage * 255)
+			if alpha > img.NRGBAAt(x, y).A {
+				img.SetNRGBA(x, y, color.NRGBA{R: c.R, G: c.G, B: c.B, A: alpha})
+			}
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package flipcamlib
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/go-json-experiment/json"
+)
+
+// BroadcastDelay is the delay behind live that a coach sets for every viewer, e.g. so that
+// athletes see their attempt when they walk back from it. The players of the viewers jump to
+// the delay when it changes, viewers can still seek afterwards.
+type BroadcastDelay struct {
+	Enabled bool `json:"enabled"`
+
+	// Seconds is the delay behind the moment the camera recorded the video.
+	Seconds float64 `json:"seconds"`
+}
+
+func (d BroadcastDelay) Validate() error {
+	if d.Seconds < 0 || d.Seconds > maxPlaylistDelay.Seconds() {
+		return fmt.Errorf("the delay must be between 0 and %.0f seconds", maxPlaylistDelay.Seconds())
+	}
+
+	return nil
+}
+
+func (f *FlipCam) getBroadcastDelay() BroadcastDelay {
+	f.broadcastDelayMu.RLock()
+	defer f.broadcastDelayMu.RUnlock()
+	return f.broadcastDelay
+}
+
+func (f *FlipCam) setBroadcastDelay(d BroadcastDelay) {
+	f.broadcastDelayMu.Lock()
+	defer f.broadcastDelayMu.Unlock()
+	f.broadcastDelay = d
+}
+
+func (f *FlipCam) handleGetBroadcastDelay(w http.ResponseWriter, _ *http.Request) {
+	writeJson(w, f.getBroadcastDelay())
+}
+
+func (f *FlipCam) handlePutBroadcastDelay(w http.ResponseWriter, r *http.Request) {
+	var delay BroadcastDelay
+	err := json.UnmarshalRead(r.Body, &delay)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid broadcast delay: %v", err), http.StatusBadRequest)
+		return
+	}
+	err = delay.Validate()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = f.saveSetting(settingBroadcastDelay, delay)
+	if err != nil {
+		log.Printf("web: failed to save broadcast delay: %v\n", err)
+		http.Error(w, "failed to save broadcast delay", http.StatusInternalServerError)
+		return
+	}
+	f.setBroadcastDelay(delay)
+	writeJson(w, delay)
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/go-json-experiment/json"
 	"github.com/go-json-experiment/json/jsontext"
 	"io"
+	"regexp"
 )
 
 var goProApi = "api.gopro.com"
@@ -14,6 +15,11 @@ type CaddyConfOpts struct {
 }
 
 func (f *FlipCam) GenerateCaddyConfig(w io.Writer, opts CaddyConfOpts) error {
+	err := f.validateCameras()
+	if err != nil {
+		return err
+	}
+
 	routerIp := f.RouterIp().Addr().String()
 	allHosts := []string{
 		routerIp,
@@ -43,13 +49,35 @@ func (f *FlipCam) GenerateCaddyConfig(w io.Writer, opts CaddyConfOpts) error {
 		},
 	}
 
+	uiProxy := OrderedObject[interface{}]{
+		{"handler", "reverse_proxy"},
+		{
+			"upstreams", []OrderedObject[interface{}]{
+				{
+					{"dial", "localhost" + f.uiPort},
+				},
+			},
+		},
+		{
+			"transport", OrderedObject[interface{}]{
+				{"protocol", "http"},
+			},
+		},
+	}
+
+	hlsPrefixes := f.hlsPrefixes()
+	var manifestPaths []string
+	for _, prefix := range hlsPrefixes {
+		manifestPaths = append(manifestPaths, prefix+"/*.m3u8", prefix+"/*.mpd")
+	}
+
 	flipcamRoutes := []OrderedObject[interface{}]{
 		{
 			// Allow CORS from local origins
 			{
 				"match", []OrderedObject[interface{}]{
 					{
-						{"path", []string{f.hlsUrlPathPrefix + "/*.m3u8"}},
+						{"path", manifestPaths},
 					},
 				},
 			},
@@ -106,12 +134,34 @@ func (f *FlipCam) GenerateCaddyConfig(w io.Writer, opts CaddyConfOpts) error {
 				},
 			},
 		},
-		{
-			// Camera path is HLS, powered by ffmpeg, which writes the files to disk.
+	}
+
+	for _, prefix := range hlsPrefixes {
+		flipcamRoutes = append(flipcamRoutes, OrderedObject[interface{}]{
+			// Delayed playlists are rendered by the UI web server, see handleDelayedPlaylist.
 			{
 				"match", []OrderedObject[interface{}]{
 					{
-						{"path", []string{f.hlsUrlPathPrefix + "/*"}},
+						{"path", []string{prefix + "/" + delayedPathSegment + "/*"}},
+					},
+				},
+			},
+			{
+				"handle", []OrderedObject[interface{}]{uiProxy},
+			},
+			{"terminal", true},
+		}, OrderedObject[interface{}]{
+			// Camera path is HLS, powered by ffmpeg, which writes the files to disk. Only the
+			// files of sessions are served, see hlsFilePattern.
+			{
+				"match", []OrderedObject[interface{}]{
+					{
+						{
+							"path_regexp", OrderedObject[interface{}]{
+								{"name", "hls_file"},
+								{"pattern", "^" + regexp.QuoteMeta(prefix) + "/" + hlsFilePattern + "$"},
+							},
+						},
 					},
 				},
 			},
@@ -147,7 +197,7 @@ func (f *FlipCam) GenerateCaddyConfig(w io.Writer, opts CaddyConfOpts) error {
 					},
 					{
 						{"handler", "rewrite"},
-						{"strip_path_prefix", "/camera"},
+						{"strip_path_prefix", prefix},
 					},
 					{
 						{"handler", "file_server"},
@@ -157,31 +207,35 @@ func (f *FlipCam) GenerateCaddyConfig(w io.Writer, opts CaddyConfOpts) error {
 				},
 			},
 			{"terminal", true},
+		})
+	}
+
+	flipcamRoutes = append(flipcamRoutes, OrderedObject[interface{}]{
+		// Nothing else in the HLS output directory is public, e.g. the results of jobs and the
+		// audio of comments are served by the API, which checks the request.
+		{
+			"match", []OrderedObject[interface{}]{
+				{
+					{"path", []string{f.hlsUrlPathPrefix + "/*"}},
+				},
+			},
 		},
 		{
-			// UI web server
-			{
-				"handle", []OrderedObject[interface{}]{
-					{
-						{"handler", "reverse_proxy"},
-						{
-							"upstreams", []OrderedObject[interface{}]{
-								{
-									{"dial", "localhost" + f.uiPort},
-								},
-							},
-						},
-						{
-							"transport", OrderedObject[interface{}]{
-								{"protocol", "http"},
-							},
-						},
-					},
+			"handle", []OrderedObject[interface{}]{
+				{
+					{"handler", "static_response"},
+					{"status_code", 404},
 				},
 			},
-			{"terminal", true},
 		},
-	}
+		{"terminal", true},
+	}, OrderedObject[interface{}]{
+		// UI web server
+		{
+			"handle", []OrderedObject[interface{}]{uiProxy},
+		},
+		{"terminal", true},
+	})
 
 	server := OrderedObject[interface{}]{
 		{"listen", []string{":80", ":443"}},
@@ -330,7 +384,7 @@ func (f *FlipCam) GenerateCaddyConfig(w io.Writer, opts CaddyConfOpts) error {
 		},
 	}
 
-	err := json.MarshalWrite(w, config, jsontext.WithIndent("\t"))
+	err = json.MarshalWrite(w, config, jsontext.WithIndent("\t"))
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,265 @@
+package flipcamlib
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultCameraName is the name of the camera if none are configured.
+const DefaultCameraName = "main"
+
+// firstMuxerPort is the loopback port on which the muxer of the first camera listens, the next
+// camera uses the next port. Publishers connect to the router, see runRtmpRouter.
+const firstMuxerPort = 19350
+
+// camera is an RTMP ingest with its own muxer, playlists, and sessions.
+type camera struct {
+	name      string
+	muxerPort int
+
+	// publishing is set while the router forwards a publisher to the muxer.
+	publishing atomic.Bool
+
+	// urlPathPrefix is the path under which the HLS files of the camera are served.
+	urlPathPrefix string
+
+	restart chan chan struct{}
+
+	// streamKeys are the stream names that the camera may publish with, see Opts.StreamKeys.
+	streamKeys []string
+
+	playlistPath   string
+	playlistPathMu sync.RWMutex
+
+	// Info about the stream that is currently being muxed.
+	streamInfoMu sync.RWMutex
+	transcoding  bool
+	videoCodec   string
+
+	// When the packets of the current session arrived, see latency.go.
+	arrival   arrivalStats
+	arrivalMu sync.Mutex
+
+	// Settings that determine how the video is processed by the muxer.
+	// A change takes effect when the muxer restarts.
+	overlay         Overlay
+	transform       VideoTransform
+	videoSettingsMu sync.RWMutex
+}
+
+var cameraNameRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// validateCameraNames returns an error if a name can't be used in URLs or is used twice.
+func validateCameraNames(names []string) error {
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		switch {
+		case !cameraNameRegexp.MatchString(name):
+			return fmt.Errorf(
+				"invalid camera name %q, only lowercase letters, digits, and - are allowed",
+				name,
+			)
+		case name == delayedPathSegment:
+			return fmt.Errorf("camera name %q is reserved", name)
+		case seen[name]:
+			return fmt.Errorf("camera name %q is used twice", name)
+		}
+		seen[name] = true
+	}
+
+	return nil
+}
+
+// validateCameras returns an error if the names or stream keys of the cameras are invalid.
+func (f *FlipCam) validateCameras() error {
+	names := make([]string, len(f.cameras))
+	for i, c := range f.cameras {
+		names[i] = c.name
+	}
+
+	err := validateCameraNames(names)
+	if err != nil {
+		return err
+	}
+
+	return f.validateStreamKeys()
+}
+
+func newCameras(names []string, hlsUrlPathPrefix string, streamKeys map[string][]string) []*camera {
+	if len(names) == 0 {
+		names = []string{DefaultCameraName}
+	}
+
+	cameras := make([]*camera, len(names))
+	for i, name := range names {
+		cameras[i] = &camera{
+			name:          name,
+			muxerPort:     firstMuxerPort + i,
+			urlPathPrefix: hlsUrlPathPrefix + "/" + name,
+			restart:       make(chan chan struct{}),
+			streamKeys:    streamKeys[name],
+			overlay: Overlay{
+				Position: OverlayTopLeft,
+				Size:     defaultOverlaySize,
+			},
+		}
+	}
+
+	return cameras
+}
+
+// rtmpUrl returns the URL that cameras publish to, without the stream name.
+func rtmpUrl(host string) string {
+	return fmt.Sprintf("rtmp://%s:%d/camera/", host, rtmpPort)
+}
+
+func (c *camera) muxerAddr() string {
+	return fmt.Sprintf("127.0.0.1:%d", c.muxerPort)
+}
+
+func (c *camera) setPlayListUrlPath(playlistFile string) error {
+	newPath, err := url.JoinPath(c.urlPathPrefix, playlistFile)
+	if err != nil {
+		return err
+	}
+
+	c.playlistPathMu.Lock()
+	c.playlistPath = newPath
+	c.playlistPathMu.Unlock()
+	return nil
+}
+
+func (c *camera) getPlayListUrlPath() string {
+	c.playlistPathMu.RLock()
+	defer c.playlistPathMu.RUnlock()
+	return c.playlistPath
+}
+
+// restartMuxerAndWait restarts the muxer of the camera and returns after the new muxer has
+// started. Returns immediately if flipcam is stopping, the muxer is then not restarted.
+func (f *FlipCam) restartMuxerAndWait(c *camera) {
+	done := make(chan struct{})
+	select {
+	case c.restart <- done:
+	case <-f.stop:
+		return
+	}
+
+	select {
+	case <-done:
+	case <-f.stop:
+	}
+}
+
+var errCameraNotFound = errors.New("camera not found")
+
+func (f *FlipCam) getCamera(name string) (*camera, error) {
+	for _, c := range f.cameras {
+		if c.name == name {
+			return c, nil
+		}
+	}
+
+	return nil, errCameraNotFound
+}
+
+// requestCamera returns the camera named by the camera query parameter. The first camera is
+// returned if the parameter is absent.
+func (f *FlipCam) requestCamera(r *http.Request) (*camera, error) {
+	name := r.URL.Query().Get("camera")
+	if name == "" {
+		return f.cameras[0], nil
+	}
+
+	return f.getCamera(name)
+}
+
+// CameraStatus describes a camera and the stream it is currently receiving.
+type CameraStatus struct {
+	Name         string `json:"name"`
+	PlaylistPath string `json:"playlistPath"`
+
+	// RtmpUrl is the URL the camera publishes to. The stream key is left out as every device on
+	// the network can read the status, the URL then ends in /camera/.
+	RtmpUrl string `json:"rtmpUrl"`
+
+	// Transcoding is true if the current muxer run re-encodes the video to H.264.
+	Transcoding bool `json:"transcoding"`
+
+	// VideoCodec is the codec of the incoming stream as named by ffmpeg. Empty when no stream
+	// has been received yet.
+	VideoCodec string `json:"videoCodec"`
+}
+
+func (f *FlipCam) cameraStatus(c *camera) CameraStatus {
+	publishUrl := rtmpUrl(f.RouterIp().Addr().String())
+	if len(c.streamKeys) == 0 {
+		publishUrl += f.streamName(c)
+	}
+
+	c.streamInfoMu.RLock()
+	defer c.streamInfoMu.RUnlock()
+	return CameraStatus{
+		Name:         c.name,
+		PlaylistPath: c.getPlayListUrlPath(),
+		RtmpUrl:      publishUrl,
+		Transcoding:  c.transcoding,
+		VideoCodec:   c.videoCodec,
+	}
+}
+
+func (f *FlipCam) cameraStatuses() []CameraStatus {
+	statuses := make([]CameraStatus, len(f.cameras))
+	for i, c := range f.cameras {
+		statuses[i] = f.cameraStatus(c)
+	}
+
+	return statuses
+}
+
+func (f *FlipCam) handleGetCameras(w http.ResponseWriter, r *http.Request) {
+	writeJson(w, f.cameraStatuses())
+}
+
+// handleGetStatus returns the status of the camera of the request.
+func (f *FlipCam) handleGetStatus(w http.ResponseWriter, r *http.Request) {
+	c, err := f.requestCamera(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	writeJson(w, f.status(c))
+}
+
+func (f *FlipCam) handleRestartMuxer(w http.ResponseWriter, r *http.Request) {
+	c, err := f.requestCamera(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	f.restartMuxerAndWait(c)
+	w.Header().Set("Content-Type", "text/plain")
+	_, err = w.Write([]byte(c.getPlayListUrlPath()))
+	if err != nil {
+		log.Printf("web: failed to write new playlist path: %v\n", err)
+	}
+}
+
+// maxAngles is the number of cameras that the cameras page plays at once.
+const maxAngles = 4
+
+func (f *FlipCam) handleCamerasPage(w http.ResponseWriter, r *http.Request) {
+	err := CamerasPage(f.cameraStatuses()).Render(r.Context(), w)
+	if err != nil {
+		log.Printf("web: failed to render: %v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
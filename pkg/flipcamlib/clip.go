@@ -0,0 +1,173 @@
+package flipcamlib
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/MatthiasKunnen/flipcam/pkg/flipcamlib/storage"
+	"github.com/go-json-experiment/json"
+)
+
+// ClipRange is a part of a session.
+// Times are media times in seconds, which equal the currentTime of the video element that plays
+// the session's playlist.
+type ClipRange struct {
+	SessionId string  `json:"sessionId"`
+	Start     float64 `json:"start"`
+	End       float64 `json:"end"`
+}
+
+func (c ClipRange) Validate() error {
+	switch {
+	case c.Start < 0:
+		return fmt.Errorf("start must not be negative")
+	case c.End <= c.Start:
+		return fmt.Errorf("end must be after start")
+	case c.End-c.Start > 10*60:
+		return fmt.Errorf("clips can be at most 10 minutes long")
+	}
+
+	return nil
+}
+
+func (c ClipRange) start() time.Duration {
+	return time.Duration(c.Start * float64(time.Second))
+}
+
+func (c ClipRange) duration() time.Duration {
+	return time.Duration((c.End - c.Start) * float64(time.Second))
+}
+
+// openClip returns a fragmented MP4 stream that contains the clip.
+// The stream starts at the beginning of the segment that contains the start of the clip, offset
+// is the position of the clip's start within the stream.
+func (f *FlipCam) openClip(c ClipRange) (input io.ReadCloser, offset time.Duration, err error) {
+	session, err := f.getSession(c.SessionId)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	playlist, err := session.readPlaylist()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read playlist: %w", err)
+	}
+
+	end := time.Duration(c.End * float64(time.Second))
+	segments := playlist.SegmentsBetween(c.start(), end)
+	if len(segments) == 0 {
+		return nil, 0, fmt.Errorf("session %s has no video between %.1fs and %.1fs", c.SessionId, c.Start, c.End)
+	}
+
+	input, err = session.openSegments(playlist.MapUri, segments)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return input, max(c.start()-segments[0].MediaTime, 0), nil
+}
+
+// Clip is a saved part of a session, e.g. an attempt that a coach wants to compare later.
+type Clip struct {
+	Id        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	ClipRange
+}
+
+// getClips returns the saved clips, newest first.
+func (f *FlipCam) getClips() ([]Clip, error) {
+	var clips []Clip
+	err := f.store.View(func(tx storage.Tx) error {
+		var err error
+		clips, err = storage.List[Clip](tx, storage.BucketClips, "")
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortStableFunc(clips, func(a, b Clip) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return clips, nil
+}
+
+func (f *FlipCam) getClip(id string) (Clip, error) {
+	var clip Clip
+	err := f.store.View(func(tx storage.Tx) error {
+		return tx.Get(storage.BucketClips, id, &clip)
+	})
+	if err != nil {
+		return Clip{}, fmt.Errorf("clip %s: %w", id, err)
+	}
+
+	return clip, nil
+}
+
+func (f *FlipCam) handleGetClips(w http.ResponseWriter, r *http.Request) {
+	clips, err := f.getClips()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJson(w, clips)
+}
+
+func (f *FlipCam) handleCreateClip(w http.ResponseWriter, r *http.Request) {
+	var clip Clip
+	err := json.UnmarshalRead(r.Body, &clip)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid clip: %v", err), http.StatusBadRequest)
+		return
+	}
+	err = clip.ClipRange.Validate()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := f.getSession(clip.SessionId); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	clip.Id = rand.Text()[:10]
+	clip.CreatedAt = time.Now()
+	clip.Name = strings.TrimSpace(clip.Name)
+	if clip.Name == "" {
+		clip.Name = clip.CreatedAt.Format("2006-01-02 15:04:05")
+	}
+
+	err = f.store.Update(func(tx storage.Tx) error {
+		return tx.Put(storage.BucketClips, clip.Id, clip)
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJson(w, clip)
+}
+
+func (f *FlipCam) handleDeleteClip(w http.ResponseWriter, r *http.Request) {
+	err := f.store.Update(func(tx storage.Tx) error {
+		return tx.Delete(storage.BucketClips, r.PathValue("id"))
+	})
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,84 @@
+package flipcamlib
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/go-json-experiment/json"
+)
+
+func (f *FlipCam) handleCreateClipExport(w http.ResponseWriter, r *http.Request) {
+	var clip ClipRange
+	err := json.UnmarshalRead(r.Body, &clip)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid clip: %v", err), http.StatusBadRequest)
+		return
+	}
+	err = clip.Validate()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := f.getSession(clip.SessionId); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	filename := fmt.Sprintf("flipcam_%s_%.1f-%.1f.mp4", clip.SessionId, clip.Start, clip.End)
+	job, err := f.queueJob("clip", filename, "video/mp4", func(ctx context.Context, resultPath string) error {
+		return f.renderClip(ctx, clip, resultPath)
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	writeJson(w, job)
+}
+
+// renderClip exports the clip, with its annotations drawn on top, to an MP4 file.
+func (f *FlipCam) renderClip(ctx context.Context, clip ClipRange, resultPath string) error {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Minute)
+	defer cancel()
+
+	input, offset, err := f.openClip(clip)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	annotationsDir, err := os.MkdirTemp("", "flipcam-clip-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(annotationsDir)
+	annotations, err := f.newAnnotationLayer(clip, annotationsDir)
+	if err != nil {
+		return err
+	}
+
+	filter := fmt.Sprintf(
+		"[0:v]trim=start=%s:duration=%s,setpts=PTS-STARTPTS[clip];%s;[annotated]format=yuv420p[out]",
+		formatFilterFloat(offset.Seconds()),
+		formatFilterFloat(clip.duration().Seconds()),
+		annotations.filter("clip", "annotated", 1),
+	)
+	args := append([]string{"-f", "mov", "-i", "pipe:0"}, annotations.inputArgs()...)
+	_, err = runFfmpegLowPriority(ctx, input, append(args,
+		"-filter_complex", filter,
+		"-map", "[out]",
+		"-an",
+		"-c:v", "libx264",
+		"-preset", "veryfast",
+		"-crf", "20",
+		"-movflags", "+faststart",
+		"-f", "mp4",
+		"-y",
+		resultPath,
+	)...)
+
+	return err
+}
@@ -0,0 +1,200 @@
+package flipcamlib
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/go-json-experiment/json"
+)
+
+// Role determines what a client may do. Viewers may watch, coaches may also control the
+// recording and change or delete the stored data.
+type Role string
+
+const (
+	RoleViewer Role = "viewer"
+	RoleCoach  Role = "coach"
+)
+
+const (
+	// coachCookie holds the token of a client that unlocked the coach role. It is a session
+	// cookie, the role is lost when the browser or flipcam restarts.
+	coachCookie = "flipcam_coach"
+
+	// pairPath is the path of the pairing link, encoded in the QR code of the coach page.
+	pairPath = "/coach/pair"
+
+	// generatedPinLength is the number of digits of the PIN generated if none is configured.
+	generatedPinLength = 6
+
+	// wrongPinDelay slows down guessing the PIN. Attempts are handled one at a time.
+	wrongPinDelay = time.Second
+)
+
+var coachPinRegexp = regexp.MustCompile(`^[0-9]{4,}$`)
+
+func validateCoachPin(pin string) error {
+	if !coachPinRegexp.MatchString(pin) {
+		return fmt.Errorf("the coach PIN must be at least 4 digits")
+	}
+
+	return nil
+}
+
+// generatePin returns a random PIN of the given number of digits.
+func generatePin(digits int) string {
+	pin := make([]byte, digits)
+	for i := range pin {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			panic(err) // crypto/rand never fails on Linux
+		}
+		pin[i] = byte('0' + n.Int64())
+	}
+
+	return string(pin)
+}
+
+// role returns the role of the client that made the request.
+func (f *FlipCam) role(r *http.Request) Role {
+	cookie, err := r.Cookie(coachCookie)
+	if err != nil {
+		return RoleViewer
+	}
+
+	f.coachTokensMu.Lock()
+	defer f.coachTokensMu.Unlock()
+	if _, ok := f.coachTokens[cookie.Value]; ok {
+		return RoleCoach
+	}
+
+	return RoleViewer
+}
+
+func (f *FlipCam) isCoach(r *http.Request) bool {
+	return f.role(r) == RoleCoach
+}
+
+// coachOnly wraps a handler so that it rejects clients without the coach role.
+func (f *FlipCam) coachOnly(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !f.isCoach(r) {
+			http.Error(
+				w,
+				"only a coach can do this, unlock the coach role at /coach",
+				http.StatusForbidden,
+			)
+			return
+		}
+
+		handler(w, r)
+	}
+}
+
+// grantCoach gives the client of the request the coach role.
+func (f *FlipCam) grantCoach(w http.ResponseWriter) {
+	token := rand.Text()
+	f.coachTokensMu.Lock()
+	f.coachTokens[token] = struct{}{}
+	f.coachTokensMu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     coachCookie,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+func (f *FlipCam) handleGetRole(w http.ResponseWriter, r *http.Request) {
+	writeRole(w, f.role(r))
+}
+
+func writeRole(w http.ResponseWriter, role Role) {
+	w.Header().Set("Cache-Control", "no-store")
+	writeJson(w, struct {
+		Role Role `json:"role"`
+	}{Role: role})
+}
+
+// handleUnlockCoach grants the coach role to a client that knows the PIN.
+func (f *FlipCam) handleUnlockCoach(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Pin string `json:"pin"`
+	}
+	err := json.UnmarshalRead(r.Body, &body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	f.pinAttemptMu.Lock()
+	if subtle.ConstantTimeCompare([]byte(body.Pin), []byte(f.coachPin)) != 1 {
+		time.Sleep(wrongPinDelay)
+		f.pinAttemptMu.Unlock()
+		log.Printf("web: wrong coach PIN entered by %s\n", r.RemoteAddr)
+		http.Error(w, "wrong PIN", http.StatusForbidden)
+		return
+	}
+	f.pinAttemptMu.Unlock()
+
+	f.grantCoach(w)
+	writeRole(w, RoleCoach)
+}
+
+// handlePair grants the coach role to a client that opened the pairing link, then redirects it
+// to the UI.
+func (f *FlipCam) handlePair(w http.ResponseWriter, r *http.Request) {
+	code := r.URL.Query().Get("code")
+	if subtle.ConstantTimeCompare([]byte(code), []byte(f.pairingCode)) != 1 {
+		log.Printf("web: invalid pairing code used by %s\n", r.RemoteAddr)
+		http.Error(w, "invalid pairing code", http.StatusForbidden)
+		return
+	}
+
+	f.grantCoach(w)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// handleLeaveCoach removes the coach role from the client.
+func (f *FlipCam) handleLeaveCoach(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(coachCookie)
+	if err == nil {
+		f.coachTokensMu.Lock()
+		delete(f.coachTokens, cookie.Value)
+		f.coachTokensMu.Unlock()
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:   coachCookie,
+		Path:   "/",
+		MaxAge: -1,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (f *FlipCam) handleCoachPage(w http.ResponseWriter, r *http.Request) {
+	coach := f.isCoach(r)
+	pairingPath := ""
+	if coach {
+		pairingPath = f.pairingPath()
+	}
+
+	err := CoachPage(coach, pairingPath).Render(r.Context(), w)
+	if err != nil {
+		log.Printf("web: failed to render: %v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// pairingPath returns the path of the link that grants the coach role.
+func (f *FlipCam) pairingPath() string {
+	return pairPath + "?code=" + f.pairingCode
+}
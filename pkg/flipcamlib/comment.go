@@ -0,0 +1,257 @@
+package flipcamlib
+
+import (
+	"cmp"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MatthiasKunnen/flipcam/pkg/flipcamlib/storage"
+)
+
+// Comment is a coach's remark attached to a point in time of a session. It is either a text note
+// or a recorded audio comment with an optional text.
+type Comment struct {
+	Id string `json:"id"`
+
+	// Time is the media time, in seconds, to which the comment applies.
+	Time float64 `json:"time"`
+
+	Text string `json:"text,omitempty"`
+
+	// AudioUrl is the URL of the recording, empty for text notes.
+	AudioUrl string `json:"audioUrl,omitempty"`
+
+	// AudioFile is the name of the recording in the session's comment directory.
+	AudioFile string `json:"audioFile,omitempty"`
+
+	// AudioType is the content type of the recording.
+	AudioType string `json:"audioType,omitempty"`
+
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+const (
+	commentsDirSuffix = "_comments"
+
+	// maxCommentUpload is the maximum size of an upload, large enough for a few minutes of
+	// compressed audio.
+	maxCommentUpload = 20 << 20
+)
+
+// commentAudioTypes are the accepted content types of recordings and their file extension.
+var commentAudioTypes = map[string]string{
+	"audio/aac":  ".aac",
+	"audio/mp4":  ".m4a",
+	"audio/mpeg": ".mp3",
+	"audio/ogg":  ".ogg",
+	"audio/wav":  ".wav",
+	"audio/webm": ".webm",
+}
+
+// readComments returns the comments of the session ordered by time.
+func (f *FlipCam) readComments(session Session) ([]Comment, error) {
+	var comments []Comment
+	err := f.store.View(func(tx storage.Tx) error {
+		var err error
+		comments, err = storage.List[Comment](tx, storage.BucketComments, sessionKeyPrefix(session))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortStableFunc(comments, func(a, b Comment) int {
+		return cmp.Compare(a.Time, b.Time)
+	})
+	return comments, nil
+}
+
+func (f *FlipCam) handleGetComments(w http.ResponseWriter, r *http.Request) {
+	session, err := f.getSession(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	comments, err := f.readComments(session)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-cache")
+	writeJson(w, comments)
+}
+
+// handleCreateComment adds a comment from a multipart form with the fields:
+//   - time: media time in seconds.
+//   - text: the note, optional if audio is present.
+//   - audio: optional recording.
+func (f *FlipCam) handleCreateComment(w http.ResponseWriter, r *http.Request) {
+	session, err := f.getSession(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxCommentUpload)
+	err = r.ParseMultipartForm(1 << 20)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid comment: %v", err), http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	comment := Comment{
+		Id:        rand.Text()[:10],
+		Text:      strings.TrimSpace(r.FormValue("text")),
+		CreatedAt: time.Now(),
+	}
+	comment.Time, err = strconv.ParseFloat(r.FormValue("time"), 64)
+	if err != nil || comment.Time < 0 {
+		http.Error(w, "time must be a positive number of seconds", http.StatusBadRequest)
+		return
+	}
+	if len(comment.Text) > 5000 {
+		http.Error(w, "text can be at most 5000 characters", http.StatusBadRequest)
+		return
+	}
+
+	audio, header, err := r.FormFile("audio")
+	switch {
+	case errors.Is(err, http.ErrMissingFile):
+		if comment.Text == "" {
+			http.Error(w, "a comment needs text or audio", http.StatusBadRequest)
+			return
+		}
+	case err != nil:
+		http.Error(w, fmt.Sprintf("invalid audio: %v", err), http.StatusBadRequest)
+		return
+	default:
+		defer audio.Close()
+		mediaType, _, _ := mime.ParseMediaType(header.Header.Get("Content-Type"))
+		extension, found := commentAudioTypes[mediaType]
+		if !found {
+			http.Error(w, fmt.Sprintf("unsupported audio type %q", mediaType), http.StatusBadRequest)
+			return
+		}
+
+		comment.AudioFile = comment.Id + extension
+		comment.AudioType = mediaType
+		comment.AudioUrl = fmt.Sprintf("/api/sessions/%s/comments/%s/audio", session.Id, comment.Id)
+		err = saveCommentAudio(session, comment.AudioFile, audio)
+		if err != nil {
+			log.Printf("web: comment: %v\n", err)
+			http.Error(w, "failed to save audio", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	err = f.store.Update(func(tx storage.Tx) error {
+		return tx.Put(storage.BucketComments, sessionKey(session, comment.Id), comment)
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJson(w, comment)
+}
+
+func saveCommentAudio(session Session, filename string, audio io.Reader) error {
+	dir := session.filePath(commentsDirSuffix)
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(path.Join(dir, filename))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, audio)
+	if err != nil {
+		_ = file.Close()
+		return errors.Join(err, os.Remove(file.Name()))
+	}
+
+	return file.Close()
+}
+
+// getComment returns the comment of the session with the given ID.
+func (f *FlipCam) getComment(session Session, id string) (Comment, error) {
+	var comment Comment
+	err := f.store.View(func(tx storage.Tx) error {
+		return tx.Get(storage.BucketComments, sessionKey(session, id), &comment)
+	})
+	if err != nil {
+		return Comment{}, err
+	}
+
+	return comment, nil
+}
+
+func (f *FlipCam) handleCommentAudio(w http.ResponseWriter, r *http.Request) {
+	session, err := f.getSession(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	comment, err := f.getComment(session, r.PathValue("commentId"))
+	if err != nil || comment.AudioFile == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "max-age=3600")
+	w.Header().Set("Content-Type", comment.AudioType)
+	http.ServeFile(w, r, path.Join(session.filePath(commentsDirSuffix), comment.AudioFile))
+}
+
+func (f *FlipCam) handleDeleteComment(w http.ResponseWriter, r *http.Request) {
+	session, err := f.getSession(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	var comment Comment
+	err = f.store.Update(func(tx storage.Tx) error {
+		key := sessionKey(session, r.PathValue("commentId"))
+		err := tx.Get(storage.BucketComments, key, &comment)
+		if err != nil {
+			return err
+		}
+
+		return tx.Delete(storage.BucketComments, key)
+	})
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if comment.AudioFile != "" {
+		err = os.Remove(path.Join(session.filePath(commentsDirSuffix), comment.AudioFile))
+		if err != nil {
+			log.Printf("web: failed to remove audio of comment %s: %v\n", comment.Id, err)
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
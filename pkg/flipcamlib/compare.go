@@ -0,0 +1,266 @@
+package flipcamlib
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-json-experiment/json"
+)
+
+// CompareMode determines how the two clips of a comparison are combined.
+type CompareMode string
+
+const (
+	CompareSideBySide CompareMode = "side-by-side"
+	CompareOverlay    CompareMode = "overlay"
+)
+
+// CompareClip is one of the clips of a comparison.
+type CompareClip struct {
+	ClipRange
+
+	// Align is the media time, in seconds, at which the clips are synchronized, e.g. the
+	// take-off of a jump. It must be within the clip.
+	Align float64 `json:"align"`
+}
+
+func (c CompareClip) Validate() error {
+	err := c.ClipRange.Validate()
+	if err != nil {
+		return err
+	}
+
+	if c.Align < c.Start || c.Align > c.End {
+		return fmt.Errorf("alignment point must be between the start and end of the clip")
+	}
+
+	return nil
+}
+
+// CompareRequest asks to render two clips, synchronized on their alignment point, to one video.
+type CompareRequest struct {
+	A    CompareClip `json:"a"`
+	B    CompareClip `json:"b"`
+	Mode CompareMode `json:"mode"`
+
+	// Opacity of clip B when it is overlaid on clip A, from 0 to 1. Defaults to 0.5.
+	Opacity float64 `json:"opacity"`
+
+	// Height of the video in pixels. Defaults to 720.
+	Height int `json:"height"`
+}
+
+func (c *CompareRequest) Validate() error {
+	err := c.A.Validate()
+	if err != nil {
+		return fmt.Errorf("clip A: %w", err)
+	}
+	err = c.B.Validate()
+	if err != nil {
+		return fmt.Errorf("clip B: %w", err)
+	}
+
+	switch c.Mode {
+	case CompareSideBySide, CompareOverlay:
+	default:
+		return fmt.Errorf("mode must be %s or %s", CompareSideBySide, CompareOverlay)
+	}
+
+	if c.Opacity == 0 {
+		c.Opacity = 0.5
+	}
+	if c.Opacity < 0 || c.Opacity > 1 {
+		return fmt.Errorf("opacity must be between 0 and 1")
+	}
+
+	if c.Height == 0 {
+		c.Height = 720
+	}
+	if c.Height < 16 || c.Height > 2160 {
+		return fmt.Errorf("height must be between 16 and 2160")
+	}
+
+	return nil
+}
+
+// lead returns the time between the start of the comparison and the alignment point.
+func (c CompareRequest) lead() float64 {
+	return max(c.A.Align-c.A.Start, c.B.Align-c.B.Start)
+}
+
+// tail returns the time between the alignment point and the end of the comparison.
+func (c CompareRequest) tail() float64 {
+	return max(c.A.End-c.A.Align, c.B.End-c.B.Align)
+}
+
+// clipFilter returns the filters of a clip that is offset seconds into input and outputs the
+// out stream. The clip is padded by repeating its first and last frame so that both clips have
+// the same duration and reach the alignment point at the same time.
+func (c CompareRequest) clipFilter(
+	clip CompareClip,
+	input int,
+	offset time.Duration,
+	annotations *annotationLayer,
+	firstAnnotationInput int,
+	out string,
+) string {
+	var scale string
+	if c.Mode == CompareOverlay {
+		width := c.Height * 16 / 9 / 2 * 2
+		scale = fmt.Sprintf(
+			"scale=%[1]d:%[2]d:force_original_aspect_ratio=decrease,pad=%[1]d:%[2]d:(ow-iw)/2:(oh-ih)/2",
+			width,
+			c.Height,
+		)
+	} else {
+		scale = fmt.Sprintf("scale=-2:%d", c.Height)
+	}
+
+	return fmt.Sprintf(
+		"[%[1]d:v]trim=start=%[2]s:duration=%[3]s,setpts=PTS-STARTPTS[%[7]s_clip];"+
+			"%[8]s;"+
+			"[%[7]s_annotated]tpad=start_duration=%[4]s:start_mode=clone:"+
+			"stop_duration=%[5]s:stop_mode=clone,%[6]s,setsar=1[%[7]s]",
+		input,
+		formatFilterFloat(offset.Seconds()),
+		formatFilterFloat(clip.duration().Seconds()),
+		formatFilterFloat(c.lead()-(clip.Align-clip.Start)),
+		formatFilterFloat(c.tail()-(clip.End-clip.Align)),
+		scale,
+		out,
+		annotations.filter(out+"_clip", out+"_annotated", firstAnnotationInput),
+	)
+}
+
+func (c CompareRequest) filterGraph(
+	offsetA time.Duration,
+	offsetB time.Duration,
+	annotationsA *annotationLayer,
+	annotationsB *annotationLayer,
+) string {
+	// Inputs 0 and 1 are the clips, followed by the annotation images of A and then B
+	graph := c.clipFilter(c.A, 0, offsetA, annotationsA, 2, "a") + ";" +
+		c.clipFilter(c.B, 1, offsetB, annotationsB, 2+len(annotationsA.images), "b") + ";"
+	if c.Mode == CompareOverlay {
+		graph += fmt.Sprintf(
+			"[b]format=yuva420p,colorchannelmixer=aa=%s[bt];[a][bt]overlay",
+			formatFilterFloat(c.Opacity),
+		)
+	} else {
+		graph += "[a][b]hstack=inputs=2"
+	}
+
+	return graph + ",format=yuv420p[out]"
+}
+
+func (f *FlipCam) handleCreateCompare(w http.ResponseWriter, r *http.Request) {
+	var req CompareRequest
+	err := json.UnmarshalRead(r.Body, &req)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid comparison: %v", err), http.StatusBadRequest)
+		return
+	}
+	err = req.Validate()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, clip := range []CompareClip{req.A, req.B} {
+		if _, err := f.getSession(clip.SessionId); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+	}
+
+	filename := fmt.Sprintf("flipcam_%s_%s_%s.mp4", req.A.SessionId, req.B.SessionId, req.Mode)
+	job, err := f.queueJob("compare", filename, "video/mp4", func(ctx context.Context, resultPath string) error {
+		return f.renderCompare(ctx, req, resultPath)
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	writeJson(w, job)
+}
+
+func (f *FlipCam) renderCompare(ctx context.Context, req CompareRequest, resultPath string) error {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Minute)
+	defer cancel()
+
+	inputA, offsetA, err := f.openClip(req.A.ClipRange)
+	if err != nil {
+		return fmt.Errorf("clip A: %w", err)
+	}
+	defer inputA.Close()
+
+	inputB, offsetB, err := f.openClip(req.B.ClipRange)
+	if err != nil {
+		return fmt.Errorf("clip B: %w", err)
+	}
+	defer inputB.Close()
+
+	annotationsDir, err := os.MkdirTemp("", "flipcam-compare-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(annotationsDir)
+	annotationsA, err := f.newAnnotationLayer(req.A.ClipRange, annotationsDir)
+	if err != nil {
+		return fmt.Errorf("clip A: %w", err)
+	}
+	annotationsB, err := f.newAnnotationLayer(req.B.ClipRange, annotationsDir)
+	if err != nil {
+		return fmt.Errorf("clip B: %w", err)
+	}
+
+	args := []string{
+		"-f", "mov",
+		"-i", "pipe:0",
+		"-f", "mov",
+		"-i", "pipe:3",
+	}
+	args = append(args, annotationsA.inputArgs()...)
+	args = append(args, annotationsB.inputArgs()...)
+	_, err = runFfmpegWithInputs(ctx, true, []io.Reader{inputA, inputB}, append(args,
+		"-filter_complex", req.filterGraph(offsetA, offsetB, annotationsA, annotationsB),
+		"-map", "[out]",
+		"-t", formatFilterFloat(req.lead()+req.tail()),
+		"-an",
+		"-c:v", "libx264",
+		"-preset", "veryfast",
+		"-crf", "20",
+		"-movflags", "+faststart",
+		"-f", "mp4",
+		"-y",
+		resultPath,
+	)...)
+
+	return err
+}
+
+func (f *FlipCam) handleComparePage(w http.ResponseWriter, r *http.Request) {
+	clips, err := f.getClips()
+	if err != nil {
+		log.Printf("web: compare: %v\n", err)
+		http.Error(w, "failed to read clips", http.StatusInternalServerError)
+		return
+	}
+
+	err = Compare(clips, f.hlsUrlPathPrefix).Render(r.Context(), w)
+	if err != nil {
+		log.Printf("web: failed to render: %v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// formatClipTime formats a media time in seconds for use in HTML attributes.
+func formatClipTime(t float64) string {
+	return strconv.FormatFloat(t, 'f', 3, 64)
+}
@@ -0,0 +1,265 @@
+package flipcamlib
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/go-json-experiment/json"
+)
+
+// CompositeRequest asks for a stroboscopic motion composite (chronophotography) of a clip.
+// Frames are taken every Interval seconds and blended into a single image that shows the
+// athlete at every position along the trajectory.
+type CompositeRequest struct {
+	ClipRange
+
+	// Interval is the time between the frames in seconds.
+	Interval float64 `json:"interval"`
+
+	// Threshold is the average difference per color channel, from 1 to 255, above which a
+	// pixel is considered to be part of the moving athlete instead of the background.
+	// Defaults to 40.
+	Threshold int `json:"threshold"`
+
+	// Width of the composite in pixels. Defaults to 960.
+	Width int `json:"width"`
+}
+
+const maxCompositeFrames = 30
+
+// compositeTimeout limits how long a composite may take to prevent a stuck ffmpeg from blocking
+// the job queue.
+const compositeTimeout = 5 * time.Minute
+
+func (c *CompositeRequest) Validate() error {
+	err := c.ClipRange.Validate()
+	if err != nil {
+		return err
+	}
+
+	if c.Interval <= 0 {
+		return fmt.Errorf("interval must be larger than zero")
+	}
+
+	frames := int((c.End-c.Start)/c.Interval) + 1
+	if frames < 2 || frames > maxCompositeFrames {
+		return fmt.Errorf(
+			"the clip and interval result in %d frames, must be between 2 and %d",
+			frames,
+			maxCompositeFrames,
+		)
+	}
+
+	if c.Threshold == 0 {
+		c.Threshold = 40
+	}
+	if c.Threshold < 1 || c.Threshold > 255 {
+		return fmt.Errorf("threshold must be between 1 and 255")
+	}
+
+	if c.Width == 0 {
+		c.Width = 960
+	}
+	if c.Width < 16 || c.Width > 3840 {
+		return fmt.Errorf("width must be between 16 and 3840")
+	}
+
+	return nil
+}
+
+func (f *FlipCam) handleCreateComposite(w http.ResponseWriter, r *http.Request) {
+	var req CompositeRequest
+	err := json.UnmarshalRead(r.Body, &req)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid composite request: %v", err), http.StatusBadRequest)
+		return
+	}
+	err = req.Validate()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := f.getSession(req.SessionId); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	filename := fmt.Sprintf("flipcam_%s_%.1f-%.1f_composite.png", req.SessionId, req.Start, req.End)
+	job, err := f.queueJob("composite", filename, "image/png", func(ctx context.Context, resultPath string) error {
+		return f.renderComposite(ctx, req, resultPath)
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	writeJson(w, job)
+}
+
+func (f *FlipCam) renderComposite(ctx context.Context, req CompositeRequest, resultPath string) error {
+	ctx, cancel := context.WithTimeout(ctx, compositeTimeout)
+	defer cancel()
+
+	frames, err := f.extractCompositeFrames(ctx, req)
+	if err != nil {
+		return err
+	}
+	if len(frames) < 2 {
+		return fmt.Errorf("need at least two frames, got %d", len(frames))
+	}
+
+	composite := compositeFrames(frames, req.Threshold)
+
+	var b bytes.Buffer
+	err = png.Encode(&b, composite)
+	if err != nil {
+		return fmt.Errorf("failed to encode composite: %w", err)
+	}
+
+	return os.WriteFile(resultPath, b.Bytes(), 0o644)
+}
+
+// extractCompositeFrames decodes a frame every interval of the clip.
+func (f *FlipCam) extractCompositeFrames(ctx context.Context, req CompositeRequest) ([]*image.RGBA, error) {
+	input, offset, err := f.openClip(req.ClipRange)
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	filter := fmt.Sprintf(
+		"trim=start=%[1]s:duration=%[2]s,setpts=PTS-STARTPTS,"+
+			"select='isnan(prev_selected_t)+gte(t-prev_selected_t,%[3]s)',scale=%[4]d:-2",
+		formatFilterFloat(offset.Seconds()),
+		formatFilterFloat(req.ClipRange.duration().Seconds()),
+		formatFilterFloat(req.Interval),
+		req.Width,
+	)
+	output, err := runFfmpegLowPriority(ctx, input,
+		"-f", "mov",
+		"-i", "pipe:0",
+		"-vf", filter,
+		"-fps_mode", "passthrough",
+		"-frames:v", fmt.Sprint(maxCompositeFrames),
+		"-f", "image2pipe",
+		"-c:v", "png",
+		"pipe:1",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var frames []*image.RGBA
+	r := bytes.NewReader(output)
+	for r.Len() > 0 {
+		img, err := png.Decode(r)
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode frame %d: %w", len(frames), err)
+		}
+
+		rgba := image.NewRGBA(img.Bounds())
+		draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+		frames = append(frames, rgba)
+	}
+
+	return frames, nil
+}
+
+// compositeFrames blends the frames into one image.
+// The background is estimated with the per-pixel median of all frames. Pixels of a frame that
+// differ more than threshold from the background are considered to be the athlete and are
+// drawn on top of the background. Later frames are drawn over earlier frames.
+func compositeFrames(frames []*image.RGBA, threshold int) *image.RGBA {
+	bounds := frames[0].Bounds()
+	background := medianBackground(frames)
+	composite := image.NewRGBA(bounds)
+	copy(composite.Pix, background.Pix)
+
+	width := bounds.Dx()
+	height := bounds.Dy()
+	mask := make([]bool, width*height)
+	for _, frame := range frames {
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				i := frame.PixOffset(x+bounds.Min.X, y+bounds.Min.Y)
+				diff := absDiff(frame.Pix[i], background.Pix[i]) +
+					absDiff(frame.Pix[i+1], background.Pix[i+1]) +
+					absDiff(frame.Pix[i+2], background.Pix[i+2])
+				mask[y*width+x] = diff > 3*threshold
+			}
+		}
+
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				// Ignore isolated pixels, these are mostly noise and compression artifacts
+				if !mask[y*width+x] || countNeighbors(mask, width, height, x, y) < 4 {
+					continue
+				}
+				i := frame.PixOffset(x+bounds.Min.X, y+bounds.Min.Y)
+				copy(composite.Pix[i:i+4], frame.Pix[i:i+4])
+			}
+		}
+	}
+
+	return composite
+}
+
+func medianBackground(frames []*image.RGBA) *image.RGBA {
+	bounds := frames[0].Bounds()
+	background := image.NewRGBA(bounds)
+	values := make([]uint8, len(frames))
+	for i := range background.Pix {
+		if i%4 == 3 {
+			background.Pix[i] = math.MaxUint8
+			continue
+		}
+		for j, frame := range frames {
+			values[j] = frame.Pix[i]
+		}
+		slices.Sort(values)
+		background.Pix[i] = values[len(values)/2]
+	}
+
+	return background
+}
+
+// countNeighbors returns the number of the 8 surrounding pixels that are set in the mask.
+func countNeighbors(mask []bool, width int, height int, x int, y int) int {
+	count := 0
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			nx := x + dx
+			ny := y + dy
+			if (dx == 0 && dy == 0) || nx < 0 || ny < 0 || nx >= width || ny >= height {
+				continue
+			}
+			if mask[ny*width+nx] {
+				count++
+			}
+		}
+	}
+
+	return count
+}
+
+func absDiff(a uint8, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+
+	return int(b - a)
+}
@@ -0,0 +1,244 @@
+package flipcamlib
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"os"
+	"time"
+)
+
+// dashManifestSuffix is appended to the session ID to get the DASH manifest filename.
+const dashManifestSuffix = ".mpd"
+
+// dashWriter writes a DASH manifest of a session while it is being recorded. The manifest
+// references the fMP4 segments of the HLS playlist, which are CMAF compatible, so no media is
+// duplicated.
+type dashWriter struct {
+	session Session
+
+	init     *fmp4Init
+	mapUri   string
+	timeline []dashSegment
+
+	// availabilityStart is the wall-clock time of presentation time zero.
+	availabilityStart time.Time
+
+	// startNumber is the number of the first segment in the segment filenames.
+	startNumber int
+}
+
+// dashSegment is a segment of the timeline, times are in the timescale of the track.
+type dashSegment struct {
+	start    int64
+	duration int64
+}
+
+func newDashWriter(session Session) *dashWriter {
+	return &dashWriter{session: session}
+}
+
+func (d *dashWriter) processSegment(playlist *MediaPlaylist, segment MediaSegment) error {
+	if d.init == nil {
+		initData, err := os.ReadFile(d.session.uriPath(playlist.MapUri))
+		if err != nil {
+			return fmt.Errorf("failed to read initialization segment: %w", err)
+		}
+
+		init, err := parseFmp4Init(initData)
+		if err != nil {
+			return fmt.Errorf("failed to parse initialization segment: %w", err)
+		}
+		d.init = &init
+		d.mapUri = playlist.MapUri
+		d.startNumber = segment.Sequence
+	}
+
+	// The segments are referenced by a template, which only works if the muxer names them
+	// by their sequence number
+	if segment.Uri != d.segmentUri(segment.Sequence) {
+		return fmt.Errorf("segment %s does not match template %s", segment.Uri, d.mediaTemplate())
+	}
+
+	data, err := os.ReadFile(d.session.uriPath(segment.Uri))
+	if err != nil {
+		return fmt.Errorf("failed to read segment: %w", err)
+	}
+
+	samples, err := parseFmp4Segment(data, *d.init)
+	if err != nil {
+		return fmt.Errorf("failed to parse segment %s: %w", segment.Uri, err)
+	}
+	if len(samples) == 0 {
+		return fmt.Errorf("segment %s has no video samples", segment.Uri)
+	}
+
+	start, end := int64(math.MaxInt64), int64(math.MinInt64)
+	for _, sample := range samples {
+		start = min(start, sample.PresentationTime())
+		end = max(end, sample.PresentationTime()+int64(sample.Duration))
+	}
+	if len(d.timeline) > 0 {
+		// The timeline must not have gaps or overlaps
+		previous := &d.timeline[len(d.timeline)-1]
+		previous.duration = start - previous.start
+	}
+	d.timeline = append(d.timeline, dashSegment{start: start, duration: end - start})
+
+	if d.availabilityStart.IsZero() && !segment.ProgramDateTime.IsZero() {
+		d.availabilityStart = segment.ProgramDateTime.Add(-d.duration(start))
+	}
+
+	return d.write(false)
+}
+
+func (d *dashWriter) finish(*MediaPlaylist) error {
+	if d.init == nil {
+		return nil
+	}
+
+	return d.write(true)
+}
+
+func (d *dashWriter) segmentUri(number int) string {
+	return fmt.Sprintf("%s_%d.mp4", d.session.Id, number)
+}
+
+func (d *dashWriter) mediaTemplate() string {
+	return d.session.Id + "_$Number$.mp4"
+}
+
+// duration converts a duration in the timescale of the track to time.Duration.
+func (d *dashWriter) duration(t int64) time.Duration {
+	return time.Duration(t) * time.Second / time.Duration(d.init.Timescale)
+}
+
+type dashMpd struct {
+	XMLName                   xml.Name   `xml:"MPD"`
+	Xmlns                     string     `xml:"xmlns,attr"`
+	Profiles                  string     `xml:"profiles,attr"`
+	Type                      string     `xml:"type,attr"`
+	AvailabilityStartTime     string     `xml:"availabilityStartTime,attr,omitempty"`
+	PublishTime               string     `xml:"publishTime,attr,omitempty"`
+	MediaPresentationDuration string     `xml:"mediaPresentationDuration,attr,omitempty"`
+	MinimumUpdatePeriod       string     `xml:"minimumUpdatePeriod,attr,omitempty"`
+	MinBufferTime             string     `xml:"minBufferTime,attr"`
+	Period                    dashPeriod `xml:"Period"`
+}
+
+type dashPeriod struct {
+	Id            string            `xml:"id,attr"`
+	Start         string            `xml:"start,attr"`
+	AdaptationSet dashAdaptationSet `xml:"AdaptationSet"`
+}
+
+type dashAdaptationSet struct {
+	ContentType      string             `xml:"contentType,attr"`
+	MimeType         string             `xml:"mimeType,attr"`
+	SegmentAlignment bool               `xml:"segmentAlignment,attr"`
+	StartWithSap     int                `xml:"startWithSAP,attr"`
+	SegmentTemplate  dashTemplate       `xml:"SegmentTemplate"`
+	Representation   dashRepresentation `xml:"Representation"`
+}
+
+type dashTemplate struct {
+	Timescale       uint32          `xml:"timescale,attr"`
+	Initialization  string          `xml:"initialization,attr"`
+	Media           string          `xml:"media,attr"`
+	StartNumber     int             `xml:"startNumber,attr"`
+	SegmentTimeline []dashTimelineS `xml:"SegmentTimeline>S"`
+}
+
+type dashTimelineS struct {
+	T int64 `xml:"t,attr,omitempty"`
+	D int64 `xml:"d,attr"`
+	R int   `xml:"r,attr,omitempty"`
+}
+
+type dashRepresentation struct {
+	Id        string `xml:"id,attr"`
+	Codecs    string `xml:"codecs,attr"`
+	Bandwidth int    `xml:"bandwidth,attr"`
+	Width     int    `xml:"width,attr,omitempty"`
+	Height    int    `xml:"height,attr,omitempty"`
+}
+
+// dashBandwidth is the bandwidth, in bits per second, advertised for the single representation.
+// Players use it to choose between representations, of which there is only one.
+const dashBandwidth = 5_000_000
+
+// write writes the manifest. A session that is still being recorded has a dynamic manifest that
+// players reload, ended sessions get a static manifest.
+func (d *dashWriter) write(ended bool) error {
+	mpd := dashMpd{
+		Xmlns:         "urn:mpeg:dash:schema:mpd:2011",
+		Profiles:      "urn:mpeg:dash:profile:isoff-live:2011",
+		Type:          "dynamic",
+		MinBufferTime: formatXsdDuration(2 * time.Second),
+		Period: dashPeriod{
+			Id:    "0",
+			Start: formatXsdDuration(0),
+			AdaptationSet: dashAdaptationSet{
+				ContentType:      "video",
+				MimeType:         "video/mp4",
+				SegmentAlignment: true,
+				StartWithSap:     1,
+				SegmentTemplate: dashTemplate{
+					Timescale:      d.init.Timescale,
+					Initialization: d.mapUri,
+					Media:          d.mediaTemplate(),
+					StartNumber:    d.startNumber,
+				},
+				Representation: dashRepresentation{
+					Id:        "video",
+					Codecs:    d.init.Codecs,
+					Bandwidth: dashBandwidth,
+					Width:     d.init.Width,
+					Height:    d.init.Height,
+				},
+			},
+		},
+	}
+	if !d.availabilityStart.IsZero() {
+		mpd.AvailabilityStartTime = d.availabilityStart.UTC().Format(time.RFC3339Nano)
+	}
+	if ended {
+		last := d.timeline[len(d.timeline)-1]
+		mpd.Type = "static"
+		mpd.MediaPresentationDuration = formatXsdDuration(d.duration(last.start + last.duration))
+	} else {
+		mpd.PublishTime = time.Now().UTC().Format(time.RFC3339Nano)
+		mpd.MinimumUpdatePeriod = formatXsdDuration(time.Second)
+	}
+
+	// Consecutive segments of equal duration are combined using the repeat count
+	timeline := &mpd.Period.AdaptationSet.SegmentTemplate.SegmentTimeline
+	for i, segment := range d.timeline {
+		if n := len(*timeline); n > 0 && (*timeline)[n-1].D == segment.duration {
+			(*timeline)[n-1].R++
+			continue
+		}
+
+		s := dashTimelineS{D: segment.duration}
+		if i == 0 {
+			s.T = segment.start
+		}
+		*timeline = append(*timeline, s)
+	}
+
+	data, err := xml.MarshalIndent(mpd, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(
+		d.session.filePath(dashManifestSuffix),
+		append([]byte(xml.Header), data...),
+		0o644,
+	)
+}
+
+// formatXsdDuration formats a duration as an xs:duration, e.g. PT1.5S.
+func formatXsdDuration(d time.Duration) string {
+	return "PT" + formatSeconds(d) + "S"
+}
@@ -0,0 +1,135 @@
+package flipcamlib
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// delayedPathSegment is the directory, below the path prefix of a camera, from which delayed
+	// playlists are served.
+	delayedPathSegment = "delayed"
+
+	// delayedPlaylistWindow is the duration of the segments listed in a delayed playlist.
+	delayedPlaylistWindow = 30 * time.Second
+
+	// maxPlaylistDelay limits the delay of a delayed playlist.
+	maxPlaylistDelay = time.Hour
+)
+
+// handleDelayedPlaylist serves a live playlist of the current session of the camera that ends
+// the requested number of seconds behind the head of the session, e.g.
+// /camera/main/delayed/10.m3u8. Any HLS player can show the delayed video without custom client
+// code. Note that players add their own buffer of a few segments on top of the delay.
+// Without a camera in the path, e.g. /camera/delayed/10.m3u8, the first camera is used.
+func (f *FlipCam) handleDelayedPlaylist(w http.ResponseWriter, r *http.Request) {
+	c := f.cameras[0]
+	if name := r.PathValue("camera"); name != "" {
+		var err error
+		c, err = f.getCamera(name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+	}
+
+	secondsStr, found := strings.CutSuffix(r.PathValue("file"), ".m3u8")
+	seconds, err := strconv.ParseFloat(secondsStr, 64)
+	delay := time.Duration(seconds * float64(time.Second))
+	if !found || err != nil || delay < 0 || delay > maxPlaylistDelay {
+		http.Error(
+			w,
+			fmt.Sprintf("the delay must be between 0 and %.0f seconds", maxPlaylistDelay.Seconds()),
+			http.StatusBadRequest,
+		)
+		return
+	}
+
+	session, err := f.currentSession(c)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	playlist, err := session.readPlaylist()
+	if err != nil {
+		log.Printf("web: delayed playlist: %v\n", err)
+		http.Error(w, "failed to read playlist", http.StatusInternalServerError)
+		return
+	}
+
+	segments := delayedSegments(playlist, delay)
+	if len(segments) == 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(max(1, int(delay.Seconds()))))
+		http.Error(
+			w,
+			fmt.Sprintf("less than %s of video has been recorded", delay),
+			http.StatusServiceUnavailable,
+		)
+		return
+	}
+
+	ended := playlist.Ended && segments[len(segments)-1].Sequence ==
+		playlist.Segments[len(playlist.Segments)-1].Sequence
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	_, err = w.Write(renderDelayedPlaylist(playlist, segments, ended))
+	if err != nil {
+		log.Printf("web: delayed playlist: failed to write: %v\n", err)
+	}
+}
+
+// delayedSegments returns the segments of the sliding window that ends delay behind the end of
+// the playlist.
+func delayedSegments(playlist *MediaPlaylist, delay time.Duration) []MediaSegment {
+	if len(playlist.Segments) == 0 {
+		return nil
+	}
+	last := playlist.Segments[len(playlist.Segments)-1]
+	cutoff := last.MediaTime + last.Duration - delay
+
+	end := len(playlist.Segments)
+	for end > 0 && playlist.Segments[end-1].MediaTime+playlist.Segments[end-1].Duration > cutoff {
+		end--
+	}
+	start := end
+	for start > 0 && playlist.Segments[start-1].MediaTime >= cutoff-delayedPlaylistWindow {
+		start--
+	}
+
+	return playlist.Segments[start:end]
+}
+
+// renderDelayedPlaylist renders a media playlist of the segments. The playlist is served from
+// the delayed directory next to the session playlist, so URIs are made relative to it.
+// The media sequence numbers are those of the session playlist so that they stay consistent
+// while the window slides.
+func renderDelayedPlaylist(playlist *MediaPlaylist, segments []MediaSegment, ended bool) []byte {
+	var b bytes.Buffer
+	b.WriteString("#EXTM3U\n")
+	b.WriteString("#EXT-X-VERSION:7\n")
+	fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", playlist.TargetDuration)
+	fmt.Fprintf(&b, "#EXT-X-MEDIA-SEQUENCE:%d\n", segments[0].Sequence)
+	fmt.Fprintf(&b, "#EXT-X-MAP:URI=%q\n", "../"+playlist.MapUri)
+	for _, segment := range segments {
+		if !segment.ProgramDateTime.IsZero() {
+			fmt.Fprintf(&b, "#EXT-X-PROGRAM-DATE-TIME:%s\n", formatProgramDateTime(segment.ProgramDateTime))
+		}
+		fmt.Fprintf(&b, "#EXTINF:%s,\n", formatSeconds(segment.Duration))
+		if segment.ByteRangeLength > 0 {
+			fmt.Fprintf(&b, "#EXT-X-BYTERANGE:%d@%d\n", segment.ByteRangeLength, segment.ByteRangeOffset)
+		}
+		b.WriteString("../")
+		b.WriteString(segment.Uri)
+		b.WriteString("\n")
+	}
+	if ended {
+		b.WriteString("#EXT-X-ENDLIST\n")
+	}
+
+	return b.Bytes()
+}
@@ -0,0 +1,37 @@
+package flipcamlib
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandleDelayedPlaylistStatus(t *testing.T) {
+	f := New(Opts{Cameras: []string{"main"}, HlsOutputDir: t.TempDir()})
+
+	tests := []struct {
+		camera     string
+		file       string
+		wantStatus int
+	}{
+		{file: "ten.m3u8", wantStatus: http.StatusBadRequest},
+		{file: "-5.m3u8", wantStatus: http.StatusBadRequest},
+		{file: "7200.m3u8", wantStatus: http.StatusBadRequest},
+		{file: "10.ts", wantStatus: http.StatusBadRequest},
+		{camera: "other", file: "10.m3u8", wantStatus: http.StatusNotFound},
+		{camera: "main", file: "10.m3u8", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.SetPathValue("camera", tt.camera)
+		r.SetPathValue("file", tt.file)
+		w := httptest.NewRecorder()
+		f.handleDelayedPlaylist(w, r)
+		if w.Code != tt.wantStatus {
+			t.Errorf(
+				"camera %q, file %q: status = %d, want %d",
+				tt.camera, tt.file, w.Code, tt.wantStatus,
+			)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package flipcamlib
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// runFfmpeg runs a short-lived ffmpeg process and returns what it wrote to stdout.
+// stdin is optional and is passed to ffmpeg's standard input.
+func runFfmpeg(ctx context.Context, stdin io.Reader, args ...string) ([]byte, error) {
+	return runFfmpegWithPriority(ctx, false, stdin, args...)
+}
+
+// runFfmpegLowPriority is runFfmpeg but with the lowest CPU and IO priority so that the process
+// does not compete with the muxer.
+func runFfmpegLowPriority(ctx context.Context, stdin io.Reader, args ...string) ([]byte, error) {
+	return runFfmpegWithPriority(ctx, true, stdin, args...)
+}
+
+const (
+	ioprioWhoProcess = 1
+	ioprioClassIdle  = 3
+	ioprioClassShift = 13
+)
+
+func runFfmpegWithPriority(
+	ctx context.Context,
+	lowPriority bool,
+	stdin io.Reader,
+	args ...string,
+) ([]byte, error) {
+	return runFfmpegWithInputs(ctx, lowPriority, []io.Reader{stdin}, args...)
+}
+
+// runFfmpegWithInputs is runFfmpegWithPriority with multiple inputs. inputs[0] is passed as
+// stdin, pipe:0, and every following input is passed as an additional file descriptor starting
+// at pipe:3.
+func runFfmpegWithInputs(
+	ctx context.Context,
+	lowPriority bool,
+	inputs []io.Reader,
+	args ...string,
+) ([]byte, error) {
+	cmd := exec.CommandContext(
+		ctx,
+		"ffmpeg",
+		append([]string{"-hide_banner", "-nostats", "-loglevel", "error"}, args...)...,
+	)
+	if len(inputs) > 0 {
+		cmd.Stdin = inputs[0]
+	}
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	var copyWg sync.WaitGroup
+	defer copyWg.Wait()
+	for _, input := range inputs[min(len(inputs), 1):] {
+		pr, pw, err := os.Pipe()
+		if err != nil {
+			return nil, fmt.Errorf("ffmpeg: failed to create pipe: %w", err)
+		}
+		defer pr.Close()
+		cmd.ExtraFiles = append(cmd.ExtraFiles, pr)
+
+		copyWg.Add(1)
+		go func() {
+			defer copyWg.Done()
+			defer pw.Close()
+			// An error means that ffmpeg stopped reading, which is reported by Wait
+			_, _ = io.Copy(pw, input)
+		}()
+	}
+
+	err := cmd.Start()
+	for _, pr := range cmd.ExtraFiles {
+		// Only the child must hold the read end so that the copy fails when ffmpeg exits
+		_ = pr.Close()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg: failed to start: %w", err)
+	}
+
+	if lowPriority {
+		pid := cmd.Process.Pid
+		err = syscall.Setpriority(syscall.PRIO_PROCESS, pid, 19)
+		if err != nil {
+			log.Printf("ffmpeg: failed to lower CPU priority: %v\n", err)
+		}
+		_, _, errno := syscall.Syscall(
+			syscall.SYS_IOPRIO_SET,
+			ioprioWhoProcess,
+			uintptr(pid),
+			ioprioClassIdle<<ioprioClassShift,
+		)
+		if errno != 0 {
+			log.Printf("ffmpeg: failed to lower IO priority: %v\n", errno)
+		}
+	}
+
+	err = cmd.Wait()
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// ImageFormat is the format of an image produced from the video.
+type ImageFormat string
+
+const (
+	ImageJpeg ImageFormat = "jpeg"
+	ImagePng  ImageFormat = "png"
+)
+
+func ParseImageFormat(v string) (ImageFormat, error) {
+	switch format := ImageFormat(v); format {
+	case ImageJpeg, ImagePng:
+		return format, nil
+	case "jpg", "":
+		return ImageJpeg, nil
+	default:
+		return "", fmt.Errorf("unknown image format %q, must be jpeg or png", v)
+	}
+}
+
+func (i ImageFormat) ContentType() string {
+	return "image/" + string(i)
+}
+
+func (i ImageFormat) codec() string {
+	if i == ImagePng {
+		return "png"
+	}
+
+	return "mjpeg"
+}
+
+// extractFrame decodes the frame at offset from the fragmented MP4 stream in input.
+// If width is larger than zero, the frame is scaled to that width keeping the aspect ratio.
+func extractFrame(
+	ctx context.Context,
+	input io.Reader,
+	offset time.Duration,
+	width int,
+	format ImageFormat,
+) ([]byte, error) {
+	frame, err := runFfmpeg(ctx, input, extractFrameArgs(offset, width, format)...)
+	return checkFrame(frame, offset, err)
+}
+
+// extractFrameLowPriority is extractFrame using runFfmpegLowPriority.
+func extractFrameLowPriority(
+	ctx context.Context,
+	input io.Reader,
+	offset time.Duration,
+	width int,
+	format ImageFormat,
+) ([]byte, error) {
+	frame, err := runFfmpegLowPriority(ctx, input, extractFrameArgs(offset, width, format)...)
+	return checkFrame(frame, offset, err)
+}
+
+func checkFrame(frame []byte, offset time.Duration, err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	if len(frame) == 0 {
+		return nil, fmt.Errorf("no frame found at %s", offset)
+	}
+
+	return frame, nil
+}
+
+func extractFrameArgs(offset time.Duration, width int, format ImageFormat) []string {
+	args := []string{
+		"-f", "mov",
+		"-i", "pipe:0",
+		// Input is not seekable, seek by decoding and discarding
+		"-ss", strconv.FormatFloat(offset.Seconds(), 'f', 3, 64),
+		"-frames:v", "1",
+	}
+	if width > 0 {
+		args = append(args, "-vf", fmt.Sprintf("scale=%d:-2", width))
+	}
+	args = append(args,
+		"-f", "image2pipe",
+		"-c:v", format.codec(),
+	)
+	if format == ImageJpeg {
+		args = append(args, "-q:v", "4")
+	}
+	return append(args, "pipe:1")
+}
@@ -2,10 +2,17 @@ package flipcamlib
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/tls"
 	"errors"
+	"fmt"
 	"github.com/MatthiasKunnen/chanwg"
+	"github.com/MatthiasKunnen/flipcam/pkg/flipcamlib/storage"
+	"net"
+	"net/http"
 	"net/netip"
 	"sync"
+	"time"
 )
 
 const defaultServiceNameCaddy = "flipcam-caddy.service"
@@ -13,35 +20,109 @@ const defaultServiceNameDnsmasq = "flipcam-dnsmasq.service"
 const defaultServiceNameHostapd = "flipcam-hostapd.service"
 
 type Opts struct {
+	// Cameras are the names of the cameras. Every camera publishes to RTMP port 1935, with its
+	// stream key or, without keys, its name as stream name, e.g. rtmp://<router>:1935/camera/side.
+	// Each camera has its own muxer and playlists below HlsUrlPathPrefix/name. Defaults to one
+	// camera named DefaultCameraName, which accepts any stream name if it has no key.
+	Cameras []string
+
+	// CaddyRootCert is the PEM file of the root certificate of Caddy's local CA, offered to
+	// devices on the /trust page when Caddy terminates TLS. Defaults to /run/caddy/root.crt, to
+	// which the Caddy service copies it.
+	CaddyRootCert string
+
+	// DatabasePath is the file in which clips, annotations, comments, the roster, and settings are
+	// stored. It must not be in HlsOutputDir, which is served to the network. Defaults to
+	// DefaultDatabasePath. A database found in HlsOutputDir, where it used to be stored, is moved.
+	DatabasePath string
+
+	// CoachPin unlocks the coach role, which may control the recording and change or delete the
+	// stored data. At least 4 digits. If empty, a PIN is generated and logged on start.
+	CoachPin string
+
 	HlsOutputDir     string
 	HlsUrlPathPrefix string
 
+	// Hostname, if set, is included in the certificate of the web UI, see TlsDir.
+	Hostname string
+
 	RouterAddr netip.Prefix
 
 	ServiceNameCaddy   string
 	ServiceNameDnsmasq string
 	ServiceNameHostapd string
 
+	// ServeHls makes the web UI serve the files in HlsOutputDir itself, with the headers and
+	// encoding of the Caddy configuration, so that Caddy is not needed. The Caddy service is
+	// then not started or monitored, the UI and video are served on UiPort.
+	ServeHls bool
+
+	// StreamKeys maps the name of a camera to the stream names it may publish with, e.g.
+	// rtmp://<router>:1935/camera/<key>. The key selects the camera, publishers using another
+	// name are disconnected. If empty, any publisher is accepted. Otherwise, every camera needs a
+	// key of its own.
+	StreamKeys map[string][]string
+
+	// StaticDir, if set, is the directory from which the files of the UI are served instead of
+	// the files embedded in the binary. Changes show up without restarting, for development. Only
+	// the .css and .mjs files in the directory itself are served.
+	StaticDir string
+
+	// SntpAddr is the UDP address on which an SNTP server is run, e.g. :123. The SNTP server is
+	// disabled if empty. DHCP clients are told to sync their clock with port 123 of the router.
+	SntpAddr string
+
+	// ThumbnailInterval is the time between the thumbnails of the timeline preview. At least
+	// MinThumbnailInterval. Defaults to 5 seconds.
+	ThumbnailInterval time.Duration
+
+	// TranscodePolicy determines when the video is re-encoded to H.264. Defaults to
+	// TranscodeAuto.
+	TranscodePolicy TranscodePolicy
+
+	// OverlayFontFile is the font used to draw the overlay. If empty, ffmpeg picks a font
+	// using fontconfig.
+	OverlayFontFile string
+
+	// TlsDir, if set, makes the web UI serve HTTPS and HTTP/2. The certificate is issued on
+	// every start for the router IP and Hostname by a local CA. The CA is generated in TlsDir
+	// the first time and reused afterwards, so that devices only have to trust it once. Its
+	// root certificate is served at /ca.crt. Requires ServeHls.
+	TlsDir string
+
 	// The port on which the web UI will be bound. E.g. :3000.
 	UiPort string
 
+	// Listener, if set, is used by the web UI instead of listening on UiPort. Start takes
+	// ownership and closes it on shutdown.
+	Listener net.Listener
+
 	WirelessInterface string
 }
 
 type FlipCam struct {
+	cameras      []*camera
 	hlsOutputDir string
 	routerAddr   netip.Prefix
 
+	// Unlocking the coach role, see coach.go.
+	coachPin          string
+	coachPinGenerated bool
+	coachTokens       map[string]struct{}
+	coachTokensMu     sync.Mutex
+	pairingCode       string
+	pinAttemptMu      sync.Mutex
+
 	serviceNameCaddy   string
 	serviceNameDnsmasq string
 	serviceNameHostapd string
 	services           []string
+	serveHls           bool
+	sntpAddr           string
+	streamKeys         map[string][]string
 
 	wirelessInterface string
-	hlsPlayListPath   string
-	hlsPlayListPathMu sync.RWMutex
 	hlsUrlPathPrefix  string
-	restartMuxer      chan chan struct{}
 	shutdownErr       error
 	shutdownErrMu     sync.Mutex
 	shutdownOnce      sync.Once
@@ -61,7 +142,42 @@ type FlipCam struct {
 	// Channel closed when everything has shut down.
 	stopped <-chan struct{}
 
-	uiPort string
+	thumbnailInterval time.Duration
+	thumbnailJobs     chan thumbnailJob
+
+	// Metadata such as clips, annotations, and settings. Opened by Start and closed after
+	// every part has stopped.
+	databasePath string
+	db           *storage.DB
+	store        storage.Repository
+
+	// Clip-processing jobs, see queueJob.
+	jobQueue chan *Job
+	jobs     map[string]*Job
+	jobsMu   sync.Mutex
+
+	transcodePolicy TranscodePolicy
+
+	// The font of the overlay, which is configured per camera.
+	overlayFontFile string
+
+	// The delay that viewers follow, see BroadcastDelay.
+	broadcastDelay   BroadcastDelay
+	broadcastDelayMu sync.RWMutex
+
+	// The web UI, see Handler.
+	handler     http.Handler
+	handlerOnce sync.Once
+	listener    net.Listener
+	staticDir   string
+	uiPort      string
+
+	// TLS of the web UI, see setupTls.
+	ca            *localCa
+	caddyRootCert string
+	hostname      string
+	tlsConfig     *tls.Config
+	tlsDir        string
 }
 
 func New(opts Opts) *FlipCam {
@@ -70,36 +186,99 @@ func New(opts Opts) *FlipCam {
 	opts.ServiceNameHostapd = defaultString(opts.ServiceNameHostapd, defaultServiceNameHostapd)
 
 	f := &FlipCam{
+		cameras:          newCameras(opts.Cameras, opts.HlsUrlPathPrefix, opts.StreamKeys),
+		databasePath:     opts.DatabasePath,
 		hlsOutputDir:     opts.HlsOutputDir,
 		hlsUrlPathPrefix: opts.HlsUrlPathPrefix,
 
-		restartMuxer: make(chan chan struct{}),
-		routerAddr:   opts.RouterAddr,
+		coachPin:          opts.CoachPin,
+		coachPinGenerated: opts.CoachPin == "",
+		coachTokens:       make(map[string]struct{}),
+		pairingCode:       rand.Text(),
+
+		jobQueue: make(chan *Job, 16),
+		jobs:     make(map[string]*Job),
+
+		overlayFontFile: opts.OverlayFontFile,
+
+		routerAddr: opts.RouterAddr,
 
 		serviceNameCaddy:   opts.ServiceNameCaddy,
 		serviceNameDnsmasq: opts.ServiceNameDnsmasq,
 		serviceNameHostapd: opts.ServiceNameHostapd,
 		services: []string{
-			opts.ServiceNameCaddy,
 			opts.ServiceNameDnsmasq,
 			opts.ServiceNameHostapd,
 		},
-
-		stop:   make(chan struct{}),
-		uiPort: defaultString(opts.UiPort, ":3000"),
+		serveHls: opts.ServeHls,
+
+		sntpAddr:   opts.SntpAddr,
+		streamKeys: opts.StreamKeys,
+
+		stop:              make(chan struct{}),
+		thumbnailInterval: defaultDuration(opts.ThumbnailInterval, 5*time.Second),
+		thumbnailJobs:     make(chan thumbnailJob, 256),
+		transcodePolicy:   TranscodePolicy(defaultString(string(opts.TranscodePolicy), string(TranscodeAuto))),
+		listener:          opts.Listener,
+		caddyRootCert:     defaultString(opts.CaddyRootCert, defaultCaddyRootCert),
+		hostname:          opts.Hostname,
+		staticDir:         opts.StaticDir,
+		tlsDir:            opts.TlsDir,
+		uiPort:            defaultString(opts.UiPort, ":3000"),
 
 		wirelessInterface: opts.WirelessInterface,
 	}
+	if f.coachPinGenerated {
+		f.coachPin = generatePin(generatedPinLength)
+	}
+	if !opts.ServeHls {
+		f.services = append(f.services, opts.ServiceNameCaddy)
+	}
 	f.started = f.startupWg.WaitChan()
-	f.stopped = f.shutdownWg.WaitChan()
+	partsStopped := f.shutdownWg.WaitChan()
+	stopped := make(chan struct{})
+	f.stopped = stopped
+	go func() {
+		<-partsStopped
+		f.closeStorage()
+		close(stopped)
+	}()
 
 	return f
 }
 
 func (f *FlipCam) Start(ctx context.Context) error {
+	err := f.validateCameras()
+	if err != nil {
+		return err
+	}
+	err = validateCoachPin(f.coachPin)
+	if err != nil {
+		return err
+	}
+	err = ValidateThumbnailInterval(f.thumbnailInterval)
+	if err != nil {
+		return err
+	}
+
+	err = f.setupTls()
+	if err != nil {
+		return err
+	}
+
+	// Storage is opened before the parts start as they all may use it
+	err = f.openStorage()
+	if err != nil {
+		return fmt.Errorf("failed to open storage: %w", err)
+	}
+
 	startFuncs := []func(ctx context.Context){
 		f.setupNetwork,
-		f.runMuxer,
+		f.runMuxers,
+		f.runRtmpRouter,
+		f.runThumbnailer,
+		f.runJobs,
+		f.runSntpServer,
 		f.startWebserver,
 	}
 	// functions must call startupWg.Done() if they started successfully.
@@ -179,12 +358,6 @@ func (f *FlipCam) addShutdownError(err error) {
 	f.shutdownErr = errors.Join(f.shutdownErr, err)
 }
 
-func (f *FlipCam) getPlayListUrlPath() string {
-	f.hlsPlayListPathMu.RLock()
-	defer f.hlsPlayListPathMu.RUnlock()
-	return f.hlsPlayListPath
-}
-
 func (f *FlipCam) stopWithError(err error) {
 	f.addShutdownError(err)
 	go func() {
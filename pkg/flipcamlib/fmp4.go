@@ -0,0 +1,555 @@
+package flipcamlib
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+	"slices"
+)
+
+// This file contains a minimal ISO BMFF (MP4) parser that supports the fragmented MP4 files
+// written by the muxer. Only the video track is considered.
+
+type mp4Box struct {
+	Type string
+
+	// Offset of the start of the box header within the parsed buffer.
+	Offset int64
+
+	// Data is the content of the box, excluding the header.
+	Data []byte
+
+	headerSize int
+}
+
+var errMp4Truncated = errors.New("mp4: box is truncated")
+
+// readMp4Boxes returns the boxes contained in data.
+// offset is the position of data in the file and is used to set mp4Box.Offset.
+func readMp4Boxes(data []byte, offset int64) ([]mp4Box, error) {
+	var boxes []mp4Box
+	pos := 0
+	for pos < len(data) {
+		if len(data)-pos < 8 {
+			return boxes, errMp4Truncated
+		}
+
+		size := uint64(binary.BigEndian.Uint32(data[pos:]))
+		boxType := string(data[pos+4 : pos+8])
+		headerSize := uint64(8)
+		switch size {
+		case 0:
+			// Box extends to the end of the file
+			size = uint64(len(data) - pos)
+		case 1:
+			if len(data)-pos < 16 {
+				return boxes, errMp4Truncated
+			}
+			size = binary.BigEndian.Uint64(data[pos+8:])
+			headerSize = 16
+		}
+
+		if size < headerSize || size > uint64(len(data)-pos) {
+			return boxes, errMp4Truncated
+		}
+
+		boxes = append(boxes, mp4Box{
+			Type:   boxType,
+			Offset: offset + int64(pos),
+			Data:   data[pos+int(headerSize) : pos+int(size)],
+
+			headerSize: int(headerSize),
+		})
+		pos += int(size)
+	}
+
+	return boxes, nil
+}
+
+// childBoxes returns the boxes contained in a container box.
+func (b mp4Box) childBoxes() ([]mp4Box, error) {
+	return readMp4Boxes(b.Data, b.Offset+int64(b.headerSize))
+}
+
+func findMp4Box(boxes []mp4Box, boxType string) (mp4Box, bool) {
+	for _, box := range boxes {
+		if box.Type == boxType {
+			return box, true
+		}
+	}
+
+	return mp4Box{}, false
+}
+
+// findMp4Path returns the first box that matches the path of box types.
+func findMp4Path(boxes []mp4Box, path ...string) (mp4Box, error) {
+	var box mp4Box
+	for i, boxType := range path {
+		var found bool
+		box, found = findMp4Box(boxes, boxType)
+		if !found {
+			return mp4Box{}, fmt.Errorf("mp4: box %s not found", boxType)
+		}
+
+		if i < len(path)-1 {
+			var err error
+			boxes, err = box.childBoxes()
+			if err != nil {
+				return mp4Box{}, err
+			}
+		}
+	}
+
+	return box, nil
+}
+
+// fmp4Init is the information from the initialization segment needed to interpret the media
+// segments.
+type fmp4Init struct {
+	// SampleEntry is the type of the sample entry of the video track. E.g. avc1 or hvc1.
+	SampleEntry string
+
+	// Codecs is the RFC 6381 codecs parameter of the video track. E.g. avc1.64001f.
+	// It is equal to SampleEntry if the codec configuration is not supported.
+	Codecs string
+
+	// Width and Height of the video in pixels, as stored in the sample entry.
+	Width  int
+	Height int
+
+	Timescale uint32
+	TrackId   uint32
+
+	defaultSampleDuration uint32
+	defaultSampleFlags    uint32
+	defaultSampleSize     uint32
+}
+
+func parseFmp4Init(data []byte) (fmp4Init, error) {
+	boxes, err := readMp4Boxes(data, 0)
+	if err != nil {
+		return fmp4Init{}, err
+	}
+
+	moov, err := findMp4Path(boxes, "moov")
+	if err != nil {
+		return fmp4Init{}, err
+	}
+	moovChildren, err := moov.childBoxes()
+	if err != nil {
+		return fmp4Init{}, err
+	}
+
+	var init fmp4Init
+	found := false
+	for _, trak := range moovChildren {
+		if trak.Type != "trak" {
+			continue
+		}
+
+		trakChildren, err := trak.childBoxes()
+		if err != nil {
+			return fmp4Init{}, err
+		}
+
+		hdlr, err := findMp4Path(trakChildren, "mdia", "hdlr")
+		if err != nil {
+			return fmp4Init{}, err
+		}
+		if len(hdlr.Data) < 12 || string(hdlr.Data[8:12]) != "vide" {
+			continue
+		}
+
+		tkhd, err := findMp4Path(trakChildren, "tkhd")
+		if err != nil {
+			return fmp4Init{}, err
+		}
+		if len(tkhd.Data) < 24 {
+			return fmp4Init{}, errMp4Truncated
+		}
+		if tkhd.Data[0] == 1 {
+			init.TrackId = binary.BigEndian.Uint32(tkhd.Data[20:])
+		} else {
+			init.TrackId = binary.BigEndian.Uint32(tkhd.Data[12:])
+		}
+
+		mdhd, err := findMp4Path(trakChildren, "mdia", "mdhd")
+		if err != nil {
+			return fmp4Init{}, err
+		}
+		if len(mdhd.Data) < 4 {
+			return fmp4Init{}, errMp4Truncated
+		}
+		if mdhd.Data[0] == 1 {
+			if len(mdhd.Data) < 24 {
+				return fmp4Init{}, errMp4Truncated
+			}
+			init.Timescale = binary.BigEndian.Uint32(mdhd.Data[20:])
+		} else {
+			if len(mdhd.Data) < 16 {
+				return fmp4Init{}, errMp4Truncated
+			}
+			init.Timescale = binary.BigEndian.Uint32(mdhd.Data[12:])
+		}
+		if init.Timescale == 0 {
+			return fmp4Init{}, errors.New("mp4: video track has a timescale of 0")
+		}
+
+		stsd, err := findMp4Path(trakChildren, "mdia", "minf", "stbl", "stsd")
+		if err != nil {
+			return fmp4Init{}, err
+		}
+		if len(stsd.Data) < 16 {
+			return fmp4Init{}, errMp4Truncated
+		}
+		// Skip version, flags, and entry count to get to the type of the first entry
+		init.SampleEntry = string(stsd.Data[12:16])
+		// The visual sample entry starts with 8 bytes of the generic sample entry followed by 16
+		// bytes of reserved and pre-defined fields
+		if len(stsd.Data) >= 44 {
+			init.Width = int(binary.BigEndian.Uint16(stsd.Data[40:]))
+			init.Height = int(binary.BigEndian.Uint16(stsd.Data[42:]))
+		}
+		init.Codecs = parseMp4Codecs(stsd.Data[8:])
+
+		found = true
+		break
+	}
+
+	if !found {
+		return fmp4Init{}, fmt.Errorf("mp4: no video track found")
+	}
+
+	mvex, found := findMp4Box(moovChildren, "mvex")
+	if found {
+		mvexChildren, err := mvex.childBoxes()
+		if err != nil {
+			return fmp4Init{}, err
+		}
+
+		for _, trex := range mvexChildren {
+			if trex.Type != "trex" || len(trex.Data) < 24 {
+				continue
+			}
+			if binary.BigEndian.Uint32(trex.Data[4:]) != init.TrackId {
+				continue
+			}
+			init.defaultSampleDuration = binary.BigEndian.Uint32(trex.Data[12:])
+			init.defaultSampleSize = binary.BigEndian.Uint32(trex.Data[16:])
+			init.defaultSampleFlags = binary.BigEndian.Uint32(trex.Data[20:])
+		}
+	}
+
+	return init, nil
+}
+
+// parseMp4Codecs returns the RFC 6381 codecs parameter of the visual sample entry at the start of
+// data. Only AVC and HEVC are described in detail, for other codecs the sample entry type is
+// returned.
+func parseMp4Codecs(data []byte) string {
+	entryType := string(data[4:8])
+	// The children of the visual sample entry follow its 8 byte header and 78 bytes of fields
+	const childrenOffset = 86
+	size := int(binary.BigEndian.Uint32(data))
+	if size < childrenOffset || size > len(data) {
+		return entryType
+	}
+	children, err := readMp4Boxes(data[childrenOffset:size], 0)
+	if err != nil {
+		return entryType
+	}
+
+	if avcC, found := findMp4Box(children, "avcC"); found && len(avcC.Data) >= 4 {
+		// Profile, profile compatibility, and level
+		return fmt.Sprintf("%s.%02x%02x%02x", entryType, avcC.Data[1], avcC.Data[2], avcC.Data[3])
+	}
+
+	if hvcC, found := findMp4Box(children, "hvcC"); found && len(hvcC.Data) >= 13 {
+		// ISO/IEC 14496-15, annex E
+		profileSpace := hvcC.Data[1] >> 6
+		tier := "L"
+		if hvcC.Data[1]&0x20 != 0 {
+			tier = "H"
+		}
+		profile := hvcC.Data[1] & 0x1f
+		// The compatibility flags are written in reverse bit order
+		var compatibility uint32
+		for i, flags := 0, binary.BigEndian.Uint32(hvcC.Data[2:]); i < 32; i++ {
+			compatibility = compatibility<<1 | flags>>i&1
+		}
+
+		codecs := entryType + "."
+		if profileSpace > 0 {
+			codecs += string(rune('A' + profileSpace - 1))
+		}
+		codecs += fmt.Sprintf("%d.%X.%s%d", profile, compatibility, tier, hvcC.Data[12])
+		// Constraint flags, trailing zero bytes are omitted
+		constraints := hvcC.Data[6:12]
+		for len(constraints) > 0 && constraints[len(constraints)-1] == 0 {
+			constraints = constraints[:len(constraints)-1]
+		}
+		for _, b := range constraints {
+			codecs += fmt.Sprintf(".%X", b)
+		}
+		return codecs
+	}
+
+	return entryType
+}
+
+// fmp4Sample is a video sample of a media segment.
+type fmp4Sample struct {
+	// CompositionOffset is the difference between the presentation and decode time.
+	CompositionOffset int64
+
+	// DecodeTime is the decode time in the timescale of the track.
+	DecodeTime uint64
+
+	// Duration in the timescale of the track.
+	Duration uint32
+
+	Keyframe bool
+
+	// MoofOffset is the offset of the moof box that describes the sample.
+	MoofOffset int64
+
+	// Offset of the sample data in the segment.
+	Offset int64
+
+	Size uint32
+}
+
+// PresentationTime returns the presentation time in the timescale of the track.
+func (s fmp4Sample) PresentationTime() int64 {
+	return int64(s.DecodeTime) + s.CompositionOffset
+}
+
+// End returns the offset of the end of the sample data.
+func (s fmp4Sample) End() int64 {
+	return s.Offset + int64(s.Size)
+}
+
+const (
+	tfhdBaseDataOffset         = 0x000001
+	tfhdSampleDescriptionIndex = 0x000002
+	tfhdDefaultSampleDuration  = 0x000008
+	tfhdDefaultSampleSize      = 0x000010
+	tfhdDefaultSampleFlags     = 0x000020
+
+	trunDataOffset       = 0x000001
+	trunFirstSampleFlags = 0x000004
+	trunSampleDuration   = 0x000100
+	trunSampleSize       = 0x000200
+	trunSampleFlags      = 0x000400
+	trunSampleCto        = 0x000800
+
+	sampleIsNonSync = 0x00010000
+)
+
+// maxTrunSamples is the largest sample count of a trun box that is accepted. Segments of the muxer
+// last about a second, this allows for far longer segments at high frame rates.
+const maxTrunSamples = 1 << 16
+
+// parseFmp4Segment returns the video samples of a media segment.
+func parseFmp4Segment(data []byte, init fmp4Init) ([]fmp4Sample, error) {
+	boxes, err := readMp4Boxes(data, 0)
+	if err != nil && !errors.Is(err, errMp4Truncated) {
+		return nil, err
+	}
+
+	var samples []fmp4Sample
+	for _, moof := range boxes {
+		if moof.Type != "moof" {
+			continue
+		}
+
+		moofChildren, err := moof.childBoxes()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, traf := range moofChildren {
+			if traf.Type != "traf" {
+				continue
+			}
+
+			trafSamples, err := parseFmp4Traf(traf, moof.Offset, init)
+			if err != nil {
+				return nil, err
+			}
+			samples = append(samples, trafSamples...)
+		}
+	}
+
+	return samples, nil
+}
+
+func parseFmp4Traf(traf mp4Box, moofOffset int64, init fmp4Init) ([]fmp4Sample, error) {
+	children, err := traf.childBoxes()
+	if err != nil {
+		return nil, err
+	}
+
+	tfhd, found := findMp4Box(children, "tfhd")
+	if !found || len(tfhd.Data) < 8 {
+		return nil, fmt.Errorf("mp4: traf without valid tfhd")
+	}
+	if binary.BigEndian.Uint32(tfhd.Data[4:]) != init.TrackId {
+		return nil, nil
+	}
+
+	r := mp4Reader{data: tfhd.Data}
+	tfhdFlags := r.uint32() & 0xffffff
+	r.uint32() // Track ID
+	baseOffset := moofOffset
+	if tfhdFlags&tfhdBaseDataOffset != 0 {
+		baseOffset = int64(r.uint64())
+	}
+	if tfhdFlags&tfhdSampleDescriptionIndex != 0 {
+		r.uint32()
+	}
+	defaultDuration := init.defaultSampleDuration
+	if tfhdFlags&tfhdDefaultSampleDuration != 0 {
+		defaultDuration = r.uint32()
+	}
+	defaultSize := init.defaultSampleSize
+	if tfhdFlags&tfhdDefaultSampleSize != 0 {
+		defaultSize = r.uint32()
+	}
+	defaultFlags := init.defaultSampleFlags
+	if tfhdFlags&tfhdDefaultSampleFlags != 0 {
+		defaultFlags = r.uint32()
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	var decodeTime uint64
+	if tfdt, found := findMp4Box(children, "tfdt"); found {
+		r := mp4Reader{data: tfdt.Data}
+		if r.uint32()>>24 == 1 {
+			decodeTime = r.uint64()
+		} else {
+			decodeTime = uint64(r.uint32())
+		}
+		if r.err != nil {
+			return nil, r.err
+		}
+	}
+
+	var samples []fmp4Sample
+	for _, trun := range children {
+		if trun.Type != "trun" {
+			continue
+		}
+
+		r := mp4Reader{data: trun.Data}
+		versionAndFlags := r.uint32()
+		version := versionAndFlags >> 24
+		trunFlags := versionAndFlags & 0xffffff
+		sampleCount := r.uint32()
+		dataOffset := baseOffset
+		if trunFlags&trunDataOffset != 0 {
+			dataOffset += int64(int32(r.uint32()))
+		}
+		var firstSampleFlags uint32
+		hasFirstSampleFlags := trunFlags&trunFirstSampleFlags != 0
+		if hasFirstSampleFlags {
+			firstSampleFlags = r.uint32()
+		}
+		if r.err != nil {
+			return nil, r.err
+		}
+
+		// Every sample takes 4 bytes per field present in the box
+		const sampleFields = trunSampleDuration | trunSampleSize | trunSampleFlags | trunSampleCto
+		sampleSize := 4 * bits.OnesCount32(trunFlags&sampleFields)
+		switch {
+		case sampleCount > maxTrunSamples:
+			return nil, fmt.Errorf("mp4: trun with %d samples", sampleCount)
+		case int(sampleCount)*sampleSize > len(r.data)-r.pos:
+			return nil, errMp4Truncated
+		}
+		samples = slices.Grow(samples, int(sampleCount))
+
+		for i := uint32(0); i < sampleCount && r.err == nil; i++ {
+			sample := fmp4Sample{
+				DecodeTime: decodeTime,
+				Duration:   defaultDuration,
+				MoofOffset: moofOffset,
+				Offset:     dataOffset,
+				Size:       defaultSize,
+			}
+			flags := defaultFlags
+
+			if trunFlags&trunSampleDuration != 0 {
+				sample.Duration = r.uint32()
+			}
+			if trunFlags&trunSampleSize != 0 {
+				sample.Size = r.uint32()
+			}
+			if trunFlags&trunSampleFlags != 0 {
+				flags = r.uint32()
+			}
+			if i == 0 && hasFirstSampleFlags {
+				flags = firstSampleFlags
+			}
+			if trunFlags&trunSampleCto != 0 {
+				if version == 0 {
+					sample.CompositionOffset = int64(r.uint32())
+				} else {
+					sample.CompositionOffset = int64(int32(r.uint32()))
+				}
+			}
+
+			sample.Keyframe = flags&sampleIsNonSync == 0
+			samples = append(samples, sample)
+			decodeTime += uint64(sample.Duration)
+			dataOffset += int64(sample.Size)
+		}
+
+		if r.err != nil {
+			return nil, r.err
+		}
+	}
+
+	return samples, nil
+}
+
+// mp4Reader reads big endian integers from a box. After the first error, all reads return zero
+// and err is set.
+type mp4Reader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *mp4Reader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if r.pos+n > len(r.data) {
+		r.err = errMp4Truncated
+		return nil
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *mp4Reader) uint32() uint32 {
+	b := r.next(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (r *mp4Reader) uint64() uint64 {
+	b := r.next(8)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
@@ -0,0 +1,230 @@
+package flipcamlib
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// testMp4Box returns a box with the content and children appended.
+func testMp4Box(boxType string, content ...[]byte) []byte {
+	size := 8
+	for _, c := range content {
+		size += len(c)
+	}
+
+	box := binary.BigEndian.AppendUint32(nil, uint32(size))
+	box = append(box, boxType...)
+	for _, c := range content {
+		box = append(box, c...)
+	}
+
+	return box
+}
+
+// testUint32s returns the values as big endian bytes.
+func testUint32s(values ...uint32) []byte {
+	var b []byte
+	for _, v := range values {
+		b = binary.BigEndian.AppendUint32(b, v)
+	}
+
+	return b
+}
+
+// testFmp4InitOpts are the fields of the fixture initialization segment.
+type testFmp4InitOpts struct {
+	handler   string
+	timescale uint32
+}
+
+// testFmp4Init returns an initialization segment with one track, a 1280x720 AVC video track
+// unless the options say otherwise.
+func testFmp4Init(opts testFmp4InitOpts) []byte {
+	// Version and flags, creation and modification time, track ID, reserved, duration
+	tkhd := testMp4Box("tkhd", testUint32s(0, 0, 0, 1, 0, 0))
+	// Version and flags, creation and modification time, timescale, duration, language
+	mdhd := testMp4Box("mdhd", testUint32s(0, 0, 0, opts.timescale, 0, 0))
+	// Version and flags, pre-defined, handler type
+	hdlr := testMp4Box("hdlr", testUint32s(0, 0), []byte(opts.handler), make([]byte, 13))
+
+	// Reserved, data reference index, pre-defined and reserved, width, height, and the remaining
+	// 50 bytes of fields
+	visualFields := make([]byte, 78)
+	binary.BigEndian.PutUint16(visualFields[6:], 1)
+	binary.BigEndian.PutUint16(visualFields[24:], 1280)
+	binary.BigEndian.PutUint16(visualFields[26:], 720)
+	avcC := testMp4Box("avcC", []byte{1, 0x64, 0x00, 0x1f, 0xff})
+	stsd := testMp4Box("stsd", testUint32s(0, 1), testMp4Box("avc1", visualFields, avcC))
+
+	stbl := testMp4Box("stbl", stsd)
+	minf := testMp4Box("minf", stbl)
+	mdia := testMp4Box("mdia", mdhd, hdlr, minf)
+	trak := testMp4Box("trak", tkhd, mdia)
+	// Version and flags, track ID, description index, duration, size, flags
+	trex := testMp4Box("trex", testUint32s(0, 1, 1, 512, 0, 0x01010000))
+	mvex := testMp4Box("mvex", trex)
+
+	ftyp := testMp4Box("ftyp", []byte("iso5"), testUint32s(512))
+	return append(ftyp, testMp4Box("moov", trak, mvex)...)
+}
+
+var testInitOpts = testFmp4InitOpts{handler: "vide", timescale: 15360}
+
+// testFmp4Segment returns a media segment with a moof box describing the samples of trun, which
+// may be nil for a segment without samples, and a mdat box of mdatSize bytes.
+func testFmp4Segment(trun []byte, mdatSize int) []byte {
+	// Version and flags (default-base-is-moof, default sample duration), track ID, duration
+	tfhd := testMp4Box("tfhd", testUint32s(0x00020008, 1, 512))
+	// Version 1 and flags, base media decode time
+	tfdt := testMp4Box("tfdt", testUint32s(1<<24, 0, 1024))
+	traf := testMp4Box("traf", tfhd, tfdt)
+	if trun != nil {
+		traf = testMp4Box("traf", tfhd, tfdt, trun)
+	}
+	mfhd := testMp4Box("mfhd", testUint32s(0, 1))
+
+	return append(testMp4Box("moof", mfhd, traf), testMp4Box("mdat", make([]byte, mdatSize))...)
+}
+
+// testTrun returns a trun box with a data offset and a size and flags per sample.
+func testTrun(dataOffset uint32, sampleCount uint32, sizesAndFlags ...uint32) []byte {
+	flags := uint32(trunDataOffset | trunSampleSize | trunSampleFlags)
+	header := testUint32s(flags, sampleCount, dataOffset)
+	return testMp4Box("trun", header, testUint32s(sizesAndFlags...))
+}
+
+func TestParseFmp4Init(t *testing.T) {
+	valid := testFmp4Init(testInitOpts)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    fmp4Init
+		wantErr bool
+	}{
+		{
+			name: "video track",
+			data: valid,
+			want: fmp4Init{
+				SampleEntry:           "avc1",
+				Codecs:                "avc1.64001f",
+				Width:                 1280,
+				Height:                720,
+				Timescale:             15360,
+				TrackId:               1,
+				defaultSampleDuration: 512,
+				defaultSampleFlags:    0x01010000,
+			},
+		},
+		{
+			name:    "truncated",
+			data:    valid[:len(valid)/2],
+			wantErr: true,
+		},
+		{
+			name:    "timescale of 0",
+			data:    testFmp4Init(testFmp4InitOpts{handler: "vide"}),
+			wantErr: true,
+		},
+		{
+			name:    "no video track",
+			data:    testFmp4Init(testFmp4InitOpts{handler: "soun", timescale: 48000}),
+			wantErr: true,
+		},
+		{
+			name:    "no moov",
+			data:    testMp4Box("ftyp", []byte("iso5")),
+			wantErr: true,
+		},
+		{
+			name:    "box larger than the data",
+			data:    testUint32s(1000, binary.BigEndian.Uint32([]byte("moov"))),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseFmp4Init(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseFmp4Init() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("parseFmp4Init() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFmp4Segment(t *testing.T) {
+	init, err := parseFmp4Init(testFmp4Init(testInitOpts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The moof box of the fixture is 120 bytes when it has a trun of two samples, the samples
+	// follow the header of the mdat box.
+	const dataOffset = 120 + 8
+	valid := testFmp4Segment(testTrun(dataOffset, 2, 100, 0x02000000, 50, 0x01010000), 150)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    []fmp4Sample
+		wantErr bool
+	}{
+		{
+			name: "two samples",
+			data: valid,
+			want: []fmp4Sample{
+				{DecodeTime: 1024, Duration: 512, Keyframe: true, Offset: dataOffset, Size: 100},
+				{DecodeTime: 1536, Duration: 512, Offset: dataOffset + 100, Size: 50},
+			},
+		},
+		{
+			name: "mdat still being written",
+			data: valid[:len(valid)-10],
+			want: []fmp4Sample{
+				{DecodeTime: 1024, Duration: 512, Keyframe: true, Offset: dataOffset, Size: 100},
+				{DecodeTime: 1536, Duration: 512, Offset: dataOffset + 100, Size: 50},
+			},
+		},
+		{
+			name: "no trun",
+			data: testFmp4Segment(nil, 0),
+		},
+		{
+			name:    "fewer samples than the sample count",
+			data:    testFmp4Segment(testTrun(dataOffset, 3, 100, 0x02000000, 50, 0x01010000), 150),
+			wantErr: true,
+		},
+		{
+			name:    "sample count larger than the box",
+			data:    testFmp4Segment(testTrun(dataOffset, 0xffffffff, 100, 0x02000000), 150),
+			wantErr: true,
+		},
+		{
+			name: "sample count too large without fields per sample",
+			data: testFmp4Segment(
+				testMp4Box("trun", testUint32s(trunDataOffset, maxTrunSamples+1, dataOffset)),
+				150,
+			),
+			wantErr: true,
+		},
+		{
+			name:    "traf without tfhd",
+			data:    testMp4Box("moof", testMp4Box("traf", testMp4Box("tfdt", testUint32s(0, 0)))),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseFmp4Segment(tt.data, init)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseFmp4Segment() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseFmp4Segment() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
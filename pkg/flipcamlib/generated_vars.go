@@ -11,6 +11,7 @@ import (
 
 type generatedVarsData struct {
 	CaddyBinaryPath     string
+	CaddyRootCertPath   string
 	CaddyServiceName    string
 	ConnectivityHosts   []string
 	DhcpEnd             string
@@ -20,6 +21,7 @@ type generatedVarsData struct {
 	HlsOutputDir        string
 	HostapdServiceName  string
 	RouterIp            string
+	SntpEnabled         bool
 	WebDomain           string
 	WirelessInterface   string
 	WirelessPassphrase  string
@@ -50,6 +52,7 @@ func (f *FlipCam) GenerateVars(w io.Writer, opts GenerateVarsOpts) error {
 
 	return generatedVarsTmpl.Execute(w, generatedVarsData{
 		CaddyBinaryPath:    opts.CaddyBinaryPath,
+		CaddyRootCertPath:  f.caddyRootCert,
 		CaddyServiceName:   f.serviceNameCaddy,
 		ConnectivityHosts:  []string{goProApi},
 		DhcpEnd:            dhcpEnd.String(),
@@ -64,6 +67,7 @@ func (f *FlipCam) GenerateVars(w io.Writer, opts GenerateVarsOpts) error {
 		HlsOutputDir:       f.hlsOutputDir,
 		HostapdServiceName: f.serviceNameHostapd,
 		RouterIp:           routerIp.Addr().String(),
+		SntpEnabled:        f.sntpAddr != "",
 		WebDomain:          opts.Hostname,
 		WirelessInterface:  f.wirelessInterface,
 		WirelessPassphrase: opts.WirelessPassphrase,
@@ -0,0 +1,137 @@
+package flipcamlib
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// minCompressLength is the size below which playlists are sent uncompressed, as configured for
+// Caddy.
+const minCompressLength = 1_000
+
+// hlsFilePattern matches the files of a session that are served below the HLS prefixes, e.g.
+// ABCDEF.m3u8, ABCDEF_12.mp4, ABCDEF_init.mp4, and ABCDEF.mpd. Other files in HlsOutputDir, such
+// as job results, comment audio, and snapshots, are only available through the API. Both
+// handleHlsFile and the Caddy configuration use it.
+const hlsFilePattern = `[A-Z2-7]{6}(_[a-z0-9]+)?\.(m3u8|mpd|mp4)`
+
+var hlsFileRegexp = regexp.MustCompile(`^` + hlsFilePattern + `$`)
+
+// localOriginRegexp matches the origins that may make cross-origin requests for HLS files.
+var localOriginRegexp = regexp.MustCompile(`^https?://(localhost|127\.0\.0\.1)(:\d+)?$`)
+
+var hlsContentTypes = map[string]string{
+	".m3u8": "audio/x-mpegurl",
+	".mpd":  "application/dash+xml",
+	".mp4":  "video/mp4",
+}
+
+var zstdEncoder = sync.OnceValue(func() *zstd.Encoder {
+	encoder, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedFastest))
+	if err != nil {
+		panic(err) // Only fails on invalid options
+	}
+	return encoder
+})
+
+// hlsPrefixes returns the URL path prefixes below which the HLS files are served. Each camera
+// has its own prefix. The session playlists of all cameras are also served directly below the
+// HLS prefix, which is used by pages that show sessions of any camera.
+func (f *FlipCam) hlsPrefixes() []string {
+	prefixes := make([]string, 0, len(f.cameras)+1)
+	for _, c := range f.cameras {
+		prefixes = append(prefixes, c.urlPathPrefix)
+	}
+
+	return append(prefixes, f.hlsUrlPathPrefix)
+}
+
+// handleHlsFile serves a file written by the muxer or the session processors the way the Caddy
+// configuration of GenerateCaddyConfig does. Used when Opts.ServeHls is set.
+func (f *FlipCam) handleHlsFile(w http.ResponseWriter, r *http.Request) {
+	file := r.PathValue("file")
+	if !hlsFileRegexp.MatchString(file) {
+		http.NotFound(w, r)
+		return
+	}
+
+	if origin := r.Header.Get("Origin"); localOriginRegexp.MatchString(origin) {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+	w.Header().Add("Vary", "Origin")
+
+	ext := filepath.Ext(file)
+	w.Header().Set("Content-Type", hlsContentTypes[ext])
+	filePath := filepath.Join(f.hlsOutputDir, file)
+	if ext == ".mp4" {
+		// ServeFile handles the range requests of players fetching byte ranges.
+		http.ServeFile(w, r, filePath)
+		return
+	}
+
+	// Manifests change while recording and are small, so they are read in full to be
+	// compressed and must not be cached.
+	w.Header().Set("Cache-Control", "no-store")
+	content, err := os.ReadFile(filePath)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		http.NotFound(w, r)
+		return
+	case err != nil:
+		log.Printf("web: failed to read %s: %v\n", file, err)
+		http.Error(w, "failed to read file", http.StatusInternalServerError)
+		return
+	}
+
+	if ext == ".m3u8" && len(content) >= minCompressLength {
+		content = compressPlaylist(w, r, content)
+	}
+	_, _ = w.Write(content)
+}
+
+// compressPlaylist encodes the content with zstd or gzip if the client accepts either, preferring
+// zstd, and sets the matching headers.
+func compressPlaylist(w http.ResponseWriter, r *http.Request, content []byte) []byte {
+	w.Header().Add("Vary", "Accept-Encoding")
+	acceptEncoding := r.Header.Get("Accept-Encoding")
+	switch {
+	case acceptsEncoding(acceptEncoding, "zstd"):
+		w.Header().Set("Content-Encoding", "zstd")
+		return zstdEncoder().EncodeAll(content, nil)
+	case acceptsEncoding(acceptEncoding, "gzip"):
+		var buf bytes.Buffer
+		gz, _ := gzip.NewWriterLevel(&buf, 4) // Level is valid
+		_, _ = gz.Write(content)              // Writes to a bytes.Buffer don't fail
+		_ = gz.Close()
+		w.Header().Set("Content-Encoding", "gzip")
+		return buf.Bytes()
+	default:
+		return content
+	}
+}
+
+// acceptsEncoding reports whether the Accept-Encoding header lists the encoding without
+// refusing it using q=0.
+func acceptsEncoding(header string, encoding string) bool {
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(name), encoding) {
+			continue
+		}
+		q, found := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		return !found || strings.Trim(q, "0.") != ""
+	}
+
+	return false
+}
@@ -0,0 +1,237 @@
+package flipcamlib
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// iframePlaylistSuffix is appended to the session ID to get the I-frame playlist filename.
+const iframePlaylistSuffix = "_iframes.m3u8"
+
+// iframeIndexer builds an EXT-X-I-FRAMES-ONLY playlist of a session while it is being recorded.
+// Every entry is a byte range of an existing segment, starting at the moof box and ending after
+// the keyframe's sample data.
+type iframeIndexer struct {
+	session Session
+
+	entries []iframeEntry
+	init    *fmp4Init
+	mapUri  string
+
+	// end is the presentation time at which the last processed segment ends.
+	end int64
+}
+
+type iframeEntry struct {
+	length          int64
+	offset          int64
+	presentation    int64
+	programDateTime time.Time
+	uri             string
+}
+
+func newIframeIndexer(session Session) *iframeIndexer {
+	return &iframeIndexer{session: session}
+}
+
+func (x *iframeIndexer) processSegment(playlist *MediaPlaylist, segment MediaSegment) error {
+	if x.init == nil {
+		initData, err := os.ReadFile(x.session.uriPath(playlist.MapUri))
+		if err != nil {
+			return fmt.Errorf("failed to read initialization segment: %w", err)
+		}
+
+		init, err := parseFmp4Init(initData)
+		if err != nil {
+			return fmt.Errorf("failed to parse initialization segment: %w", err)
+		}
+		x.init = &init
+		x.mapUri = playlist.MapUri
+	}
+
+	data, err := os.ReadFile(x.session.uriPath(segment.Uri))
+	if err != nil {
+		return fmt.Errorf("failed to read segment: %w", err)
+	}
+
+	samples, err := parseFmp4Segment(data, *x.init)
+	if err != nil {
+		return fmt.Errorf("failed to parse segment %s: %w", segment.Uri, err)
+	}
+	if len(samples) == 0 {
+		return nil
+	}
+
+	segmentStart := int64(math.MaxInt64)
+	for _, sample := range samples {
+		segmentStart = min(segmentStart, sample.PresentationTime())
+		x.end = max(x.end, sample.PresentationTime()+int64(sample.Duration))
+	}
+
+	for _, sample := range samples {
+		if !sample.Keyframe {
+			continue
+		}
+
+		entry := iframeEntry{
+			length:       sample.End() - sample.MoofOffset,
+			offset:       sample.MoofOffset,
+			presentation: sample.PresentationTime(),
+			uri:          segment.Uri,
+		}
+		if !segment.ProgramDateTime.IsZero() {
+			entry.programDateTime = segment.ProgramDateTime.Add(
+				x.duration(sample.PresentationTime() - segmentStart),
+			)
+		}
+		x.entries = append(x.entries, entry)
+	}
+
+	return x.write(false)
+}
+
+func (x *iframeIndexer) finish(*MediaPlaylist) error {
+	if x.init == nil {
+		return nil
+	}
+
+	return x.write(true)
+}
+
+// duration converts a duration in the timescale of the track to time.Duration.
+func (x *iframeIndexer) duration(d int64) time.Duration {
+	return time.Duration(d) * time.Second / time.Duration(x.init.Timescale)
+}
+
+func (x *iframeIndexer) write(ended bool) error {
+	var b bytes.Buffer
+	durations := make([]time.Duration, len(x.entries))
+	var targetDuration time.Duration
+	for i, entry := range x.entries {
+		end := x.end
+		if i+1 < len(x.entries) {
+			end = x.entries[i+1].presentation
+		}
+		durations[i] = x.duration(end - entry.presentation)
+		targetDuration = max(targetDuration, durations[i])
+	}
+
+	b.WriteString("#EXTM3U\n")
+	b.WriteString("#EXT-X-VERSION:7\n")
+	b.WriteString("#EXT-X-TARGETDURATION:")
+	b.WriteString(strconv.Itoa(int(math.Ceil(targetDuration.Seconds()))))
+	b.WriteString("\n#EXT-X-MEDIA-SEQUENCE:0\n")
+	b.WriteString("#EXT-X-PLAYLIST-TYPE:EVENT\n")
+	b.WriteString("#EXT-X-I-FRAMES-ONLY\n")
+	fmt.Fprintf(&b, "#EXT-X-MAP:URI=%q\n", x.mapUri)
+	for i, entry := range x.entries {
+		if !entry.programDateTime.IsZero() {
+			fmt.Fprintf(&b, "#EXT-X-PROGRAM-DATE-TIME:%s\n", formatProgramDateTime(entry.programDateTime))
+		}
+		fmt.Fprintf(&b, "#EXTINF:%s,\n", formatSeconds(durations[i]))
+		fmt.Fprintf(&b, "#EXT-X-BYTERANGE:%d@%d\n", entry.length, entry.offset)
+		b.WriteString(entry.uri)
+		b.WriteString("\n")
+	}
+	if ended {
+		b.WriteString("#EXT-X-ENDLIST\n")
+	}
+
+	return writeFileAtomic(x.session.filePath(iframePlaylistSuffix), b.Bytes(), 0o644)
+}
+
+// handleScrub returns a JPEG of the keyframe at or before the requested media time.
+// It uses the I-frame playlist so that only the keyframe is read and decoded.
+//
+// Query parameters:
+//   - t: media time in seconds, as the currentTime of the video element.
+//   - width: optional width in pixels, defaults to 320.
+func (f *FlipCam) handleScrub(w http.ResponseWriter, r *http.Request) {
+	session, err := f.getSession(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	t, err := strconv.ParseFloat(r.URL.Query().Get("t"), 64)
+	if err != nil || t < 0 {
+		http.Error(w, "t must be a positive number of seconds", http.StatusBadRequest)
+		return
+	}
+	mediaTime := time.Duration(t * float64(time.Second))
+
+	width, err := parseImageWidth(r.URL.Query().Get("width"), 320)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	iframes, err := readMediaPlaylist(session.filePath(iframePlaylistSuffix))
+	if err != nil || len(iframes.Segments) == 0 {
+		http.Error(w, "no I-frames indexed for this session", http.StatusNotFound)
+		return
+	}
+
+	index, _ := slices.BinarySearchFunc(
+		iframes.Segments,
+		mediaTime,
+		func(segment MediaSegment, t time.Duration) int {
+			return cmp.Compare(segment.MediaTime, t)
+		},
+	)
+	if index == len(iframes.Segments) ||
+		(index > 0 && iframes.Segments[index].MediaTime > mediaTime) {
+		index--
+	}
+	iframe := iframes.Segments[index]
+
+	input, err := session.openSegments(iframes.MapUri, []MediaSegment{iframe})
+	if err != nil {
+		log.Printf("web: scrub: %v\n", err)
+		http.Error(w, "failed to read I-frame", http.StatusInternalServerError)
+		return
+	}
+	defer input.Close()
+
+	frame, err := extractFrame(r.Context(), input, 0, width, ImageJpeg)
+	if err != nil {
+		log.Printf("web: scrub: failed to decode I-frame: %v\n", err)
+		http.Error(w, "failed to decode I-frame", http.StatusInternalServerError)
+		return
+	}
+
+	if index == len(iframes.Segments)-1 {
+		// A later I-frame might be closer to the requested time
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Cache-Control", "max-age=3600")
+	}
+	w.Header().Set("Content-Type", ImageJpeg.ContentType())
+	_, err = w.Write(frame)
+	if err != nil {
+		log.Printf("web: scrub: failed to write frame: %v\n", err)
+	}
+}
+
+// parseImageWidth parses the requested width of an image. Returns defaultWidth if value is
+// empty.
+func parseImageWidth(value string, defaultWidth int) (int, error) {
+	if value == "" {
+		return defaultWidth, nil
+	}
+
+	width, err := strconv.Atoi(value)
+	if err != nil || width < 16 || width > 3840 {
+		return 0, fmt.Errorf("width must be a number between 16 and 3840")
+	}
+
+	return width, nil
+}
@@ -8,7 +8,9 @@ package flipcamlib
 import "github.com/a-h/templ"
 import templruntime "github.com/a-h/templ/runtime"
 
-func Index(playlistPath string) templ.Component {
+// Index is the main page. The controls of the recording are hidden from viewers, the server
+// rejects their use anyway.
+func Index(cameras []CameraStatus, coach bool) templ.Component {
 	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
 		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
 		if templ_7745c5c3_CtxErr := ctx.Err(); templ_7745c5c3_CtxErr != nil {
@@ -29,20 +31,229 @@ func Index(playlistPath string) templ.Component {
 			templ_7745c5c3_Var1 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<!doctype html><html lang=\"en\"><head><meta charset=\"UTF-8\"><title>Flipcam</title><meta name=\"viewport\" content=\"width=device-width, initial-scale=1\"><script type=\"importmap\">\n\t\t\t{\n\t\t\t\t\"imports\": {\n\t\t\t\t\t\"helpers\": \"/static/helpers.mjs\",\n\t\t\t\t\t\"hls\": \"/static/hls.light.mjs\"\n\t\t\t\t}\n\t\t\t}\n\t\t</script><link rel=\"modulepreload\" href=\"/static/helpers.mjs\"><link rel=\"modulepreload\" href=\"/static/hls.light.mjs\"><link rel=\"stylesheet\" href=\"/static/normalize.css\"><link rel=\"stylesheet\" href=\"/static/style.css\"></head><body><main><div id=\"video-container\"><video id=\"video\" controls muted playsinline width=\"1920\" height=\"1080\"></video><button id=\"playback-start\" aria-label=\"Start playback\" class=\"button-icon\"><svg xmlns=\"http://www.w3.org/2000/svg\" viewBox=\"0 -960 960 960\"><path d=\"m380-300 280-180-280-180v360ZM480-80q-83 0-156-31.5T197-197q-54-54-85.5-127T80-480q0-83 31.5-156T197-763q54-54 127-85.5T480-880q83 0 156 31.5T763-763q54 54 85.5 127T880-480q0 83-31.5 156T763-197q-54 54-127 85.5T480-80Zm0-80q134 0 227-93t93-227q0-134-93-227t-227-93q-134 0-227 93t-93 227q0 134 93 227t227 93Zm0-320Z\"></path></svg></button></div><div id=\"controls\"><div id=\"main-controls\" class=\"fully-collapsed\"><div id=\"collapse-controls\"><button id=\"expand-now\" class=\"button-icon\"><svg xmlns=\"http://www.w3.org/2000/svg\" viewBox=\"0 -960 960 960\"><path d=\"m296-345-56-56 240-240 240 240-56 56-184-183-184 183Z\"></path></svg></button> <button id=\"collapse-now\" class=\"button-icon\"><svg xmlns=\"http://www.w3.org/2000/svg\" viewBox=\"0 -960 960 960\"><path d=\"m296-345-56-56 240-240 240 240-56 56-184-183-184 183Z\"></path></svg></button></div><div id=\"controls-left\"><button id=\"playback-speed-reset\" aria-label=\"Reset playback speed\" class=\"button-icon\"><svg aria-hidden=\"true\" xmlns=\"http://www.w3.org/2000/svg\" viewBox=\"0 -960 960 960\"><path d=\"m400-320 240-160-240-160v320Zm80 240q-83 0-156-31.5T197-197q-54-54-85.5-127T80-480q0-43 9-84.5t26-80.5l62 62q-8 26-12.5 51.5T160-480q0 134 93 227t227 93q134 0 227-93t93-227q0-134-93-227t-227-93q-27 0-52.5 4.5T377-783l-61-61q40-18 80-27t84-9q83 0 156 31.5T763-763q54 54 85.5 127T880-480q0 83-31.5 156T763-197q-54 54-127 85.5T480-80ZM220-680q-25 0-42.5-17.5T160-740q0-25 17.5-42.5T220-800q25 0 42.5 17.5T280-740q0 25-17.5 42.5T220-680Zm260 200Z\"></path></svg></button><div id=\"playback-speed-container\"><input id=\"playback-speed-input\" aria-label=\"Playback speed\" type=\"range\" min=\"-5\" max=\"5\" step=\"0.05\"> <span id=\"playback-speed-output\"></span></div></div><div><button onclick=\"video.currentTime -= 5\">-5s</button> <button onclick=\"video.currentTime -= 1\">-1s</button> <button onclick=\"video.currentTime += 1\">+1s</button> <button onclick=\"video.currentTime += 5\">+5s</button></div><div id=\"controls-right\"><span id=\"latency\">?\u00a0s</span> <button id=\"fullscreen-toggle\" class=\"button-icon\" aria-label=\"Go fullscreen\"><svg xmlns=\"http://www.w3.org/2000/svg\" viewBox=\"0 -960 960 960\"><path d=\"M120-120v-200h80v120h120v80H120Zm520 0v-80h120v-120h80v200H640ZM120-640v-200h200v80H200v120h-80Zm640 0v-120H640v-80h200v200h-80Z\"></path></svg></button></div></div><div id=\"goto\">GoTo <button id=\"skip-to-live\">Live</button> <button aria-label=\"Add\" id=\"save-latency\">+</button><div id=\"saved-latencies-container\"><button class=\"button-icon\" aria-label=\"Remove mode\" id=\"remove-latency\"><svg aria-hidden=\"true\" xmlns=\"http://www.w3.org/2000/svg\" viewBox=\"0 -960 960 960\"><path d=\"M280-120q-33 0-56.5-23.5T200-200v-520h-40v-80h200v-40h240v40h200v80h-40v520q0 33-23.5 56.5T680-120H280Zm400-600H280v520h400v-520ZM360-280h80v-360h-80v360Zm160 0h80v-360h-80v360ZM280-720v520-520Z\"></path></svg></button><div id=\"saved-latencies\"></div></div></div></div></main><aside><h2>Settings</h2><div><label for=\"cts-latency\">Camera to server latency</label> <input id=\"cts-latency\" type=\"number\" step=\"100\" value=\"3000\"> ms</div><div><label for=\"playlist-url\">Playlist URL</label> <input id=\"playlist-url\" type=\"url\" value=\"")
-		if templ_7745c5c3_Err != nil {
-			return templ_7745c5c3_Err
-		}
-		var templ_7745c5c3_Var2 string
-		templ_7745c5c3_Var2, templ_7745c5c3_Err = templ.JoinStringErrs(playlistPath)
-		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `pkg/flipcamlib/index.templ`, Line: 101, Col: 59}
-		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var2))
-		if templ_7745c5c3_Err != nil {
-			return templ_7745c5c3_Err
-		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "\" autocomplete=\"off\"></div><button id=\"restart-muxer\">Restart muxer</button></aside><script type=\"module\" src=\"/static/main.mjs\"></script></body></html>")
+		templ_7745c5c3_Var2 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+			templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+			templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+			if !templ_7745c5c3_IsBuffer {
+				defer func() {
+					templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+					if templ_7745c5c3_Err == nil {
+						templ_7745c5c3_Err = templ_7745c5c3_BufErr
+					}
+				}()
+			}
+			ctx = templ.InitializeContext(ctx)
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<main><div id=\"video-container\"><video id=\"video\" controls muted playsinline width=\"1920\" height=\"1080\"></video><canvas id=\"annotation-canvas\"></canvas><div id=\"comment-display\" hidden></div><button id=\"playback-start\" aria-label=\"Start playback\" class=\"button-icon\"><svg xmlns=\"http://www.w3.org/2000/svg\" viewBox=\"0 -960 960 960\"><path d=\"m380-300 280-180-280-180v360ZM480-80q-83 0-156-31.5T197-197q-54-54-85.5-127T80-480q0-83 31.5-156T197-763q54-54 127-85.5T480-880q83 0 156 31.5T763-763q54 54 85.5 127T880-480q0 83-31.5 156T763-197q-54 54-127 85.5T480-80Zm0-80q134 0 227-93t93-227q0-134-93-227t-227-93q-134 0-227 93t-93 227q0 134 93 227t227 93Zm0-320Z\"></path></svg></button></div><div id=\"controls\"><div id=\"main-controls\" class=\"fully-collapsed\"><div id=\"collapse-controls\"><button id=\"expand-now\" class=\"button-icon\"><svg xmlns=\"http://www.w3.org/2000/svg\" viewBox=\"0 -960 960 960\"><path d=\"m296-345-56-56 240-240 240 240-56 56-184-183-184 183Z\"></path></svg></button> <button id=\"collapse-now\" class=\"button-icon\"><svg xmlns=\"http://www.w3.org/2000/svg\" viewBox=\"0 -960 960 960\"><path d=\"m296-345-56-56 240-240 240 240-56 56-184-183-184 183Z\"></path></svg></button></div><div id=\"controls-left\"><button id=\"playback-speed-reset\" aria-label=\"Reset playback speed\" class=\"button-icon\"><svg aria-hidden=\"true\" xmlns=\"http://www.w3.org/2000/svg\" viewBox=\"0 -960 960 960\"><path d=\"m400-320 240-160-240-160v320Zm80 240q-83 0-156-31.5T197-197q-54-54-85.5-127T80-480q0-43 9-84.5t26-80.5l62 62q-8 26-12.5 51.5T160-480q0 134 93 227t227 93q134 0 227-93t93-227q0-134-93-227t-227-93q-27 0-52.5 4.5T377-783l-61-61q40-18 80-27t84-9q83 0 156 31.5T763-763q54 54 85.5 127T880-480q0 83-31.5 156T763-197q-54 54-127 85.5T480-80ZM220-680q-25 0-42.5-17.5T160-740q0-25 17.5-42.5T220-800q25 0 42.5 17.5T280-740q0 25-17.5 42.5T220-680Zm260 200Z\"></path></svg></button><div id=\"playback-speed-container\"><input id=\"playback-speed-input\" aria-label=\"Playback speed\" type=\"range\" min=\"-5\" max=\"5\" step=\"0.05\"> <span id=\"playback-speed-output\"></span></div></div><div><button onclick=\"video.currentTime -= 5\">-5s</button> <button onclick=\"video.currentTime -= 1\">-1s</button> <button onclick=\"video.currentTime += 1\">+1s</button> <button onclick=\"video.currentTime += 5\">+5s</button></div><div id=\"controls-right\"><span id=\"latency\">?\u00a0s</span> <button id=\"snapshot\" class=\"button-icon\" aria-label=\"Save snapshot\"><svg xmlns=\"http://www.w3.org/2000/svg\" viewBox=\"0 -960 960 960\"><path d=\"M480-260q75 0 127.5-52.5T660-440q0-75-52.5-127.5T480-620q-75 0-127.5 52.5T300-440q0 75 52.5 127.5T480-260Zm0-80q-42 0-71-29t-29-71q0-42 29-71t71-29q42 0 71 29t29 71q0 42-29 71t-71 29ZM160-120q-33 0-56.5-23.5T80-200v-480q0-33 23.5-56.5T160-760h126l74-80h240l74 80h126q33 0 56.5 23.5T880-680v480q0 33-23.5 56.5T800-120H160Zm0-80h640v-480H638l-73-80H395l-73 80H160v480Zm320-240Z\"></path></svg></button> <button id=\"fullscreen-toggle\" class=\"button-icon\" aria-label=\"Go fullscreen\"><svg xmlns=\"http://www.w3.org/2000/svg\" viewBox=\"0 -960 960 960\"><path d=\"M120-120v-200h80v120h120v80H120Zm520 0v-80h120v-120h80v200H640ZM120-640v-200h200v80H200v120h-80Zm640 0v-120H640v-80h200v200h-80Z\"></path></svg></button></div></div><div id=\"goto\">GoTo <button id=\"skip-to-live\">Live</button> <button aria-label=\"Add\" id=\"save-latency\">+</button><div id=\"saved-latencies-container\"><button class=\"button-icon\" aria-label=\"Remove mode\" id=\"remove-latency\"><svg aria-hidden=\"true\" xmlns=\"http://www.w3.org/2000/svg\" viewBox=\"0 -960 960 960\"><path d=\"M280-120q-33 0-56.5-23.5T200-200v-520h-40v-80h200v-40h240v40h200v80h-40v520q0 33-23.5 56.5T680-120H280Zm400-600H280v520h400v-520ZM360-280h80v-360h-80v360Zm160 0h80v-360h-80v360ZM280-720v520-520Z\"></path></svg></button><div id=\"saved-latencies\"></div></div></div><div id=\"timeline\"><div id=\"timeline-markers\"></div><input id=\"timeline-input\" aria-label=\"Timeline\" type=\"range\" min=\"0\" max=\"0\" step=\"0.1\" value=\"0\"><div id=\"timeline-sprite\" hidden></div><img id=\"scrub-preview\" alt=\"\" hidden></div></div></main><aside><h2>Settings</h2>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			if len(cameras) > 1 {
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "<div><label for=\"camera\">Camera</label> <select id=\"camera\">")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				for _, camera := range cameras {
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "<option value=\"")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					var templ_7745c5c3_Var3 string
+					templ_7745c5c3_Var3, templ_7745c5c3_Err = templ.JoinStringErrs(camera.Name)
+					if templ_7745c5c3_Err != nil {
+						return templ.Error{Err: templ_7745c5c3_Err, FileName: `pkg/flipcamlib/index.templ`, Line: 93, Col: 34}
+					}
+					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var3))
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 4, "\">")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					var templ_7745c5c3_Var4 string
+					templ_7745c5c3_Var4, templ_7745c5c3_Err = templ.JoinStringErrs(camera.Name)
+					if templ_7745c5c3_Err != nil {
+						return templ.Error{Err: templ_7745c5c3_Err, FileName: `pkg/flipcamlib/index.templ`, Line: 93, Col: 50}
+					}
+					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var4))
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 5, "</option>")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+				}
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 6, "</select> <a href=\"/cameras\">All cameras</a></div>")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 7, "<div><label for=\"cts-latency\">Camera to server latency</label> <input id=\"cts-latency\" type=\"number\" step=\"100\" value=\"3000\"> ms <a id=\"calibrate-link\" href=\"/calibrate\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			if !coach {
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 8, " hidden")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 9, ">Calibrate</a></div><div>Clock offset to server <span id=\"clock-offset\">?</span></div><div><label for=\"playlist-url\">Playlist URL</label> <input id=\"playlist-url\" type=\"url\" value=\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var5 string
+			templ_7745c5c3_Var5, templ_7745c5c3_Err = templ.JoinStringErrs(cameras[0].PlaylistPath)
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `pkg/flipcamlib/index.templ`, Line: 111, Col: 71}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var5))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 10, "\" autocomplete=\"off\"></div><div>Video codec <span id=\"video-codec\">?</span></div><fieldset id=\"transform\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			if !coach {
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 11, " hidden")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 12, "><legend>Video transform</legend><div><input id=\"transform-flip-horizontal\" type=\"checkbox\"> <label for=\"transform-flip-horizontal\">Mirror</label></div><div><input id=\"transform-flip-vertical\" type=\"checkbox\"> <label for=\"transform-flip-vertical\">Upside down</label></div><div><label for=\"transform-rotation\">Rotation</label> <select id=\"transform-rotation\"><option value=\"0\">0°</option> <option value=\"90\">90°</option> <option value=\"180\">180°</option> <option value=\"270\">270°</option></select></div><div>Crop (%) <input id=\"transform-crop-x\" aria-label=\"Crop left\" type=\"number\" min=\"0\" max=\"100\" value=\"0\"> <input id=\"transform-crop-y\" aria-label=\"Crop top\" type=\"number\" min=\"0\" max=\"100\" value=\"0\"> <input id=\"transform-crop-width\" aria-label=\"Crop width\" type=\"number\" min=\"1\" max=\"100\" value=\"100\"> <input id=\"transform-crop-height\" aria-label=\"Crop height\" type=\"number\" min=\"1\" max=\"100\" value=\"100\"></div><button id=\"transform-apply\">Apply transform</button></fieldset><fieldset id=\"overlay\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			if !coach {
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 13, " hidden")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 14, "><legend>Timestamp overlay</legend><div><input id=\"overlay-enabled\" type=\"checkbox\"> <label for=\"overlay-enabled\">Show overlay</label></div><div><label for=\"overlay-athlete-name\">Athlete</label> <input id=\"overlay-athlete-name\" type=\"text\" autocomplete=\"off\"></div><div><label for=\"overlay-position\">Position</label> <select id=\"overlay-position\"><option value=\"top-left\">Top left</option> <option value=\"top-right\">Top right</option> <option value=\"bottom-left\">Bottom left</option> <option value=\"bottom-right\">Bottom right</option></select></div><div><label for=\"overlay-size\">Size</label> <input id=\"overlay-size\" type=\"number\" min=\"1\" max=\"50\" step=\"0.5\" value=\"4\"> %</div><button id=\"overlay-apply\">Apply overlay</button></fieldset><fieldset id=\"broadcast-delay\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			if !coach {
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 15, " hidden")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 16, "><legend>Broadcast delay</legend><div><input id=\"broadcast-delay-enabled\" type=\"checkbox\"> <label for=\"broadcast-delay-enabled\">Move every viewer to this delay</label></div><div><label for=\"broadcast-delay-seconds\">Delay</label> <input id=\"broadcast-delay-seconds\" type=\"number\" min=\"0\" max=\"3600\" step=\"1\" value=\"10\"> s</div><button id=\"broadcast-delay-apply\">Apply delay</button></fieldset><fieldset id=\"clip\"><legend>Clip</legend><div><button id=\"clip-mark-start\">Mark in</button> <span id=\"clip-start\">-</span> <button id=\"clip-mark-end\">Mark out</button> <span id=\"clip-end\">-</span></div><div><label for=\"clip-name\">Name</label> <input id=\"clip-name\" type=\"text\" autocomplete=\"off\"> <button id=\"clip-save\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			if !coach {
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 17, " hidden")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 18, ">Save clip</button> <a href=\"/compare\">Compare clips</a></div><div><label for=\"clip-athlete\">Athlete</label> <select id=\"clip-athlete\"></select> <button id=\"clip-assign\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			if !coach {
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 19, " hidden")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 20, ">Assign clip</button> <a href=\"/athletes\">Athletes</a></div><div><label for=\"composite-interval\">Composite interval</label> <input id=\"composite-interval\" type=\"number\" min=\"0.05\" step=\"0.05\" value=\"0.2\"> s</div><button id=\"clip-export\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			if !coach {
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 21, " hidden")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 22, ">Export MP4</button> <button id=\"composite-create\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			if !coach {
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 23, " hidden")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 24, ">Create composite</button><div id=\"clip-jobs\"></div></fieldset><fieldset id=\"annotations\"><legend>Annotations</legend><div><label for=\"annotation-kind\">Shape</label> <select id=\"annotation-kind\"><option value=\"line\">Line</option> <option value=\"angle\">Angle</option> <option value=\"circle\">Circle</option> <option value=\"freehand\">Freehand</option> <option value=\"text\">Text</option></select> <input id=\"annotation-color\" aria-label=\"Color\" type=\"color\" value=\"#ff0000\"></div><div><label for=\"annotation-text\">Text</label> <input id=\"annotation-text\" type=\"text\" autocomplete=\"off\"></div><div><label for=\"annotation-duration\">Show for</label> <input id=\"annotation-duration\" type=\"number\" min=\"0.1\" step=\"0.1\" value=\"3\"> s</div><button id=\"annotation-draw\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			if !coach {
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 25, " hidden")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 26, ">Draw</button><ul id=\"annotation-list\"></ul></fieldset><fieldset id=\"comments\"><legend>Comments</legend><div><button id=\"comment-record\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			if !coach {
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 27, " hidden")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 28, ">Record voice comment</button></div><div><label for=\"comment-text\">Note</label> <input id=\"comment-text\" type=\"text\" autocomplete=\"off\"> <button id=\"comment-add\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			if !coach {
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 29, " hidden")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 30, ">Add note</button></div><div><input id=\"comment-autoplay\" type=\"checkbox\" checked> <label for=\"comment-autoplay\">Play comments during replay</label></div><ul id=\"comment-list\"></ul></fieldset><button id=\"restart-muxer\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			if !coach {
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 31, " hidden")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 32, ">Restart muxer</button> <button id=\"session-delete\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			if !coach {
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 33, " hidden")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 34, ">Delete session</button> <a href=\"/trust\">Trust this server</a> <a href=\"/coach\">")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			if coach {
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 35, "Coach mode")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			} else {
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 36, "Unlock coach mode")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 37, "</a></aside>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			return nil
+		})
+		templ_7745c5c3_Err = page("Flipcam", "main.mjs").Render(templ.WithChildren(ctx, templ_7745c5c3_Var2), templ_7745c5c3_Buffer)
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -66,38 +277,38 @@ func button(name string, content string) templ.Component {
 			}()
 		}
 		ctx = templ.InitializeContext(ctx)
-		templ_7745c5c3_Var3 := templ.GetChildren(ctx)
-		if templ_7745c5c3_Var3 == nil {
-			templ_7745c5c3_Var3 = templ.NopComponent
+		templ_7745c5c3_Var6 := templ.GetChildren(ctx)
+		if templ_7745c5c3_Var6 == nil {
+			templ_7745c5c3_Var6 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "<button value=\"")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 38, "<button value=\"")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		var templ_7745c5c3_Var4 string
-		templ_7745c5c3_Var4, templ_7745c5c3_Err = templ.JoinStringErrs(name)
+		var templ_7745c5c3_Var7 string
+		templ_7745c5c3_Var7, templ_7745c5c3_Err = templ.JoinStringErrs(name)
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `pkg/flipcamlib/index.templ`, Line: 111, Col: 21}
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `pkg/flipcamlib/index.templ`, Line: 269, Col: 21}
 		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var4))
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var7))
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 4, "\">")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 39, "\">")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		var templ_7745c5c3_Var5 string
-		templ_7745c5c3_Var5, templ_7745c5c3_Err = templ.JoinStringErrs(content)
+		var templ_7745c5c3_Var8 string
+		templ_7745c5c3_Var8, templ_7745c5c3_Err = templ.JoinStringErrs(content)
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `pkg/flipcamlib/index.templ`, Line: 111, Col: 33}
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `pkg/flipcamlib/index.templ`, Line: 269, Col: 33}
 		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var5))
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var8))
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 5, "</button>")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 40, "</button>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -0,0 +1,199 @@
+package flipcamlib
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"time"
+)
+
+// JobState is the state of a clip-processing job.
+type JobState string
+
+const (
+	JobQueued  JobState = "queued"
+	JobRunning JobState = "running"
+	JobDone    JobState = "done"
+	JobFailed  JobState = "failed"
+)
+
+// Job is a long-running clip-processing task such as rendering a composite image.
+// Jobs are executed one at a time, in order, by runJobs.
+type Job struct {
+	Id        string    `json:"id"`
+	Kind      string    `json:"kind"`
+	State     JobState  `json:"state"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	// ResultUrl is the URL at which the result can be downloaded once the job is done.
+	ResultUrl string `json:"resultUrl"`
+
+	contentType string
+	filename    string
+	resultPath  string
+	run         jobFunc
+
+	// finishedAt is the time at which the job was done or failed, see expireJobs.
+	finishedAt time.Time
+}
+
+// jobFunc executes the job and writes the result to resultPath.
+type jobFunc func(ctx context.Context, resultPath string) error
+
+const jobsDir = "jobs"
+
+// jobTtl is the time a finished job, and its result, are kept after it finished.
+const jobTtl = 1 * time.Hour
+
+// queueJob adds a job to the queue and returns it.
+// filename is the name of the result as suggested to the browser, its extension is also used
+// for the file on disk.
+func (f *FlipCam) queueJob(kind string, filename string, contentType string, run jobFunc) (Job, error) {
+	f.expireJobs(time.Now())
+
+	id := rand.Text()[:10]
+	job := &Job{
+		Id:        id,
+		Kind:      kind,
+		State:     JobQueued,
+		CreatedAt: time.Now(),
+		ResultUrl: "/api/jobs/" + id + "/result",
+
+		contentType: contentType,
+		filename:    filename,
+		resultPath:  path.Join(f.hlsOutputDir, jobsDir, id+path.Ext(filename)),
+		run:         run,
+	}
+
+	f.jobsMu.Lock()
+	f.jobs[id] = job
+	f.jobsMu.Unlock()
+
+	select {
+	case f.jobQueue <- job:
+	default:
+		f.setJobState(job, JobFailed, fmt.Errorf("too many jobs queued"))
+		return *job, fmt.Errorf("too many jobs queued, try again later")
+	}
+
+	return f.getJob(id)
+}
+
+func (f *FlipCam) getJob(id string) (Job, error) {
+	f.jobsMu.Lock()
+	defer f.jobsMu.Unlock()
+	job, found := f.jobs[id]
+	if !found {
+		return Job{}, fmt.Errorf("job %s not found", id)
+	}
+
+	return *job, nil
+}
+
+func (f *FlipCam) setJobState(job *Job, state JobState, err error) {
+	f.jobsMu.Lock()
+	defer f.jobsMu.Unlock()
+	job.State = state
+	if err != nil {
+		job.Error = err.Error()
+	}
+	if state == JobDone || state == JobFailed {
+		job.finishedAt = time.Now()
+	}
+}
+
+// expireJobs forgets the jobs that finished more than jobTtl before now and deletes their
+// results.
+func (f *FlipCam) expireJobs(now time.Time) {
+	f.jobsMu.Lock()
+	var expired []*Job
+	for id, job := range f.jobs {
+		if !job.finishedAt.IsZero() && now.Sub(job.finishedAt) > jobTtl {
+			expired = append(expired, job)
+			delete(f.jobs, id)
+		}
+	}
+	f.jobsMu.Unlock()
+
+	for _, job := range expired {
+		err := os.Remove(job.resultPath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("[jobs]: failed to delete result of job %s: %v\n", job.Id, err)
+		}
+	}
+}
+
+// runJobs executes the queued jobs one at a time.
+func (f *FlipCam) runJobs(ctx context.Context) {
+	// Results of the previous run can't be downloaded anymore as the jobs are not stored
+	err := os.RemoveAll(path.Join(f.hlsOutputDir, jobsDir))
+	if err != nil {
+		log.Printf("[jobs]: failed to delete the results of the previous run: %v\n", err)
+	}
+
+	f.startupWg.Done()
+	defer f.shutdownWg.Done() // Add occurred in calling function
+
+	jobCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-f.stop
+		cancel()
+	}()
+
+	for {
+		var job *Job
+		select {
+		case <-f.stop:
+			return
+		case job = <-f.jobQueue:
+		}
+
+		f.setJobState(job, JobRunning, nil)
+		err := os.MkdirAll(path.Dir(job.resultPath), 0o755)
+		if err == nil {
+			err = job.run(jobCtx, job.resultPath)
+		}
+		if err != nil {
+			log.Printf("[jobs]: %s job %s failed: %v\n", job.Kind, job.Id, err)
+			f.setJobState(job, JobFailed, err)
+			continue
+		}
+
+		log.Printf("[jobs]: %s job %s done\n", job.Kind, job.Id)
+		f.setJobState(job, JobDone, nil)
+	}
+}
+
+func (f *FlipCam) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	job, err := f.getJob(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	writeJson(w, job)
+}
+
+func (f *FlipCam) handleJobResult(w http.ResponseWriter, r *http.Request) {
+	job, err := f.getJob(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if job.State != JobDone {
+		http.Error(w, fmt.Sprintf("job is %s", job.State), http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", job.filename))
+	w.Header().Set("Content-Type", job.contentType)
+	http.ServeFile(w, r, job.resultPath)
+}
@@ -0,0 +1,46 @@
+package flipcamlib
+
+import (
+	"errors"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestExpireJobs(t *testing.T) {
+	f := New(Opts{HlsOutputDir: t.TempDir()})
+	now := time.Now()
+	jobs := map[string]*Job{
+		"expired":  {Id: "expired", State: JobDone, finishedAt: now.Add(-jobTtl - time.Second)},
+		"failed":   {Id: "failed", State: JobFailed, finishedAt: now.Add(-jobTtl - time.Second)},
+		"finished": {Id: "finished", State: JobDone, finishedAt: now.Add(-time.Minute)},
+		"running":  {Id: "running", State: JobRunning},
+	}
+	err := os.MkdirAll(path.Join(f.hlsOutputDir, jobsDir), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, job := range jobs {
+		job.resultPath = path.Join(f.hlsOutputDir, jobsDir, id+".png")
+		err := os.WriteFile(job.resultPath, nil, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.jobs[id] = job
+	}
+
+	f.expireJobs(now)
+
+	for id, job := range jobs {
+		wantKept := id == "finished" || id == "running"
+		_, kept := f.jobs[id]
+		if kept != wantKept {
+			t.Errorf("job %s kept = %v, want %v", id, kept, wantKept)
+		}
+		_, err := os.Stat(job.resultPath)
+		if resultKept := !errors.Is(err, os.ErrNotExist); resultKept != wantKept {
+			t.Errorf("result of job %s kept = %v, want %v", id, resultKept, wantKept)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package flipcamlib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/MatthiasKunnen/flipcam/pkg/flipcamlib/storage"
+)
+
+// LatencySource is how the camera to server latency was determined.
+type LatencySource string
+
+const (
+	// LatencyCalibration is a measurement using the timecode of the calibration page.
+	LatencyCalibration LatencySource = "calibration"
+
+	// LatencyEncoderClock is derived from the wall-clock time reported by the encoder.
+	LatencyEncoderClock LatencySource = "encoder-clock"
+)
+
+// LatencyEstimate is the estimated delay between the camera and the server.
+type LatencyEstimate struct {
+	// CameraToServerMs is the time between the capture of a frame and its program date time.
+	// Zero if unknown.
+	CameraToServerMs float64 `json:"cameraToServerMs"`
+
+	// Source is empty if the latency is unknown.
+	Source LatencySource `json:"source,omitempty"`
+
+	// ArrivalDelayMs is how much later than the fastest packet the latest packets of the stream
+	// arrived. It grows when the network is congested.
+	ArrivalDelayMs float64 `json:"arrivalDelayMs"`
+}
+
+// arrivalStats describe when the packets of a session arrived.
+// A packet's stream start is its arrival time minus its timestamp, this is the time the stream
+// would have started if every packet was delayed like that packet. The program date time of the
+// playlist is anchored to the first stream start.
+type arrivalStats struct {
+	sessionId string
+
+	firstStart  time.Time
+	bestStart   time.Time
+	latestStart time.Time
+
+	// encoderStart is the time at which the encoder started the stream according to its clock.
+	encoderStart time.Time
+}
+
+// anchorDelay returns how much later than the fastest packet the program date time was
+// anchored.
+func (a arrivalStats) anchorDelay() time.Duration {
+	return a.firstStart.Sub(a.bestStart)
+}
+
+// latencyCalibration is the stored result of a calibration.
+type latencyCalibration struct {
+	// CaptureToArrivalMs is the time between the capture of a frame and the arrival of the
+	// fastest packets. Unlike the camera to server latency, it does not depend on the session.
+	CaptureToArrivalMs float64   `json:"captureToArrivalMs"`
+	MeasuredAt         time.Time `json:"measuredAt"`
+}
+
+// latencyCalibrationKey returns the settings key of the calibration of the camera.
+func latencyCalibrationKey(c *camera) string {
+	return "latencyCalibration/" + c.name
+}
+
+// resetArrivalStats starts tracking the arrivals of a new session.
+func (c *camera) resetArrivalStats(sessionId string) {
+	c.arrivalMu.Lock()
+	defer c.arrivalMu.Unlock()
+	c.arrival = arrivalStats{sessionId: sessionId}
+}
+
+// recordArrival records that the packet with the given timestamp arrived.
+func (c *camera) recordArrival(sessionId string, arrival time.Time, outTime time.Duration) {
+	start := arrival.Add(-outTime)
+	c.arrivalMu.Lock()
+	defer c.arrivalMu.Unlock()
+	if c.arrival.sessionId != sessionId {
+		return
+	}
+
+	if c.arrival.firstStart.IsZero() {
+		c.arrival.firstStart = start
+	}
+	if c.arrival.bestStart.IsZero() || start.Before(c.arrival.bestStart) {
+		c.arrival.bestStart = start
+	}
+	c.arrival.latestStart = start
+}
+
+func (c *camera) setEncoderStart(sessionId string, t time.Time) {
+	c.arrivalMu.Lock()
+	defer c.arrivalMu.Unlock()
+	if c.arrival.sessionId == sessionId {
+		c.arrival.encoderStart = t
+	}
+}
+
+func (c *camera) getArrivalStats() arrivalStats {
+	c.arrivalMu.Lock()
+	defer c.arrivalMu.Unlock()
+	return c.arrival
+}
+
+// latencyEstimate returns the best available estimate for the current session of the camera.
+// A calibration is preferred over the clock of the encoder, which is often not synchronized.
+func (f *FlipCam) latencyEstimate(c *camera) (LatencyEstimate, error) {
+	arrival := c.getArrivalStats()
+	var estimate LatencyEstimate
+	if !arrival.latestStart.IsZero() {
+		estimate.ArrivalDelayMs = durationMillis(arrival.latestStart.Sub(arrival.bestStart))
+	}
+
+	var calibration latencyCalibration
+	err := f.store.View(func(tx storage.Tx) error {
+		return tx.Get(storage.BucketSettings, latencyCalibrationKey(c), &calibration)
+	})
+	switch {
+	case err == nil:
+		estimate.CameraToServerMs = calibration.CaptureToArrivalMs +
+			durationMillis(arrival.anchorDelay())
+		estimate.Source = LatencyCalibration
+	case !errors.Is(err, storage.ErrNotFound):
+		return LatencyEstimate{}, err
+	case !arrival.encoderStart.IsZero() && !arrival.firstStart.IsZero():
+		estimate.CameraToServerMs = durationMillis(arrival.firstStart.Sub(arrival.encoderStart))
+		estimate.Source = LatencyEncoderClock
+	}
+
+	return estimate, nil
+}
+
+func durationMillis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+func (f *FlipCam) handleGetLatency(w http.ResponseWriter, r *http.Request) {
+	c, err := f.requestCamera(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	estimate, err := f.latencyEstimate(c)
+	if err != nil {
+		log.Printf("web: latency: %v\n", err)
+		http.Error(w, "failed to estimate latency", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-cache")
+	writeJson(w, estimate)
+}
+
+const (
+	// calibrationDuration is the part of the current session, before its end, that is searched
+	// for the timecode.
+	calibrationDuration  = 3 * time.Second
+	calibrationFps       = 20
+	calibrationWidth     = 960
+	calibrationMinFrames = 3
+)
+
+// handleCalibrateLatency measures the camera to server latency by decoding the timecode of the
+// calibration page, filmed by the camera, from the end of the current session.
+func (f *FlipCam) handleCalibrateLatency(w http.ResponseWriter, r *http.Request) {
+	c, err := f.requestCamera(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	session, err := f.currentSession(c)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	latency, err := f.measureLatency(ctx, session)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	arrival := c.getArrivalStats()
+	if arrival.sessionId != session.Id {
+		arrival = arrivalStats{}
+	}
+	err = f.saveSetting(latencyCalibrationKey(c), latencyCalibration{
+		CaptureToArrivalMs: durationMillis(latency - arrival.anchorDelay()),
+		MeasuredAt:         time.Now(),
+	})
+	if err != nil {
+		log.Printf("web: latency: %v\n", err)
+		http.Error(w, "failed to save calibration", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("web: camera to server latency of %s calibrated at %s\n", c.name, latency)
+
+	f.handleGetLatency(w, r)
+}
+
+// measureLatency returns the median difference between the program date time of the frames at
+// the end of the session and the timecode they show.
+func (f *FlipCam) measureLatency(ctx context.Context, session Session) (time.Duration, error) {
+	playlist, err := session.readPlaylist()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read playlist: %w", err)
+	}
+
+	segments := playlist.Segments
+	for i := len(segments) - 1; i >= 0; i-- {
+		if segments[len(segments)-1].End().Sub(segments[i].ProgramDateTime) >= calibrationDuration {
+			segments = segments[i:]
+			break
+		}
+	}
+	if len(segments) == 0 || segments[0].ProgramDateTime.IsZero() {
+		return 0, errors.New("the session has no video yet")
+	}
+
+	input, err := session.openSegments(playlist.MapUri, segments)
+	if err != nil {
+		return 0, err
+	}
+	defer input.Close()
+
+	output, err := runFfmpegLowPriority(ctx, input,
+		"-f", "mov",
+		"-i", "pipe:0",
+		"-vf", fmt.Sprintf("fps=%d,scale=%d:-2,format=gray", calibrationFps, calibrationWidth),
+		"-f", "image2pipe",
+		"-c:v", "pgm",
+		"pipe:1",
+	)
+	if err != nil {
+		return 0, err
+	}
+	frames, err := readPgmFrames(output)
+	if err != nil {
+		return 0, err
+	}
+
+	var latencies []time.Duration
+	for i, frame := range frames {
+		value, ok := decodeTimecode(frame)
+		if !ok {
+			continue
+		}
+
+		frameTime := segments[0].ProgramDateTime.Add(time.Duration(i) * time.Second / calibrationFps)
+		latencies = append(latencies, frameTime.Sub(timecodeTime(value, frameTime)))
+	}
+	if len(latencies) < calibrationMinFrames {
+		return 0, fmt.Errorf(
+			"the timecode was found in %d of %d frames, make sure the camera films the whole "+
+				"calibration page",
+			len(latencies),
+			len(frames),
+		)
+	}
+
+	slices.Sort(latencies)
+	return latencies[len(latencies)/2], nil
+}
+
+func (f *FlipCam) handleCalibratePage(w http.ResponseWriter, r *http.Request) {
+	err := Calibrate().Render(r.Context(), w)
+	if err != nil {
+		log.Printf("web: failed to render: %v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
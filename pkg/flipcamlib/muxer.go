@@ -6,24 +6,38 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"net/url"
 	"os"
 	"path"
 	"sync"
 	"time"
 )
 
-func (f *FlipCam) runMuxer(ctx context.Context) {
+// runMuxers runs a muxer for every camera.
+func (f *FlipCam) runMuxers(ctx context.Context) {
+	// The first Add occurred in the calling function
+	f.startupWg.Add(len(f.cameras) - 1)
+	f.shutdownWg.Add(len(f.cameras) - 1)
+	for _, c := range f.cameras {
+		go f.runMuxer(ctx, c)
+	}
+}
+
+func (f *FlipCam) runMuxer(ctx context.Context, c *camera) {
 	reportStarted := sync.OnceFunc(f.startupWg.Done)
 	internalRestartChan := make(chan chan struct{}, 1)
 	muxer := RtmpToHlsMuxer{
-		Url: "rtmp://0.0.0.0:1935/camera/",
+		Name: c.name,
+		Url:  fmt.Sprintf("rtmp://%s/camera/%s", c.muxerAddr(), f.streamName(c)),
 	}
 	numOfRestarts := -1
+	var prefix string
+	var startedAt time.Time
+
+	// Restart requests received while waiting to restart, answered when the next run started.
+	var pendingRestarts []chan struct{}
 
 	for {
 		numOfRestarts++
-		var prefix string
 		for {
 			prefix = rand.Text()[:6]
 			_, err := os.Stat(path.Join(f.hlsOutputDir, prefix+".m3u8"))
@@ -31,18 +45,66 @@ func (f *FlipCam) runMuxer(ctx context.Context) {
 				break
 			}
 		}
+		startedAt = time.Now()
+		c.resetArrivalStats(prefix)
 		muxer.Prefix = prefix + "_"
 		newPlaylistFile := prefix + ".m3u8"
 		muxer.PlaylistPath = path.Join(f.hlsOutputDir, newPlaylistFile)
-		err := f.setPlayListUrlPath(newPlaylistFile)
+		err := c.setPlayListUrlPath(newPlaylistFile)
 		if err != nil {
-			f.stopWithError(fmt.Errorf("[muxer]: failed to set playlist path: %w", err))
+			f.stopWithError(fmt.Errorf("[muxer %s]: failed to set playlist path: %w", c.name, err))
 			return
 		}
 
+		transcode := f.shouldTranscode(c)
+		muxer.Transcode = transcode
+		muxer.Filters, err = f.videoFilters(c, prefix)
+		if err != nil {
+			f.stopWithError(fmt.Errorf("[muxer %s]: %w", c.name, err))
+			return
+		}
+		reencoding := transcode || len(muxer.Filters) > 0
+		c.setTranscoding(reencoding)
+		record := SessionRecord{
+			Id:          prefix,
+			Camera:      c.name,
+			StartedAt:   startedAt,
+			Transcoding: reencoding,
+		}
+		f.recordSession(record)
+		muxer.OnProgress = func(outTime time.Duration) {
+			c.recordArrival(prefix, time.Now(), outTime)
+		}
+		muxer.OnEncoderTime = func(t time.Time) {
+			c.setEncoderStart(prefix, t)
+		}
+		// In auto mode, the run was set up for the codec of the previous publisher. It is
+		// restarted when the codec of this publisher needs the other mode.
+		transcodeChanged := make(chan struct{}, 1)
+		muxer.OnVideoCodec = func(codec string) {
+			c.setVideoCodec(codec)
+			record.VideoCodec = codec
+			f.recordSession(record)
+			if f.transcodePolicy != TranscodeAuto {
+				return
+			}
+			switch needsTranscode := codecNeedsTranscode(codec); {
+			case needsTranscode && !reencoding:
+				log.Printf("[muxer %s]: %s is not supported by all clients, restarting with transcoding\n", c.name, codec)
+			case !needsTranscode && transcode:
+				log.Printf("[muxer %s]: %s is supported by all clients, restarting without transcoding\n", c.name, codec)
+			default:
+				return
+			}
+			select {
+			case transcodeChanged <- struct{}{}:
+			default:
+			}
+		}
+
 		err = muxer.Start()
 		if err != nil {
-			log.Printf("[muxer]: failed to start: %v\n", err)
+			log.Printf("[muxer %s]: failed to start: %v\n", c.name, err)
 			if numOfRestarts == 0 {
 				// If the muxer fails to start on first start, give up
 				f.stopWithError(fmt.Errorf("failed to start muxer: %w", err))
@@ -56,8 +118,14 @@ func (f *FlipCam) runMuxer(ctx context.Context) {
 			close(done)
 		default:
 		}
+		for _, done := range pendingRestarts {
+			close(done)
+		}
+		pendingRestarts = nil
 
 		runEnd := make(chan struct{})
+		f.shutdownWg.Add(1)
+		go f.watchSession(Session{Id: prefix, Dir: f.hlsOutputDir}, runEnd)
 		if numOfRestarts > 0 {
 			// The first Add occurred in calling function
 			f.shutdownWg.Add(1)
@@ -66,10 +134,11 @@ func (f *FlipCam) runMuxer(ctx context.Context) {
 			defer f.shutdownWg.Done()
 			select {
 			case <-f.stop:
-			case done := <-f.restartMuxer:
+			case done := <-c.restart:
 				if done != nil {
 					internalRestartChan <- done
 				}
+			case <-transcodeChanged:
 			case <-runEnd:
 				return
 			}
@@ -78,36 +147,50 @@ func (f *FlipCam) runMuxer(ctx context.Context) {
 			defer cancel()
 			err := muxer.Shutdown(ctx)
 			if err != nil {
-				f.addShutdownError(fmt.Errorf("[muxer]: error during shutdown: %w", err))
+				f.addShutdownError(fmt.Errorf("[muxer %s]: error during shutdown: %w", c.name, err))
 			}
 		}()
 
 		err = muxer.Wait()
 		if err != nil && !errors.Is(err, os.ErrProcessDone) {
-			log.Printf("[muxer]: exited with error: %v\n", err)
+			log.Printf("[muxer %s]: exited with error: %v\n", c.name, err)
 		}
 		close(runEnd)
 
 		select {
 		case <-f.stop:
 			// If the muxer was stopped by request from the main thread, do not restart
-			log.Println("[muxer]: shutdown cleanly")
+			log.Printf("[muxer %s]: shutdown cleanly\n", c.name)
 			return
 		default:
-			log.Println("[muxer]: restarting")
-			time.Sleep(1 * time.Second)
+		}
+
+		log.Printf("[muxer %s]: restarting\n", c.name)
+		select {
+		case <-f.stop:
+			log.Printf("[muxer %s]: shutdown cleanly\n", c.name)
+			return
+		case done := <-c.restart:
+			// Restart requests are not delayed
+			pendingRestarts = append(pendingRestarts, done)
+		case <-time.After(1 * time.Second):
 		}
 	}
 }
 
-func (f *FlipCam) setPlayListUrlPath(playlistFile string) error {
-	newPath, err := url.JoinPath(f.hlsUrlPathPrefix, playlistFile)
+// videoFilters returns the filters the muxer must apply to the video of the session of the
+// camera.
+func (f *FlipCam) videoFilters(c *camera, sessionId string) ([]string, error) {
+	filters := c.getTransform().filters()
+
+	// The overlay is drawn last so that it is not affected by the transform
+	overlay, err := f.overlayFilter(c, sessionId)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if overlay != "" {
+		filters = append(filters, overlay)
 	}
 
-	f.hlsPlayListPathMu.Lock()
-	f.hlsPlayListPath = newPath
-	f.hlsPlayListPathMu.Unlock()
-	return nil
+	return filters, nil
 }
@@ -0,0 +1,152 @@
+package flipcamlib
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// Overlay describes the text that is burned into the video.
+// The overlay shows the wall-clock time, which uses the same clock as the program date time of
+// the playlist, the session and, optionally, the name of the athlete.
+type Overlay struct {
+	Enabled bool `json:"enabled"`
+
+	// AthleteName is shown below the session if not empty.
+	AthleteName string `json:"athleteName"`
+
+	Position OverlayPosition `json:"position"`
+
+	// Size is the height of a line of text as a fraction of the video height.
+	Size float64 `json:"size"`
+}
+
+// OverlayPosition is the corner of the video in which the overlay is drawn.
+type OverlayPosition string
+
+const (
+	OverlayTopLeft     OverlayPosition = "top-left"
+	OverlayTopRight    OverlayPosition = "top-right"
+	OverlayBottomLeft  OverlayPosition = "bottom-left"
+	OverlayBottomRight OverlayPosition = "bottom-right"
+)
+
+const defaultOverlaySize = 0.04
+
+func (o Overlay) Validate() error {
+	switch o.Position {
+	case OverlayTopLeft, OverlayTopRight, OverlayBottomLeft, OverlayBottomRight:
+	default:
+		return fmt.Errorf("unknown overlay position %q", o.Position)
+	}
+
+	if o.Size <= 0 || o.Size > 0.5 {
+		return fmt.Errorf("overlay size must be larger than 0 and at most 0.5")
+	}
+
+	if strings.ContainsAny(o.AthleteName, "\r\n") {
+		return fmt.Errorf("athlete name must not contain line breaks")
+	}
+
+	return nil
+}
+
+// filter returns the drawtext filter that draws the overlay.
+// textFile is the file containing the text, see overlayText.
+// fontFile is optional, when empty, the default font of ffmpeg's fontconfig is used.
+func (o Overlay) filter(textFile string, fontFile string) string {
+	margin := "h*0.02"
+	x := margin
+	y := margin
+	switch o.Position {
+	case OverlayTopRight:
+		x = "w-tw-" + margin
+	case OverlayBottomLeft:
+		y = "h-th-" + margin
+	case OverlayBottomRight:
+		x = "w-tw-" + margin
+		y = "h-th-" + margin
+	}
+
+	options := []string{
+		"textfile=" + escapeFilterValue(textFile),
+		"fontsize=h*" + formatFilterFloat(o.Size),
+		"fontcolor=white",
+		"box=1",
+		"boxcolor=black@0.5",
+		"boxborderw=8",
+		"x=" + x,
+		"y=" + y,
+	}
+	if fontFile != "" {
+		options = append(options, "fontfile="+escapeFilterValue(fontFile))
+	}
+
+	return "drawtext=" + strings.Join(options, ":")
+}
+
+// overlayText returns the contents of the drawtext text file.
+func (o Overlay) overlayText(sessionId string) string {
+	lines := []string{
+		// Time at which the frame is processed, this is the clock used for program_date_time.
+		// The text file is not unescaped as an option value, only the colons inside the format
+		// are escaped so that they don't end the argument.
+		`%{localtime:%Y-%m-%d %H\:%M\:%S}`,
+		"Session " + escapeDrawtext(sessionId),
+	}
+	if o.AthleteName != "" {
+		lines = append(lines, escapeDrawtext(o.AthleteName))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// escapeDrawtext escapes text so that drawtext does not expand it.
+func escapeDrawtext(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`).Replace(v)
+}
+
+// escapeFilterValue escapes a value of a filter option so that it can be used in a filtergraph.
+func escapeFilterValue(v string) string {
+	// First level, the option value
+	v = strings.NewReplacer(`\`, `\\`, `'`, `\'`, `:`, `\:`).Replace(v)
+	// Second level, the filtergraph
+	return strings.NewReplacer(
+		`\`, `\\`,
+		`'`, `\'`,
+		`[`, `\[`,
+		`]`, `\]`,
+		`,`, `\,`,
+		`;`, `\;`,
+	).Replace(v)
+}
+
+func (c *camera) getOverlay() Overlay {
+	c.videoSettingsMu.RLock()
+	defer c.videoSettingsMu.RUnlock()
+	return c.overlay
+}
+
+func (c *camera) setOverlay(o Overlay) {
+	c.videoSettingsMu.Lock()
+	defer c.videoSettingsMu.Unlock()
+	c.overlay = o
+}
+
+// overlayFilter writes the overlay text of the session of the camera and returns the filter that
+// draws it. Returns an empty string if the overlay is disabled.
+func (f *FlipCam) overlayFilter(c *camera, sessionId string) (string, error) {
+	overlay := c.getOverlay()
+	if !overlay.Enabled {
+		return "", nil
+	}
+
+	textFile := path.Join(f.hlsOutputDir, sessionId+"_overlay.txt")
+	err := os.WriteFile(textFile, []byte(overlay.overlayText(sessionId)), 0o644)
+	if err != nil {
+		return "", fmt.Errorf("failed to write overlay text: %w", err)
+	}
+
+	return overlay.filter(textFile, f.overlayFontFile), nil
+}
@@ -0,0 +1,250 @@
+package flipcamlib
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MediaPlaylist is a parsed HLS media playlist as written by the muxer.
+// Only the tags that ffmpeg writes are supported.
+type MediaPlaylist struct {
+	// Ended is true if the playlist contains EXT-X-ENDLIST, no more segments will be added.
+	Ended bool
+
+	// IFramesOnly is true for EXT-X-I-FRAMES-ONLY playlists.
+	IFramesOnly bool
+
+	// MapUri is the URI of the initialization segment, relative to the playlist.
+	MapUri string
+
+	MediaSequence  int
+	Segments       []MediaSegment
+	TargetDuration int
+}
+
+// MediaSegment is a segment, or part of a segment if ByteRangeLength is set, of a playlist.
+type MediaSegment struct {
+	// ByteRangeLength and ByteRangeOffset are set if the segment is a byte range of Uri.
+	// ByteRangeLength is zero if the entire resource is the segment.
+	ByteRangeLength int64
+	ByteRangeOffset int64
+
+	Duration time.Duration
+
+	// MediaTime is the start of the segment relative to the start of the playlist.
+	// This is equal to the currentTime of a video element that plays the playlist.
+	MediaTime time.Duration
+
+	// ProgramDateTime is the wall-clock time of the first sample of the segment.
+	// Zero if unknown.
+	ProgramDateTime time.Time
+
+	// Sequence is the media sequence number of the segment.
+	Sequence int
+
+	// Uri relative to the playlist.
+	Uri string
+}
+
+// End returns the wall-clock time at which the segment ends.
+func (s MediaSegment) End() time.Time {
+	return s.ProgramDateTime.Add(s.Duration)
+}
+
+// SegmentAt returns the segment that contains the wall-clock time t.
+func (p *MediaPlaylist) SegmentAt(t time.Time) (MediaSegment, bool) {
+	for _, segment := range p.Segments {
+		if segment.ProgramDateTime.IsZero() {
+			continue
+		}
+		if !t.Before(segment.ProgramDateTime) && t.Before(segment.End()) {
+			return segment, true
+		}
+	}
+
+	return MediaSegment{}, false
+}
+
+// MediaTimeAt returns the media time at which the wall-clock time t is shown.
+func (p *MediaPlaylist) MediaTimeAt(t time.Time) (time.Duration, bool) {
+	segment, ok := p.SegmentAt(t)
+	if !ok {
+		return 0, false
+	}
+
+	return segment.MediaTime + t.Sub(segment.ProgramDateTime), true
+}
+
+// SegmentsBetween returns the segments that overlap the media time range [start, end).
+func (p *MediaPlaylist) SegmentsBetween(start time.Duration, end time.Duration) []MediaSegment {
+	var segments []MediaSegment
+	for _, segment := range p.Segments {
+		if segment.MediaTime+segment.Duration <= start || segment.MediaTime >= end {
+			continue
+		}
+		segments = append(segments, segment)
+	}
+
+	return segments
+}
+
+func readMediaPlaylist(playlistPath string) (*MediaPlaylist, error) {
+	file, err := os.Open(playlistPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return parseMediaPlaylist(file)
+}
+
+func parseMediaPlaylist(r io.Reader) (*MediaPlaylist, error) {
+	playlist := &MediaPlaylist{}
+	s := bufio.NewScanner(r)
+
+	var segment MediaSegment
+	var mediaTime time.Duration
+	var nextByteRangeOffset int64
+	first := true
+
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if first {
+			if line != "#EXTM3U" {
+				return nil, fmt.Errorf("playlist does not start with #EXTM3U")
+			}
+			first = false
+			continue
+		}
+
+		tag, value, _ := strings.Cut(line, ":")
+		switch tag {
+		case "":
+			continue
+		case "#EXT-X-ENDLIST":
+			playlist.Ended = true
+		case "#EXT-X-I-FRAMES-ONLY":
+			playlist.IFramesOnly = true
+		case "#EXT-X-MAP":
+			playlist.MapUri = parseAttributes(value)["URI"]
+		case "#EXT-X-MEDIA-SEQUENCE":
+			sequence, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid media sequence %q: %w", value, err)
+			}
+			playlist.MediaSequence = sequence
+		case "#EXT-X-TARGETDURATION":
+			duration, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid target duration %q: %w", value, err)
+			}
+			playlist.TargetDuration = duration
+		case "#EXT-X-PROGRAM-DATE-TIME":
+			pdt, err := time.Parse("2006-01-02T15:04:05.999999999Z0700", value)
+			if err != nil {
+				pdt, err = time.Parse(time.RFC3339Nano, value)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid program date time %q: %w", value, err)
+			}
+			segment.ProgramDateTime = pdt
+		case "#EXTINF":
+			durationStr, _, _ := strings.Cut(value, ",")
+			seconds, err := strconv.ParseFloat(durationStr, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid segment duration %q: %w", value, err)
+			}
+			segment.Duration = time.Duration(math.Round(seconds * float64(time.Second)))
+		case "#EXT-X-BYTERANGE":
+			lengthStr, offsetStr, hasOffset := strings.Cut(value, "@")
+			length, err := strconv.ParseInt(lengthStr, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid byte range %q: %w", value, err)
+			}
+			offset := nextByteRangeOffset
+			if hasOffset {
+				offset, err = strconv.ParseInt(offsetStr, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid byte range %q: %w", value, err)
+				}
+			}
+			segment.ByteRangeLength = length
+			segment.ByteRangeOffset = offset
+			nextByteRangeOffset = offset + length
+		default:
+			if strings.HasPrefix(line, "#") {
+				continue
+			}
+
+			segment.Uri = line
+			segment.MediaTime = mediaTime
+			segment.Sequence = playlist.MediaSequence + len(playlist.Segments)
+			if segment.ProgramDateTime.IsZero() && len(playlist.Segments) > 0 {
+				// The date time of a segment without the tag follows from the previous one
+				previous := playlist.Segments[len(playlist.Segments)-1]
+				if !previous.ProgramDateTime.IsZero() {
+					segment.ProgramDateTime = previous.End()
+				}
+			}
+			mediaTime += segment.Duration
+			playlist.Segments = append(playlist.Segments, segment)
+			segment = MediaSegment{}
+		}
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	if first {
+		return nil, fmt.Errorf("playlist is empty")
+	}
+
+	return playlist, nil
+}
+
+// parseAttributes parses an attribute list such as URI="init.mp4",BYTERANGE="10@0".
+func parseAttributes(value string) map[string]string {
+	attributes := make(map[string]string)
+	for len(value) > 0 {
+		name, rest, found := strings.Cut(value, "=")
+		if !found {
+			break
+		}
+
+		var attrValue string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.IndexByte(rest[1:], '"')
+			if end == -1 {
+				attrValue = rest[1:]
+				rest = ""
+			} else {
+				attrValue = rest[1 : end+1]
+				rest = rest[end+2:]
+			}
+		} else {
+			attrValue, rest, _ = strings.Cut(rest, ",")
+		}
+
+		attributes[strings.TrimSpace(name)] = attrValue
+		value = strings.TrimPrefix(rest, ",")
+	}
+
+	return attributes
+}
+
+// formatSeconds formats a duration as seconds for use in playlists.
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 6, 64)
+}
+
+// formatProgramDateTime formats the time the same way ffmpeg does.
+func formatProgramDateTime(t time.Time) string {
+	return t.Format("2006-01-02T15:04:05.000Z0700")
+}
@@ -0,0 +1,204 @@
+package flipcamlib
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseMediaPlaylist(t *testing.T) {
+	start := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		playlist string
+		want     *MediaPlaylist
+		wantErr  bool
+	}{
+		{
+			name: "muxer playlist",
+			playlist: `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-TARGETDURATION:2
+#EXT-X-MEDIA-SEQUENCE:3
+#EXT-X-PLAYLIST-TYPE:EVENT
+#EXT-X-MAP:URI="AbCdEf_init.mp4"
+#EXT-X-PROGRAM-DATE-TIME:2025-06-01T10:00:00.000+0000
+#EXTINF:1.500000,
+AbCdEf_3.mp4
+#EXTINF:1.000000,
+AbCdEf_4.mp4
+#EXT-X-ENDLIST
+`,
+			want: &MediaPlaylist{
+				Ended:          true,
+				MapUri:         "AbCdEf_init.mp4",
+				MediaSequence:  3,
+				TargetDuration: 2,
+				Segments: []MediaSegment{
+					{
+						Duration:        1500 * time.Millisecond,
+						ProgramDateTime: start,
+						Sequence:        3,
+						Uri:             "AbCdEf_3.mp4",
+					},
+					{
+						Duration:        time.Second,
+						MediaTime:       1500 * time.Millisecond,
+						ProgramDateTime: start.Add(1500 * time.Millisecond),
+						Sequence:        4,
+						Uri:             "AbCdEf_4.mp4",
+					},
+				},
+			},
+		},
+		{
+			name: "I-frame byte ranges",
+			playlist: `#EXTM3U
+#EXT-X-I-FRAMES-ONLY
+#EXTINF:1.000000,
+#EXT-X-BYTERANGE:100@20
+seg.mp4
+#EXTINF:1.000000,
+#EXT-X-BYTERANGE:50
+seg.mp4
+`,
+			want: &MediaPlaylist{
+				IFramesOnly: true,
+				Segments: []MediaSegment{
+					{
+						ByteRangeLength: 100,
+						ByteRangeOffset: 20,
+						Duration:        time.Second,
+						Uri:             "seg.mp4",
+					},
+					{
+						ByteRangeLength: 50,
+						ByteRangeOffset: 120,
+						Duration:        time.Second,
+						MediaTime:       time.Second,
+						Sequence:        1,
+						Uri:             "seg.mp4",
+					},
+				},
+			},
+		},
+		{
+			name:     "no header",
+			playlist: "#EXTINF:1.0,\nseg.mp4\n",
+			wantErr:  true,
+		},
+		{
+			name:     "empty",
+			playlist: "",
+			wantErr:  true,
+		},
+		{
+			name:     "invalid duration",
+			playlist: "#EXTM3U\n#EXTINF:one,\nseg.mp4\n",
+			wantErr:  true,
+		},
+		{
+			name:     "invalid byte range",
+			playlist: "#EXTM3U\n#EXT-X-BYTERANGE:100@x\nseg.mp4\n",
+			wantErr:  true,
+		},
+		{
+			name:     "invalid program date time",
+			playlist: "#EXTM3U\n#EXT-X-PROGRAM-DATE-TIME:yesterday\nseg.mp4\n",
+			wantErr:  true,
+		},
+		{
+			name:     "invalid media sequence",
+			playlist: "#EXTM3U\n#EXT-X-MEDIA-SEQUENCE:-x\n",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseMediaPlaylist(strings.NewReader(tt.playlist))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseMediaPlaylist() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			// ffmpeg writes the time with a +0000 offset instead of Z
+			for i := range got.Segments {
+				got.Segments[i].ProgramDateTime = got.Segments[i].ProgramDateTime.UTC()
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseMediaPlaylist() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMediaPlaylistMediaTimeAt(t *testing.T) {
+	start := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	playlist := &MediaPlaylist{Segments: []MediaSegment{
+		{Duration: time.Second, ProgramDateTime: start},
+		{Duration: time.Second, MediaTime: time.Second, ProgramDateTime: start.Add(time.Second)},
+	}}
+
+	tests := []struct {
+		name   string
+		t      time.Time
+		want   time.Duration
+		wantOk bool
+	}{
+		{
+			name:   "first segment",
+			t:      start.Add(200 * time.Millisecond),
+			want:   200 * time.Millisecond,
+			wantOk: true,
+		},
+		{
+			name:   "second segment",
+			t:      start.Add(1500 * time.Millisecond),
+			want:   1500 * time.Millisecond,
+			wantOk: true,
+		},
+		{name: "before the playlist", t: start.Add(-time.Millisecond)},
+		{name: "after the playlist", t: start.Add(2 * time.Second)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := playlist.MediaTimeAt(tt.t)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("MediaTimeAt() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestParseAttributes(t *testing.T) {
+	tests := []struct {
+		value string
+		want  map[string]string
+	}{
+		{
+			value: `URI="init.mp4",BYTERANGE="10@0"`,
+			want:  map[string]string{"URI": "init.mp4", "BYTERANGE": "10@0"},
+		},
+		{
+			value: `BANDWIDTH=1000,CODECS="avc1.64001f,mp4a.40.2"`,
+			want:  map[string]string{"BANDWIDTH": "1000", "CODECS": "avc1.64001f,mp4a.40.2"},
+		},
+		{
+			value: `URI="unterminated`,
+			want:  map[string]string{"URI": "unterminated"},
+		},
+		{
+			value: `garbage`,
+			want:  map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		got := parseAttributes(tt.value)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseAttributes(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,388 @@
+package flipcamlib
+
+import (
+	"cmp"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/MatthiasKunnen/flipcam/pkg/flipcamlib/storage"
+	"github.com/go-json-experiment/json"
+)
+
+// Roster links recordings to the athletes of a club. It is stored locally and has no accounts.
+type Roster struct {
+	Athletes    []Athlete    `json:"athletes"`
+	Groups      []Group      `json:"groups"`
+	Assignments []Assignment `json:"assignments"`
+}
+
+type Athlete struct {
+	Id       string   `json:"id"`
+	Name     string   `json:"name"`
+	GroupIds []string `json:"groupIds"`
+}
+
+// Group is a set of athletes, e.g. a training group or age category.
+type Group struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Assignment links a part of a session to an athlete, it forms the athlete's clip library.
+type Assignment struct {
+	Id        string    `json:"id"`
+	AthleteId string    `json:"athleteId"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	ClipRange
+}
+
+var errRosterInvalid = errors.New("invalid")
+
+// getRoster returns the roster. Athletes and groups are ordered by name, assignments newest
+// first.
+func (f *FlipCam) getRoster() (Roster, error) {
+	var roster Roster
+	err := f.store.View(func(tx storage.Tx) error {
+		var err error
+		roster.Athletes, err = storage.List[Athlete](tx, storage.BucketAthletes, "")
+		if err != nil {
+			return err
+		}
+		roster.Groups, err = storage.List[Group](tx, storage.BucketGroups, "")
+		if err != nil {
+			return err
+		}
+		roster.Assignments, err = storage.List[Assignment](tx, storage.BucketAssignments, "")
+		return err
+	})
+	if err != nil {
+		return Roster{}, err
+	}
+
+	slices.SortFunc(roster.Athletes, func(a, b Athlete) int {
+		return cmp.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	})
+	slices.SortFunc(roster.Groups, func(a, b Group) int {
+		return cmp.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	})
+	slices.SortStableFunc(roster.Assignments, func(a, b Assignment) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return roster, nil
+}
+
+func validateGroupIds(tx storage.Tx, groupIds []string) error {
+	for _, groupId := range groupIds {
+		var group Group
+		err := tx.Get(storage.BucketGroups, groupId, &group)
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			return fmt.Errorf("group %s is %w", groupId, errRosterInvalid)
+		case err != nil:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AthleteClips returns the assignments of the athlete, newest first.
+func (r *Roster) AthleteClips(athleteId string) []Assignment {
+	var clips []Assignment
+	for _, assignment := range r.Assignments {
+		if assignment.AthleteId == athleteId {
+			clips = append(clips, assignment)
+		}
+	}
+
+	return clips
+}
+
+// GroupNames returns the names of the groups of the athlete.
+func (r *Roster) GroupNames(athlete Athlete) []string {
+	var names []string
+	for _, group := range r.Groups {
+		if slices.Contains(athlete.GroupIds, group.Id) {
+			names = append(names, group.Name)
+		}
+	}
+
+	return names
+}
+
+// writeRosterError responds with the status that matches the error of a roster update.
+func writeRosterError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, errRosterInvalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("web: roster: %v\n", err)
+		http.Error(w, "failed to update roster", http.StatusInternalServerError)
+	}
+}
+
+func validateRosterName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 100 {
+		return "", fmt.Errorf("name must be between 1 and 100 characters")
+	}
+
+	return name, nil
+}
+
+func (f *FlipCam) handleGetRoster(w http.ResponseWriter, r *http.Request) {
+	roster, err := f.getRoster()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJson(w, roster)
+}
+
+// handleSaveAthlete creates an athlete, or updates it if the id path value is present.
+func (f *FlipCam) handleSaveAthlete(w http.ResponseWriter, r *http.Request) {
+	var athlete Athlete
+	err := json.UnmarshalRead(r.Body, &athlete)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid athlete: %v", err), http.StatusBadRequest)
+		return
+	}
+	athlete.Name, err = validateRosterName(athlete.Name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if athlete.GroupIds == nil {
+		athlete.GroupIds = []string{}
+	}
+
+	athlete.Id = r.PathValue("id")
+	err = f.store.Update(func(tx storage.Tx) error {
+		err := validateGroupIds(tx, athlete.GroupIds)
+		if err != nil {
+			return err
+		}
+
+		if athlete.Id == "" {
+			athlete.Id = rand.Text()[:10]
+		} else {
+			err = tx.Get(storage.BucketAthletes, athlete.Id, &Athlete{})
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.Put(storage.BucketAthletes, athlete.Id, athlete)
+	})
+	if err != nil {
+		writeRosterError(w, err)
+		return
+	}
+
+	writeJson(w, athlete)
+}
+
+// handleDeleteAthlete removes the athlete and their assignments. The recordings are kept.
+func (f *FlipCam) handleDeleteAthlete(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	err := f.store.Update(func(tx storage.Tx) error {
+		err := tx.Delete(storage.BucketAthletes, id)
+		if err != nil {
+			return err
+		}
+
+		assignments, err := storage.List[Assignment](tx, storage.BucketAssignments, "")
+		if err != nil {
+			return err
+		}
+		for _, assignment := range assignments {
+			if assignment.AthleteId != id {
+				continue
+			}
+			err = tx.Delete(storage.BucketAssignments, assignment.Id)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		writeRosterError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (f *FlipCam) handleCreateGroup(w http.ResponseWriter, r *http.Request) {
+	var group Group
+	err := json.UnmarshalRead(r.Body, &group)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid group: %v", err), http.StatusBadRequest)
+		return
+	}
+	group.Name, err = validateRosterName(group.Name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	group.Id = rand.Text()[:10]
+	err = f.store.Update(func(tx storage.Tx) error {
+		return tx.Put(storage.BucketGroups, group.Id, group)
+	})
+	if err != nil {
+		writeRosterError(w, err)
+		return
+	}
+
+	writeJson(w, group)
+}
+
+// handleDeleteGroup removes the group. Its athletes are kept.
+func (f *FlipCam) handleDeleteGroup(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	err := f.store.Update(func(tx storage.Tx) error {
+		err := tx.Delete(storage.BucketGroups, id)
+		if err != nil {
+			return err
+		}
+
+		athletes, err := storage.List[Athlete](tx, storage.BucketAthletes, "")
+		if err != nil {
+			return err
+		}
+		for _, athlete := range athletes {
+			if !slices.Contains(athlete.GroupIds, id) {
+				continue
+			}
+			athlete.GroupIds = slices.DeleteFunc(athlete.GroupIds, func(groupId string) bool {
+				return groupId == id
+			})
+			err = tx.Put(storage.BucketAthletes, athlete.Id, athlete)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		writeRosterError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (f *FlipCam) handleCreateAssignment(w http.ResponseWriter, r *http.Request) {
+	var assignment Assignment
+	err := json.UnmarshalRead(r.Body, &assignment)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid assignment: %v", err), http.StatusBadRequest)
+		return
+	}
+	err = assignment.ClipRange.Validate()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := f.getSession(assignment.SessionId); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	assignment.Note = strings.TrimSpace(assignment.Note)
+	if len(assignment.Note) > 500 {
+		http.Error(w, "note can be at most 500 characters", http.StatusBadRequest)
+		return
+	}
+
+	assignment.Id = rand.Text()[:10]
+	assignment.CreatedAt = time.Now()
+	err = f.store.Update(func(tx storage.Tx) error {
+		err := tx.Get(storage.BucketAthletes, assignment.AthleteId, &Athlete{})
+		if err != nil {
+			return err
+		}
+
+		return tx.Put(storage.BucketAssignments, assignment.Id, assignment)
+	})
+	if err != nil {
+		writeRosterError(w, err)
+		return
+	}
+
+	writeJson(w, assignment)
+}
+
+func (f *FlipCam) handleDeleteAssignment(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	err := f.store.Update(func(tx storage.Tx) error {
+		return tx.Delete(storage.BucketAssignments, id)
+	})
+	if err != nil {
+		writeRosterError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (f *FlipCam) handleRosterPage(w http.ResponseWriter, r *http.Request) {
+	roster, err := f.getRoster()
+	if err != nil {
+		log.Printf("web: roster: %v\n", err)
+		http.Error(w, "failed to read roster", http.StatusInternalServerError)
+		return
+	}
+
+	err = RosterPage(roster).Render(r.Context(), w)
+	if err != nil {
+		log.Printf("web: failed to render: %v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (f *FlipCam) handleAthletePage(w http.ResponseWriter, r *http.Request) {
+	roster, err := f.getRoster()
+	if err != nil {
+		log.Printf("web: roster: %v\n", err)
+		http.Error(w, "failed to read roster", http.StatusInternalServerError)
+		return
+	}
+
+	i := slices.IndexFunc(roster.Athletes, func(a Athlete) bool {
+		return a.Id == r.PathValue("id")
+	})
+	if i == -1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	err = AthletePage(roster, roster.Athletes[i], f.hlsUrlPathPrefix).Render(r.Context(), w)
+	if err != nil {
+		log.Printf("web: failed to render: %v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// formatClipLabel describes a clip of a session, e.g. for a list of clips.
+func formatClipLabel(clip ClipRange, createdAt time.Time) string {
+	return fmt.Sprintf(
+		"%s, session %s, %.1f–%.1f s",
+		createdAt.Format("2006-01-02 15:04"),
+		clip.SessionId,
+		clip.Start,
+		clip.End,
+	)
+}
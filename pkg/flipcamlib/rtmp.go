@@ -0,0 +1,439 @@
+package flipcamlib
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// The parts of RTMP that the router needs to learn the stream name of a publisher, see
+// rtmp_router.go. Only the simple handshake is supported, which is what the RTMP server of ffmpeg
+// offers as well.
+
+const rtmpVersion = 3
+const rtmpHandshakeSize = 1536
+const rtmpDefaultChunkSize = 128
+
+// rtmpMaxMessageSize is the largest message that is read before the publisher is accepted.
+// Commands are much smaller, media is not sent before publishing.
+const rtmpMaxMessageSize = 64 * 1024
+
+// rtmpMaxChunkStreams limits the number of chunk streams a publisher can open before it is
+// accepted, as each may hold a partial message.
+const rtmpMaxChunkStreams = 16
+
+// Message types.
+const (
+	rtmpSetChunkSize     = 1
+	rtmpAcknowledgement  = 3
+	rtmpUserControl      = 4
+	rtmpWindowAckSize    = 5
+	rtmpSetPeerBandwidth = 6
+	rtmpCommandAmf3      = 17
+	rtmpCommandAmf0      = 20
+)
+
+// Chunk stream IDs used for the messages of the router.
+const (
+	rtmpControlChunkStream = 2
+	rtmpCommandChunkStream = 3
+)
+
+var errRtmpVersion = errors.New("unsupported RTMP version")
+
+// rtmpServerHandshake performs the handshake of the server. C0 and C1 are read exactly, so
+// nothing after the handshake is consumed from r.
+func rtmpServerHandshake(rw io.ReadWriter) error {
+	c0c1 := make([]byte, 1+rtmpHandshakeSize)
+	_, err := io.ReadFull(rw, c0c1)
+	if err != nil {
+		return err
+	}
+	if c0c1[0] != rtmpVersion {
+		return errRtmpVersion
+	}
+
+	// S0, S1 with a zero time and version followed by random bytes, S2 echoes C1
+	s0s1s2 := make([]byte, 1+2*rtmpHandshakeSize)
+	s0s1s2[0] = rtmpVersion
+	_, _ = rand.Read(s0s1s2[9 : 1+rtmpHandshakeSize])
+	copy(s0s1s2[1+rtmpHandshakeSize:], c0c1[1:])
+	_, err = rw.Write(s0s1s2)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.ReadFull(rw, make([]byte, rtmpHandshakeSize))
+	return err
+}
+
+// rtmpClientHandshake performs the handshake of the client.
+func rtmpClientHandshake(rw io.ReadWriter) error {
+	c0c1 := make([]byte, 1+rtmpHandshakeSize)
+	c0c1[0] = rtmpVersion
+	_, _ = rand.Read(c0c1[9:])
+	_, err := rw.Write(c0c1)
+	if err != nil {
+		return err
+	}
+
+	s0s1s2 := make([]byte, 1+2*rtmpHandshakeSize)
+	_, err = io.ReadFull(rw, s0s1s2)
+	if err != nil {
+		return err
+	}
+	if s0s1s2[0] != rtmpVersion {
+		return errRtmpVersion
+	}
+
+	// C2 echoes S1
+	_, err = rw.Write(s0s1s2[1 : 1+rtmpHandshakeSize])
+	return err
+}
+
+type rtmpMessage struct {
+	typeId   byte
+	streamId uint32
+	payload  []byte
+}
+
+// rtmpChunkStream is the state of a chunk stream, headers of later chunks only contain the
+// fields that changed.
+type rtmpChunkStream struct {
+	length            uint32
+	typeId            byte
+	streamId          uint32
+	extendedTimestamp bool
+
+	payload []byte
+}
+
+// rtmpChunkReader reads the messages of a peer from its chunks.
+type rtmpChunkReader struct {
+	r         *bufio.Reader
+	chunkSize uint32
+	streams   map[uint32]*rtmpChunkStream
+}
+
+func newRtmpChunkReader(r io.Reader) *rtmpChunkReader {
+	return &rtmpChunkReader{
+		r:         bufio.NewReader(r),
+		chunkSize: rtmpDefaultChunkSize,
+		streams:   make(map[uint32]*rtmpChunkStream),
+	}
+}
+
+// readMessage reads chunks until a message is complete. Set Chunk Size messages are applied.
+func (cr *rtmpChunkReader) readMessage() (rtmpMessage, error) {
+	for {
+		msg, complete, err := cr.readChunk()
+		if err != nil {
+			return rtmpMessage{}, err
+		}
+		if !complete {
+			continue
+		}
+
+		if msg.typeId == rtmpSetChunkSize {
+			if len(msg.payload) < 4 {
+				return rtmpMessage{}, errors.New("set chunk size message is too short")
+			}
+			size := binary.BigEndian.Uint32(msg.payload) & 0x7fffffff
+			if size == 0 {
+				return rtmpMessage{}, errors.New("chunk size is 0")
+			}
+			cr.chunkSize = size
+		}
+
+		return msg, nil
+	}
+}
+
+func (cr *rtmpChunkReader) readChunk() (msg rtmpMessage, complete bool, err error) {
+	first, err := cr.r.ReadByte()
+	if err != nil {
+		return msg, false, err
+	}
+	format := first >> 6
+	id := uint32(first & 0x3f)
+	switch id {
+	case 0:
+		b, err := cr.r.ReadByte()
+		if err != nil {
+			return msg, false, err
+		}
+		id = 64 + uint32(b)
+	case 1:
+		var b [2]byte
+		_, err := io.ReadFull(cr.r, b[:])
+		if err != nil {
+			return msg, false, err
+		}
+		id = 64 + uint32(b[0]) + uint32(b[1])*256
+	}
+
+	cs, found := cr.streams[id]
+	if !found {
+		if format != 0 {
+			return msg, false, fmt.Errorf("chunk stream %d starts without a full header", id)
+		}
+		if len(cr.streams) >= rtmpMaxChunkStreams {
+			return msg, false, errors.New("too many chunk streams")
+		}
+		cs = &rtmpChunkStream{}
+		cr.streams[id] = cs
+	}
+
+	headerSizes := [4]int{11, 7, 3, 0}
+	var header [11]byte
+	_, err = io.ReadFull(cr.r, header[:headerSizes[format]])
+	if err != nil {
+		return msg, false, err
+	}
+	if format < 3 {
+		if len(cs.payload) > 0 {
+			return msg, false, fmt.Errorf("chunk stream %d interrupts its message", id)
+		}
+		// The timestamp itself is not needed
+		cs.extendedTimestamp = header[0] == 0xff && header[1] == 0xff && header[2] == 0xff
+	}
+	if format < 2 {
+		cs.length = uint32(header[3])<<16 | uint32(header[4])<<8 | uint32(header[5])
+		cs.typeId = header[6]
+		if cs.length > rtmpMaxMessageSize {
+			return msg, false, fmt.Errorf("message of %d bytes is too large", cs.length)
+		}
+	}
+	if format == 0 {
+		cs.streamId = binary.LittleEndian.Uint32(header[7:11])
+	}
+	if cs.extendedTimestamp {
+		_, err = io.ReadFull(cr.r, make([]byte, 4))
+		if err != nil {
+			return msg, false, err
+		}
+	}
+
+	size := min(cs.length-uint32(len(cs.payload)), cr.chunkSize)
+	start := len(cs.payload)
+	cs.payload = append(cs.payload, make([]byte, size)...)
+	_, err = io.ReadFull(cr.r, cs.payload[start:])
+	if err != nil {
+		return msg, false, err
+	}
+	if uint32(len(cs.payload)) < cs.length {
+		return msg, false, nil
+	}
+
+	msg = rtmpMessage{typeId: cs.typeId, streamId: cs.streamId, payload: cs.payload}
+	cs.payload = nil
+	return msg, true, nil
+}
+
+// writeRtmpMessage writes a message with a zero timestamp in chunks of the default size.
+func writeRtmpMessage(w io.Writer, chunkStream byte, msg rtmpMessage) error {
+	header := []byte{
+		chunkStream, // Format 0
+		0, 0, 0,
+		byte(len(msg.payload) >> 16), byte(len(msg.payload) >> 8), byte(len(msg.payload)),
+		msg.typeId,
+	}
+	header = binary.LittleEndian.AppendUint32(header, msg.streamId)
+
+	chunks := header
+	for payload := msg.payload; ; {
+		n := min(len(payload), rtmpDefaultChunkSize)
+		chunks = append(chunks, payload[:n]...)
+		payload = payload[n:]
+		if len(payload) == 0 {
+			break
+		}
+		chunks = append(chunks, 0xc0|chunkStream) // Format 3
+	}
+
+	_, err := w.Write(chunks)
+	return err
+}
+
+// rtmpControlMessage returns a protocol control message with a single 32-bit value.
+func rtmpControlMessage(typeId byte, value uint32) rtmpMessage {
+	return rtmpMessage{typeId: typeId, payload: binary.BigEndian.AppendUint32(nil, value)}
+}
+
+// amf0Object is an AMF0 object whose properties are encoded in order.
+type amf0Object []amf0Property
+
+type amf0Property struct {
+	Name  string
+	Value any
+}
+
+// encodeAmf0 encodes float64, bool, string, nil, and amf0Object values.
+func encodeAmf0(values ...any) []byte {
+	var b []byte
+	for _, value := range values {
+		b = appendAmf0(b, value)
+	}
+
+	return b
+}
+
+func appendAmf0(b []byte, value any) []byte {
+	switch v := value.(type) {
+	case float64:
+		b = append(b, 0)
+		return binary.BigEndian.AppendUint64(b, math.Float64bits(v))
+	case bool:
+		if v {
+			return append(b, 1, 1)
+		}
+		return append(b, 1, 0)
+	case string:
+		b = append(b, 2)
+		return appendAmf0String(b, v)
+	case amf0Object:
+		b = append(b, 3)
+		for _, property := range v {
+			b = appendAmf0String(b, property.Name)
+			b = appendAmf0(b, property.Value)
+		}
+		return append(b, 0, 0, 9)
+	default:
+		return append(b, 5) // null
+	}
+}
+
+func appendAmf0String(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// amf0MaxDepth limits the nesting of objects and arrays.
+const amf0MaxDepth = 8
+
+var errAmf0Truncated = errors.New("amf0: truncated value")
+
+// decodeAmf0 decodes the values of a command. Numbers are float64, objects and ECMA arrays
+// map[string]any, strict arrays []any, null and undefined nil.
+func decodeAmf0(b []byte) ([]any, error) {
+	var values []any
+	for len(b) > 0 {
+		value, rest, err := decodeAmf0Value(b, 0)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		b = rest
+	}
+
+	return values, nil
+}
+
+func decodeAmf0Value(b []byte, depth int) (any, []byte, error) {
+	if len(b) == 0 {
+		return nil, nil, errAmf0Truncated
+	}
+	if depth > amf0MaxDepth {
+		return nil, nil, errors.New("amf0: nested too deep")
+	}
+
+	marker, b := b[0], b[1:]
+	switch marker {
+	case 0: // Number
+		if len(b) < 8 {
+			return nil, nil, errAmf0Truncated
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), b[8:], nil
+	case 1: // Boolean
+		if len(b) < 1 {
+			return nil, nil, errAmf0Truncated
+		}
+		return b[0] != 0, b[1:], nil
+	case 2: // String
+		return decodeAmf0String(b, 2)
+	case 3: // Object
+		return decodeAmf0Properties(b, depth)
+	case 5, 6: // Null, undefined
+		return nil, b, nil
+	case 8: // ECMA array, the count is only a hint
+		if len(b) < 4 {
+			return nil, nil, errAmf0Truncated
+		}
+		return decodeAmf0Properties(b[4:], depth)
+	case 10: // Strict array
+		if len(b) < 4 {
+			return nil, nil, errAmf0Truncated
+		}
+		count := binary.BigEndian.Uint32(b)
+		b = b[4:]
+		// Every value takes at least a byte
+		if uint64(count) > uint64(len(b)) {
+			return nil, nil, errAmf0Truncated
+		}
+		values := make([]any, count)
+		for i := range values {
+			value, rest, err := decodeAmf0Value(b, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			values[i] = value
+			b = rest
+		}
+		return values, b, nil
+	case 11: // Date, milliseconds and a time zone that is not used
+		if len(b) < 10 {
+			return nil, nil, errAmf0Truncated
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), b[10:], nil
+	case 12: // Long string
+		return decodeAmf0String(b, 4)
+	default:
+		return nil, nil, fmt.Errorf("amf0: unsupported type %d", marker)
+	}
+}
+
+// decodeAmf0String decodes a string whose length is stored in lengthSize bytes.
+func decodeAmf0String(b []byte, lengthSize int) (any, []byte, error) {
+	if len(b) < lengthSize {
+		return nil, nil, errAmf0Truncated
+	}
+	var length uint64
+	if lengthSize == 2 {
+		length = uint64(binary.BigEndian.Uint16(b))
+	} else {
+		length = uint64(binary.BigEndian.Uint32(b))
+	}
+	b = b[lengthSize:]
+	if length > uint64(len(b)) {
+		return nil, nil, errAmf0Truncated
+	}
+
+	return string(b[:length]), b[length:], nil
+}
+
+func decodeAmf0Properties(b []byte, depth int) (any, []byte, error) {
+	properties := make(map[string]any)
+	for {
+		if len(b) < 3 {
+			return nil, nil, errAmf0Truncated
+		}
+		if b[0] == 0 && b[1] == 0 && b[2] == 9 {
+			return properties, b[3:], nil
+		}
+
+		name, rest, err := decodeAmf0String(b, 2)
+		if err != nil {
+			return nil, nil, err
+		}
+		value, rest, err := decodeAmf0Value(rest, depth+1)
+		if err != nil {
+			return nil, nil, err
+		}
+		properties[name.(string)] = value
+		b = rest
+	}
+}
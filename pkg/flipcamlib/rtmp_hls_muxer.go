@@ -9,7 +9,9 @@ import (
 	"log"
 	"os/exec"
 	"path"
+	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
@@ -17,6 +19,8 @@ import (
 )
 
 type RtmpToHlsMuxer struct {
+	// Name identifies the muxer in the log, e.g. the name of the camera.
+	Name string
 
 	// The URL to start listening on for incoming RTMP streams.
 	Url string
@@ -27,6 +31,26 @@ type RtmpToHlsMuxer struct {
 	// The path where the playlist file should be written.
 	PlaylistPath string
 
+	// Transcode makes the muxer re-encode the video to H.264 instead of copying the incoming
+	// codec.
+	Transcode bool
+
+	// Filters are ffmpeg video filters that are applied, in order, to the video.
+	// Filters require re-encoding, setting them implies Transcode.
+	Filters []string
+
+	// OnVideoCodec, if set, is called with the codec of the incoming video stream as soon as
+	// ffmpeg reports it. E.g. h264 or hevc.
+	OnVideoCodec func(codec string)
+
+	// OnEncoderTime, if set, is called with the wall-clock time at which the encoder started the
+	// stream, if the stream carries it.
+	OnEncoderTime func(t time.Time)
+
+	// OnProgress, if set, is called for every progress report of ffmpeg with the timestamp of the
+	// last muxed packet, relative to the start of the stream. Reports are made twice per second.
+	OnProgress func(outTime time.Duration)
+
 	cmd     *exec.Cmd
 	mu      sync.Mutex
 	stdin   io.WriteCloser
@@ -53,25 +77,8 @@ func (m *RtmpToHlsMuxer) Start() error {
 
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	destinationDir := path.Dir(m.PlaylistPath)
-	cmd := exec.Command("ffmpeg",
-		"-loglevel", "warning",
-		"-listen", "1", // Wait for connection
-		"-i", m.Url,
-		"-rtmp_live", "live",
-		"-rtmp_buffer", "1000",
-		"-c:v", "copy",
-		"-an", // Drop audio, wasted bytes underwater
-		"-f", "hls",
-		"-hls_list_size", "0",
-		"-hls_segment_type", "fmp4",
-		"-hls_time", "1",
-		"-hls_flags", "program_date_time+split_by_time",
-		"-hls_playlist_type", "event",
-		"-hls_segment_filename", path.Join(destinationDir, m.Prefix+"%d.mp4"),
-		"-hls_fmp4_init_filename", m.Prefix+"init.mp4",
-		m.PlaylistPath)
-	log.Printf("[muxer]: cmd: %s\n", cmd)
+	cmd := exec.Command("ffmpeg", m.args()...)
+	log.Printf("[muxer %s]: cmd: %s\n", m.Name, cmd)
 
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
@@ -91,19 +98,60 @@ func (m *RtmpToHlsMuxer) Start() error {
 	go func() {
 		s := bufio.NewScanner(stdout)
 		for s.Scan() {
-			log.Printf("[muxer]: ffmpeg stdout: %s\n", s.Text())
+			key, value, found := strings.Cut(s.Text(), "=")
+			switch {
+			case !found:
+				log.Printf("[muxer %s]: ffmpeg stdout: %s\n", m.Name, s.Text())
+			case key == "out_time_us" && m.OnProgress != nil:
+				us, err := strconv.ParseInt(value, 10, 64)
+				if err == nil {
+					m.OnProgress(time.Duration(us) * time.Microsecond)
+				}
+			}
 		}
 		if err := s.Err(); err != nil {
-			log.Printf("[muxer]: error processing stdout: %v\n", err)
+			log.Printf("[muxer %s]: error processing stdout: %v\n", m.Name, err)
 		}
 	}()
 	go func() {
 		s := bufio.NewScanner(stderr)
+		inInput := false
+		codecReported := false
 		for s.Scan() {
-			log.Printf("[muxer]: ffmpeg stderr: %s\n", s.Text())
+			level, msg := parseFfmpegLogLine(s.Text())
+			switch {
+			case strings.HasPrefix(msg, "Input #"):
+				inInput = true
+			case strings.HasPrefix(msg, "Output #"), strings.HasPrefix(msg, "Stream mapping:"):
+				inInput = false
+			}
+
+			if inInput && m.OnEncoderTime != nil {
+				if t, ok := parseFfmpegCreationTime(msg); ok {
+					log.Printf("[muxer %s]: stream created by encoder at %s\n", m.Name, t)
+					m.OnEncoderTime(t)
+				}
+			}
+
+			if inInput && !codecReported {
+				if codec, ok := parseFfmpegVideoCodec(msg); ok {
+					codecReported = true
+					log.Printf("[muxer %s]: incoming video codec: %s\n", m.Name, codec)
+					if m.OnVideoCodec != nil {
+						m.OnVideoCodec(codec)
+					}
+				}
+			}
+
+			switch level {
+			case "info", "verbose", "debug", "trace":
+				// ffmpeg runs at the info level to report stream info, don't flood the log
+			default:
+				log.Printf("[muxer %s]: ffmpeg stderr: %s\n", m.Name, s.Text())
+			}
 		}
 		if err := s.Err(); err != nil {
-			log.Printf("[muxer]: error processing stderr: %v\n", err)
+			log.Printf("[muxer %s]: error processing stderr: %v\n", m.Name, err)
 		}
 	}()
 
@@ -139,7 +187,8 @@ func (m *RtmpToHlsMuxer) Start() error {
 		return fmt.Errorf("muxer: failed to start: %w", err)
 	}
 	log.Printf(
-		"[muxer]: Ready for RTMP ingest at %s. Playlist will be at %s.\n",
+		"[muxer %s]: Ready for RTMP ingest at %s. Playlist will be at %s.\n",
+		m.Name,
 		m.Url,
 		m.PlaylistPath,
 	)
@@ -161,6 +210,55 @@ func (m *RtmpToHlsMuxer) Start() error {
 	return nil
 }
 
+func (m *RtmpToHlsMuxer) args() []string {
+	destinationDir := path.Dir(m.PlaylistPath)
+	args := []string{
+		"-hide_banner",
+		"-nostats",
+		// Progress is reported on stdout to learn when packets arrive, see OnProgress
+		"-progress", "pipe:1",
+		"-stats_period", "0.5",
+		// Info is needed to learn the codec of the incoming stream. The level prefix is used to
+		// filter what gets logged.
+		"-loglevel", "level+info",
+		"-listen", "1", // Wait for connection
+		"-i", m.Url,
+		"-rtmp_live", "live",
+		"-rtmp_buffer", "1000",
+	}
+
+	if len(m.Filters) > 0 {
+		args = append(args, "-vf", strings.Join(m.Filters, ","))
+	}
+
+	if m.Transcode || len(m.Filters) > 0 {
+		args = append(args,
+			"-c:v", "libx264",
+			"-preset", "veryfast",
+			"-tune", "zerolatency",
+			// 10-bit input would otherwise result in High 10 which browsers can't decode
+			"-pix_fmt", "yuv420p",
+			// Keyframe every second so segments can be split at hls_time
+			"-force_key_frames", "expr:gte(t,n_forced*1)",
+		)
+	} else {
+		args = append(args, "-c:v", "copy")
+	}
+
+	return append(args,
+		"-an", // Drop audio, wasted bytes underwater
+		"-f", "hls",
+		"-hls_list_size", "0",
+		"-hls_segment_type", "fmp4",
+		"-hls_time", "1",
+		"-hls_flags", "program_date_time+split_by_time",
+		"-hls_playlist_type", "event",
+		"-hls_segment_filename", path.Join(destinationDir, m.Prefix+"%d.mp4"),
+		"-hls_fmp4_init_filename", m.Prefix+"init.mp4",
+		m.PlaylistPath,
+	)
+}
+
 // Wait waits for the muxing to end.
 // Wait must be called in the same goroutine as Start.
 func (m *RtmpToHlsMuxer) Wait() error {
@@ -199,10 +297,10 @@ func (m *RtmpToHlsMuxer) Shutdown(ctx context.Context) error {
 			// Time to forcefully kill
 		}
 	} else {
-		log.Printf("[muxer]: Failed to send quit signal to ffmpeg stdin: %v.\n", err)
+		log.Printf("[muxer %s]: Failed to send quit signal to ffmpeg stdin: %v.\n", m.Name, err)
 	}
 
-	log.Println("[muxer]: ffmpeg did not close after sending 'q', killing it.")
+	log.Printf("[muxer %s]: ffmpeg did not close after sending 'q', killing it.\n", m.Name)
 	err = m.cmd.Process.Kill()
 	if err != nil {
 		return fmt.Errorf("[muxer]: error when sending SIGKILL to ffmpeg: %w\n", err)
@@ -215,3 +313,49 @@ func (m *RtmpToHlsMuxer) Shutdown(ctx context.Context) error {
 		return ctx.Err()
 	}
 }
+
+var ffmpegLogLevelRegexp = regexp.MustCompile(
+	`^(?:\[[^]]+ @ [^]]+] )?\[(trace|debug|verbose|info|warning|error|fatal|panic)] ?`,
+)
+
+// parseFfmpegLogLine splits a line, logged by ffmpeg with the level flag, in the level and
+// the message. If no level is found, level is empty and message is the whole line.
+func parseFfmpegLogLine(line string) (level string, message string) {
+	match := ffmpegLogLevelRegexp.FindStringSubmatchIndex(line)
+	if match == nil {
+		return "", line
+	}
+
+	return line[match[2]:match[3]], line[match[1]:]
+}
+
+var ffmpegVideoStreamRegexp = regexp.MustCompile(`^\s*Stream #\d+:\d+.*?: Video: (\w+)`)
+
+// parseFfmpegVideoCodec returns the codec of a stream info line such as
+// "Stream #0:0: Video: hevc (Main), yuv420p(tv), 1920x1080".
+func parseFfmpegVideoCodec(message string) (string, bool) {
+	match := ffmpegVideoStreamRegexp.FindStringSubmatch(message)
+	if match == nil {
+		return "", false
+	}
+
+	return match[1], true
+}
+
+var ffmpegCreationTimeRegexp = regexp.MustCompile(`^\s*creation_time\s*: (\S+)`)
+
+// parseFfmpegCreationTime returns the time of a metadata line such as
+// "creation_time   : 2025-06-01T10:00:00.000000Z".
+func parseFfmpegCreationTime(message string) (time.Time, bool) {
+	match := ffmpegCreationTimeRegexp.FindStringSubmatch(message)
+	if match == nil {
+		return time.Time{}, false
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, match[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return t, true
+}
@@ -0,0 +1,386 @@
+package flipcamlib
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rtmpPort is the port that every camera publishes to. The router picks the camera by the
+// stream name and forwards the publisher to the muxer of that camera.
+const rtmpPort = 1935
+
+// rtmpCommandTimeout is the time a publisher has to finish the handshake and start publishing.
+const rtmpCommandTimeout = 10 * time.Second
+
+// rtmpMaxCommandBytes is the most a publisher can send before it is accepted.
+const rtmpMaxCommandBytes = 1024 * 1024
+
+// rtmpIdleTimeout is the time after which a publisher that stopped sending is disconnected, so
+// that the muxer ends the session.
+const rtmpIdleTimeout = 10 * time.Second
+
+// rejectedPublisherDelay is the time before a publisher with an unknown stream key is
+// disconnected, it slows down publishers that keep on retrying.
+const rejectedPublisherDelay = 5 * time.Second
+
+// muxerDialTimeout is the time the router keeps trying to reach a muxer, e.g. while it restarts.
+const muxerDialTimeout = 5 * time.Second
+
+// runRtmpRouter accepts the publishers of all cameras on rtmpPort.
+//
+// The RTMP server of ffmpeg accepts a single publisher and can't reject stream names, so every
+// muxer listens on a loopback port of its own. The router completes the handshake and the
+// commands of the publisher up to publish, which carries the stream name. It then performs the
+// handshake with the muxer of the camera and replays everything the publisher sent, so that ffmpeg
+// sees the same stream as when the publisher connects directly. Afterwards, the bytes of the
+// publisher are forwarded as is.
+func (f *FlipCam) runRtmpRouter(ctx context.Context) {
+	addr := fmt.Sprintf(":%d", rtmpPort)
+	listener, err := (&net.ListenConfig{}).Listen(ctx, "tcp", addr)
+	if err != nil {
+		f.shutdownWg.Done()
+		f.stopWithError(fmt.Errorf("[rtmp]: failed to listen on %s: %w", addr, err))
+		return
+	}
+	log.Printf("[rtmp]: listening on %s\n", addr)
+	f.startupWg.Done()
+
+	go func() {
+		<-f.stop
+		err := listener.Close()
+		if err != nil {
+			f.addShutdownError(fmt.Errorf("[rtmp]: failed to close: %w", err))
+		}
+	}()
+
+	defer f.shutdownWg.Done()
+	var publishers sync.WaitGroup
+	for {
+		conn, err := listener.Accept()
+		switch {
+		case errors.Is(err, net.ErrClosed):
+			publishers.Wait()
+			log.Println("[rtmp]: shutdown cleanly")
+			return
+		case err != nil:
+			log.Printf("[rtmp]: failed to accept: %v\n", err)
+			continue
+		}
+
+		publishers.Add(1)
+		go func() {
+			defer publishers.Done()
+			f.routePublisher(conn)
+		}()
+	}
+}
+
+// cameraForStream returns the camera that publishes with the stream name. Without stream keys,
+// the name is the name of the camera, or anything if there is only one camera.
+func (f *FlipCam) cameraForStream(name string) (*camera, bool) {
+	for _, c := range f.cameras {
+		switch {
+		case len(c.streamKeys) > 0 && slices.Contains(c.streamKeys, name):
+			return c, true
+		case len(c.streamKeys) == 0 && (c.name == name || len(f.cameras) == 1):
+			return c, true
+		}
+	}
+
+	return nil, false
+}
+
+// routePublisher forwards the publisher to the muxer of the camera it publishes to.
+func (f *FlipCam) routePublisher(conn net.Conn) {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-f.stop:
+		case <-done:
+		}
+		_ = conn.Close()
+	}()
+	publisher := conn.RemoteAddr().String()
+
+	_ = conn.SetDeadline(time.Now().Add(rtmpCommandTimeout))
+	err := rtmpServerHandshake(conn)
+	if err != nil {
+		log.Printf("[rtmp]: handshake with %s failed: %v\n", publisher, err)
+		return
+	}
+
+	// Everything after the handshake is kept to be replayed to the muxer
+	var sent bytes.Buffer
+	cr := newRtmpChunkReader(io.TeeReader(io.LimitReader(conn, rtmpMaxCommandBytes), &sent))
+	name, ackWindow, err := rtmpAwaitPublish(cr, conn)
+	if err != nil {
+		log.Printf("[rtmp]: publisher %s failed before publishing: %v\n", publisher, err)
+		return
+	}
+	name, _, _ = strings.Cut(name, "?")
+
+	c, found := f.cameraForStream(name)
+	if !found {
+		log.Printf("[rtmp]: rejecting publisher %s with unknown stream key %q\n", publisher, name)
+		select {
+		case <-f.stop:
+		case <-time.After(rejectedPublisherDelay):
+		}
+		_ = writeRtmpPublishStatus(conn, "error", "NetStream.Publish.Denied", "Unknown stream key")
+		return
+	}
+	if !c.publishing.CompareAndSwap(false, true) {
+		log.Printf(
+			"[rtmp]: rejecting publisher %s, camera %s is already receiving a stream\n",
+			publisher,
+			c.name,
+		)
+		_ = writeRtmpPublishStatus(conn, "error", "NetStream.Publish.BadName", "Already publishing")
+		return
+	}
+	defer c.publishing.Store(false)
+
+	muxer, err := f.dialMuxer(c)
+	if err != nil {
+		log.Printf("[rtmp]: failed to reach the muxer of camera %s: %v\n", c.name, err)
+		_ = writeRtmpPublishStatus(conn, "error", "NetStream.Publish.BadName", "Camera unavailable")
+		return
+	}
+	defer muxer.Close()
+
+	_ = muxer.SetDeadline(time.Now().Add(rtmpCommandTimeout))
+	err = rtmpClientHandshake(muxer)
+	if err == nil {
+		_, err = muxer.Write(sent.Bytes())
+	}
+	if err != nil {
+		log.Printf("[rtmp]: failed to forward publisher %s: %v\n", publisher, err)
+		return
+	}
+	_ = muxer.SetDeadline(time.Time{})
+
+	err = writeRtmpPublishStatus(conn, "status", "NetStream.Publish.Start", "Publishing "+name)
+	if err != nil {
+		log.Printf("[rtmp]: failed to accept publisher %s: %v\n", publisher, err)
+		return
+	}
+	log.Printf("[rtmp]: publisher %s is streaming to camera %s\n", publisher, c.name)
+
+	// The responses of the muxer are for the commands that the router already answered
+	go func() {
+		_, _ = io.Copy(io.Discard, muxer)
+		_ = conn.Close()
+	}()
+
+	_ = conn.SetDeadline(time.Time{})
+	_, err = io.Copy(muxer, &rtmpPublisherReader{
+		conn:      conn,
+		ackWindow: ackWindow,
+		received:  uint32(sent.Len()),
+	})
+	switch {
+	case err != nil:
+		log.Printf("[rtmp]: publisher %s of camera %s disconnected: %v\n", publisher, c.name, err)
+	default:
+		log.Printf("[rtmp]: publisher %s of camera %s disconnected\n", publisher, c.name)
+	}
+}
+
+// dialMuxer connects to the muxer of the camera, retrying while it is not listening, e.g. because
+// it restarts.
+func (f *FlipCam) dialMuxer(c *camera) (net.Conn, error) {
+	deadline := time.Now().Add(muxerDialTimeout)
+	for {
+		conn, err := net.Dial("tcp", c.muxerAddr())
+		if err == nil || time.Now().After(deadline) {
+			return conn, err
+		}
+
+		select {
+		case <-f.stop:
+			return nil, errors.New("shutting down")
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+}
+
+// rtmpAwaitPublish answers the commands of a publisher until it publishes and returns the stream
+// name. ackWindow is the number of bytes after which the publisher wants an acknowledgement, 0
+// if it did not ask.
+func rtmpAwaitPublish(cr *rtmpChunkReader, w io.Writer) (name string, ackWindow uint32, err error) {
+	for {
+		msg, err := cr.readMessage()
+		if err != nil {
+			return "", 0, err
+		}
+
+		payload := msg.payload
+		switch msg.typeId {
+		case rtmpWindowAckSize:
+			if len(payload) >= 4 {
+				ackWindow = binary.BigEndian.Uint32(payload)
+			}
+			continue
+		case rtmpCommandAmf3:
+			// An AMF0 command preceded by a format byte
+			if len(payload) == 0 {
+				return "", 0, errors.New("empty command")
+			}
+			payload = payload[1:]
+		case rtmpCommandAmf0:
+		default:
+			continue
+		}
+
+		values, err := decodeAmf0(payload)
+		if err != nil {
+			return "", 0, err
+		}
+		command, _ := amf0Arg[string](values, 0)
+		transaction, _ := amf0Arg[float64](values, 1)
+
+		switch command {
+		case "connect":
+			err = writeRtmpConnectResult(w, transaction)
+		case "createStream":
+			err = writeRtmpCommand(w, 0, "_result", transaction, nil, 1.0)
+		case "FCPublish":
+			err = writeRtmpCommand(w, 0, "onFCPublish", 0.0, nil)
+		case "publish":
+			name, ok := amf0Arg[string](values, 3)
+			if !ok {
+				return "", 0, errors.New("publish without stream name")
+			}
+			return name, ackWindow, nil
+		default:
+			if transaction != 0 {
+				err = writeRtmpCommand(w, 0, "_result", transaction, nil)
+			}
+		}
+		if err != nil {
+			return "", 0, err
+		}
+	}
+}
+
+// amf0Arg returns the argument at index i of a command if it has type T.
+func amf0Arg[T any](values []any, i int) (T, bool) {
+	var zero T
+	if i >= len(values) {
+		return zero, false
+	}
+
+	v, ok := values[i].(T)
+	return v, ok
+}
+
+// writeRtmpConnectResult accepts the connection of the publisher, as the RTMP server of ffmpeg
+// does.
+func writeRtmpConnectResult(w io.Writer, transaction float64) error {
+	const window = 5000000
+	messages := []rtmpMessage{
+		rtmpControlMessage(rtmpWindowAckSize, window),
+		{
+			typeId:  rtmpSetPeerBandwidth,
+			payload: append(rtmpControlMessage(0, window).payload, 2), // Dynamic
+		},
+		// Stream Begin of stream 0
+		{typeId: rtmpUserControl, payload: make([]byte, 6)},
+	}
+	for _, msg := range messages {
+		err := writeRtmpMessage(w, rtmpControlChunkStream, msg)
+		if err != nil {
+			return err
+		}
+	}
+
+	return writeRtmpCommand(
+		w,
+		0,
+		"_result",
+		transaction,
+		amf0Object{
+			{Name: "fmsVer", Value: "FMS/3,0,1,123"},
+			{Name: "capabilities", Value: 31.0},
+		},
+		amf0Object{
+			{Name: "level", Value: "status"},
+			{Name: "code", Value: "NetConnection.Connect.Success"},
+			{Name: "description", Value: "Connection succeeded."},
+			{Name: "objectEncoding", Value: 0.0},
+		},
+	)
+}
+
+// writeRtmpPublishStatus reports the result of publishing on stream 1, the stream returned by
+// createStream.
+func writeRtmpPublishStatus(w io.Writer, level string, code string, description string) error {
+	if level == "status" {
+		// Stream Begin of stream 1
+		err := writeRtmpMessage(w, rtmpControlChunkStream, rtmpMessage{
+			typeId:  rtmpUserControl,
+			payload: []byte{0, 0, 0, 0, 0, 1},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return writeRtmpCommand(w, 1, "onStatus", 0.0, nil, amf0Object{
+		{Name: "level", Value: level},
+		{Name: "code", Value: code},
+		{Name: "description", Value: description},
+	})
+}
+
+func writeRtmpCommand(w io.Writer, streamId uint32, values ...any) error {
+	return writeRtmpMessage(w, rtmpCommandChunkStream, rtmpMessage{
+		typeId:   rtmpCommandAmf0,
+		streamId: streamId,
+		payload:  encodeAmf0(values...),
+	})
+}
+
+// rtmpPublisherReader reads the stream of a publisher. It acknowledges the received bytes if the
+// publisher asked for it and fails if the publisher stops sending.
+type rtmpPublisherReader struct {
+	conn      net.Conn
+	ackWindow uint32
+	received  uint32
+	acked     uint32
+}
+
+func (r *rtmpPublisherReader) Read(p []byte) (int, error) {
+	err := r.conn.SetReadDeadline(time.Now().Add(rtmpIdleTimeout))
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := r.conn.Read(p)
+	// The sequence number wraps around
+	r.received += uint32(n)
+	if r.ackWindow > 0 && r.received-r.acked >= r.ackWindow {
+		r.acked = r.received
+		ackErr := writeRtmpMessage(
+			r.conn,
+			rtmpControlChunkStream,
+			rtmpControlMessage(rtmpAcknowledgement, r.received),
+		)
+		if err == nil {
+			err = ackErr
+		}
+	}
+
+	return n, err
+}
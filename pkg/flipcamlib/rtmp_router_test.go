@@ -0,0 +1,303 @@
+package flipcamlib
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCameraForStream(t *testing.T) {
+	tests := []struct {
+		name       string
+		cameras    []string
+		keys       map[string][]string
+		stream     string
+		wantCamera string
+	}{
+		{
+			name:    "key",
+			cameras: []string{"side", "front"},
+			keys: map[string][]string{
+				"side":  {"side-key-1"},
+				"front": {"front-key", "front-key-2"},
+			},
+			stream:     "front-key-2",
+			wantCamera: "front",
+		},
+		{
+			name:    "unknown key",
+			cameras: []string{"side", "front"},
+			keys:    map[string][]string{"side": {"side-key-1"}, "front": {"front-key"}},
+			stream:  "side",
+		},
+		{
+			name:       "camera name without keys",
+			cameras:    []string{"side", "front"},
+			stream:     "front",
+			wantCamera: "front",
+		},
+		{
+			name:    "unknown camera without keys",
+			cameras: []string{"side", "front"},
+			stream:  "back",
+		},
+		{
+			name:       "one camera without keys",
+			stream:     "live",
+			wantCamera: DefaultCameraName,
+		},
+		{
+			name:   "one camera with a key",
+			keys:   map[string][]string{DefaultCameraName: {"main-key-1"}},
+			stream: "live",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := New(Opts{Cameras: tt.cameras, StreamKeys: tt.keys})
+			c, found := f.cameraForStream(tt.stream)
+			switch {
+			case tt.wantCamera == "" && found:
+				t.Errorf("cameraForStream(%q) = %s, want none", tt.stream, c.name)
+			case tt.wantCamera != "" && (!found || c.name != tt.wantCamera):
+				t.Errorf("cameraForStream(%q) = %v, want %s", tt.stream, c, tt.wantCamera)
+			}
+		})
+	}
+}
+
+func TestDecodeAmf0(t *testing.T) {
+	encoded := encodeAmf0(
+		"connect",
+		1.0,
+		amf0Object{
+			{Name: "app", Value: "camera"},
+			{Name: "fpad", Value: false},
+			{Name: "nested", Value: amf0Object{{Name: "n", Value: 2.0}}},
+		},
+		nil,
+	)
+
+	tests := []struct {
+		name    string
+		input   []byte
+		want    []any
+		wantErr bool
+	}{
+		{
+			name:  "command",
+			input: encoded,
+			want: []any{
+				"connect",
+				1.0,
+				map[string]any{"app": "camera", "fpad": false, "nested": map[string]any{"n": 2.0}},
+				nil,
+			},
+		},
+		{
+			name:  "strict array and long string",
+			input: []byte{10, 0, 0, 0, 2, 5, 12, 0, 0, 0, 1, 'a'},
+			want:  []any{[]any{nil, "a"}},
+		},
+		{
+			name:  "ECMA array",
+			input: []byte{8, 0, 0, 0, 1, 0, 1, 'k', 1, 1, 0, 0, 9},
+			want:  []any{map[string]any{"k": true}},
+		},
+		{
+			name:    "truncated",
+			input:   encoded[:len(encoded)-3],
+			wantErr: true,
+		},
+		{
+			name:    "string longer than input",
+			input:   []byte{2, 0xff, 0xff, 'a'},
+			wantErr: true,
+		},
+		{
+			name:    "array count larger than input",
+			input:   []byte{10, 0xff, 0xff, 0xff, 0xff, 5},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported type",
+			input:   []byte{17},
+			wantErr: true,
+		},
+		{
+			name:    "nested too deep",
+			input:   bytes.Repeat([]byte{10, 0, 0, 0, 1}, amf0MaxDepth+2),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeAmf0(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeAmf0() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeAmf0() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoutePublisher(t *testing.T) {
+	f := New(Opts{
+		Cameras:    []string{"side", "front"},
+		StreamKeys: map[string][]string{"side": {"side-key-1"}, "front": {"front-key-1"}},
+	})
+	muxerListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer muxerListener.Close()
+	f.cameras[1].muxerPort = muxerListener.Addr().(*net.TCPAddr).Port
+
+	publisher, router := net.Pipe()
+	defer publisher.Close()
+	routed := make(chan struct{})
+	go func() {
+		f.routePublisher(router)
+		close(routed)
+	}()
+	_ = publisher.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// The muxer must receive the commands of the publisher followed by the stream
+	received := make(chan []rtmpMessage, 1)
+	go func() {
+		conn, err := muxerListener.Accept()
+		if err != nil {
+			received <- nil
+			return
+		}
+		defer conn.Close()
+		_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+		err = rtmpServerHandshake(conn)
+		if err != nil {
+			received <- nil
+			return
+		}
+		cr := newRtmpChunkReader(conn)
+		var messages []rtmpMessage
+		for range 4 {
+			msg, err := cr.readMessage()
+			if err != nil {
+				break
+			}
+			messages = append(messages, msg)
+		}
+		received <- messages
+	}()
+
+	err = rtmpClientHandshake(publisher)
+	if err != nil {
+		t.Fatalf("handshake: %v", err)
+	}
+	responses := newRtmpChunkReader(publisher)
+	command := func(streamId uint32, values ...any) []any {
+		t.Helper()
+		err := writeRtmpCommand(publisher, streamId, values...)
+		if err != nil {
+			t.Fatalf("writing %v: %v", values[0], err)
+		}
+		for {
+			msg, err := responses.readMessage()
+			if err != nil {
+				t.Fatalf("response to %v: %v", values[0], err)
+			}
+			if msg.typeId == rtmpCommandAmf0 {
+				response, err := decodeAmf0(msg.payload)
+				if err != nil {
+					t.Fatalf("response to %v: %v", values[0], err)
+				}
+				return response
+			}
+		}
+	}
+
+	// Longer than a chunk
+	connect := command(0, "connect", 1.0, amf0Object{
+		{Name: "app", Value: "camera"},
+		{Name: "type", Value: "nonprivate"},
+		{Name: "flashVer", Value: "FMLE/3.0 (compatible; FMSc/1.0)"},
+		{Name: "tcUrl", Value: "rtmp://192.168.23.1:1935/camera"},
+	})
+	if connect[0] != "_result" {
+		t.Fatalf("connect response = %v, want _result", connect)
+	}
+	createStream := command(0, "createStream", 2.0, nil)
+	if createStream[0] != "_result" || createStream[3] != 1.0 {
+		t.Fatalf("createStream response = %v, want _result with stream 1", createStream)
+	}
+	publish := command(1, "publish", 0.0, nil, "front-key-1", "live")
+	status, _ := publish[3].(map[string]any)
+	if publish[0] != "onStatus" || status["code"] != "NetStream.Publish.Start" {
+		t.Fatalf("publish response = %v, want NetStream.Publish.Start", publish)
+	}
+
+	media := rtmpMessage{typeId: 9, streamId: 1, payload: []byte("video")}
+	err = writeRtmpMessage(publisher, 4, media)
+	if err != nil {
+		t.Fatalf("writing media: %v", err)
+	}
+
+	messages := <-received
+	if len(messages) != 4 {
+		t.Fatalf("muxer received %d messages, want 4", len(messages))
+	}
+	for i, want := range []string{"connect", "createStream", "publish"} {
+		values, err := decodeAmf0(messages[i].payload)
+		if err != nil || values[0] != want {
+			t.Errorf("message %d of the muxer = %v, %v, want %s", i, values, err, want)
+		}
+	}
+	if !reflect.DeepEqual(messages[3], media) {
+		t.Errorf("media of the muxer = %v, want %v", messages[3], media)
+	}
+
+	_ = publisher.Close()
+	<-routed
+	if f.cameras[1].publishing.Load() {
+		t.Error("camera still publishing after the publisher disconnected")
+	}
+}
+
+func TestRtmpChunkReaderInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{
+			name:  "continuation without header",
+			input: []byte{0xc3, 1, 2, 3},
+		},
+		{
+			name:  "message too large",
+			input: []byte{0x03, 0, 0, 0, 0xff, 0xff, 0xff, 20, 0, 0, 0, 0},
+		},
+		{
+			name: "zero chunk size",
+			input: []byte{
+				0x02, 0, 0, 0, 0, 0, 4, rtmpSetChunkSize, 0, 0, 0, 0,
+				0, 0, 0, 0,
+			},
+		},
+		{
+			name:  "truncated",
+			input: []byte{0x03, 0, 0, 0, 0, 0, 10, 20, 0, 0, 0, 0, 1, 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newRtmpChunkReader(bytes.NewReader(tt.input)).readMessage()
+			if err == nil || err == io.EOF {
+				t.Errorf("readMessage() error = %v, want an error", err)
+			}
+		})
+	}
+}
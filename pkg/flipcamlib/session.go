@@ -0,0 +1,253 @@
+package flipcamlib
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/MatthiasKunnen/flipcam/pkg/flipcamlib/storage"
+)
+
+// Session is the recording of a single muxer run.
+// All files of a session are stored in Dir and start with the session ID.
+type Session struct {
+	Id string
+
+	// Dir is the directory that contains the playlist and segments.
+	Dir string
+}
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// sessionIdRegexp matches the IDs generated by runMuxer using rand.Text.
+var sessionIdRegexp = regexp.MustCompile(`^[A-Z2-7]{6}$`)
+
+// getSession returns the session with the given ID. ErrSessionNotFound is returned if the ID is
+// invalid or no such session exists.
+func (f *FlipCam) getSession(id string) (Session, error) {
+	if !sessionIdRegexp.MatchString(id) {
+		return Session{}, ErrSessionNotFound
+	}
+
+	session := Session{Id: id, Dir: f.hlsOutputDir}
+	_, err := os.Stat(session.PlaylistPath())
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return Session{}, ErrSessionNotFound
+	case err != nil:
+		return Session{}, err
+	}
+
+	return session, nil
+}
+
+// currentSession returns the session that the muxer of the camera is recording.
+func (f *FlipCam) currentSession(c *camera) (Session, error) {
+	return f.getSession(strings.TrimSuffix(path.Base(c.getPlayListUrlPath()), ".m3u8"))
+}
+
+var errSessionRecording = errors.New("the session is being recorded, restart the muxer first")
+
+// isRecording returns true if the muxer of a camera is writing the session.
+func (f *FlipCam) isRecording(session Session) bool {
+	for _, c := range f.cameras {
+		current, err := f.currentSession(c)
+		if err == nil && current.Id == session.Id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// deleteSession removes the files of the session and everything that is stored about it,
+// including the clips and assignments of the session.
+func (f *FlipCam) deleteSession(session Session) error {
+	if f.isRecording(session) {
+		return errSessionRecording
+	}
+
+	err := f.store.Update(func(tx storage.Tx) error {
+		err := tx.Delete(storage.BucketSessions, session.Id)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return err
+		}
+		for _, bucket := range []storage.Bucket{storage.BucketAnnotations, storage.BucketComments} {
+			err = deletePrefix(tx, bucket, sessionKeyPrefix(session))
+			if err != nil {
+				return err
+			}
+		}
+
+		clips, err := storage.List[Clip](tx, storage.BucketClips, "")
+		if err != nil {
+			return err
+		}
+		for _, clip := range clips {
+			if clip.SessionId == session.Id {
+				err = tx.Delete(storage.BucketClips, clip.Id)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		assignments, err := storage.List[Assignment](tx, storage.BucketAssignments, "")
+		if err != nil {
+			return err
+		}
+		for _, assignment := range assignments {
+			if assignment.SessionId == session.Id {
+				err = tx.Delete(storage.BucketAssignments, assignment.Id)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Every file and directory of the session starts with the ID followed by . or _
+	var files []string
+	for _, pattern := range []string{session.Id + ".*", session.Id + "_*"} {
+		matches, err := filepath.Glob(path.Join(session.Dir, pattern))
+		if err != nil {
+			return err
+		}
+		files = append(files, matches...)
+	}
+	for _, file := range files {
+		err = errors.Join(err, os.RemoveAll(file))
+	}
+
+	return err
+}
+
+// deletePrefix deletes the keys of the bucket that start with prefix.
+func deletePrefix(tx storage.Tx, bucket storage.Bucket, prefix string) error {
+	var keys []string
+	err := tx.ForEach(bucket, prefix, func(key string, _ []byte) error {
+		keys = append(keys, key)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Keys are deleted after iterating as the cursor must not be used while the bucket changes
+	for _, key := range keys {
+		err = tx.Delete(bucket, key)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (f *FlipCam) handleDeleteSession(w http.ResponseWriter, r *http.Request) {
+	session, err := f.getSession(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	err = f.deleteSession(session)
+	switch {
+	case errors.Is(err, errSessionRecording):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		log.Printf("web: failed to delete session %s: %v\n", session.Id, err)
+		http.Error(w, "failed to delete session", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("web: deleted session %s\n", session.Id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PlaylistPath returns the path of the media playlist written by the muxer.
+func (s Session) PlaylistPath() string {
+	return path.Join(s.Dir, s.Id+".m3u8")
+}
+
+// filePath returns the path of a file belonging to the session.
+// suffix is appended to the session ID, e.g. _iframes.m3u8.
+func (s Session) filePath(suffix string) string {
+	return path.Join(s.Dir, s.Id+suffix)
+}
+
+// uriPath returns the path of a file referenced by a URI in the playlist.
+func (s Session) uriPath(uri string) string {
+	return path.Join(s.Dir, path.Clean("/"+uri))
+}
+
+func (s Session) readPlaylist() (*MediaPlaylist, error) {
+	return readMediaPlaylist(s.PlaylistPath())
+}
+
+// openSegments returns a reader that reads the initialization segment followed by the
+// segments. The result is a valid fragmented MP4 stream.
+// Segments with a byte range only read that range.
+func (s Session) openSegments(mapUri string, segments []MediaSegment) (io.ReadCloser, error) {
+	r := &multiFileReader{}
+	readers := make([]io.Reader, 0, len(segments)+1)
+
+	initFile, err := os.Open(s.uriPath(mapUri))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open initialization segment: %w", err)
+	}
+	r.files = append(r.files, initFile)
+	readers = append(readers, initFile)
+
+	for _, segment := range segments {
+		file, err := os.Open(s.uriPath(segment.Uri))
+		if err != nil {
+			_ = r.Close()
+			return nil, fmt.Errorf("failed to open segment %s: %w", segment.Uri, err)
+		}
+		r.files = append(r.files, file)
+
+		if segment.ByteRangeLength > 0 {
+			readers = append(readers, io.NewSectionReader(
+				file,
+				segment.ByteRangeOffset,
+				segment.ByteRangeLength,
+			))
+		} else {
+			readers = append(readers, file)
+		}
+	}
+
+	r.reader = io.MultiReader(readers...)
+	return r, nil
+}
+
+type multiFileReader struct {
+	files  []*os.File
+	reader io.Reader
+}
+
+func (r *multiFileReader) Read(p []byte) (int, error) {
+	return r.reader.Read(p)
+}
+
+func (r *multiFileReader) Close() error {
+	var err error
+	for _, file := range r.files {
+		err = errors.Join(err, file.Close())
+	}
+	return err
+}
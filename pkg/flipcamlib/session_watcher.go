@@ -0,0 +1,90 @@
+package flipcamlib
+
+import (
+	"errors"
+	"log"
+	"os"
+	"time"
+)
+
+// sessionProcessor processes the segments of a session while they are written by the muxer.
+type sessionProcessor interface {
+	// processSegment is called, in order, for every segment that is added to the playlist.
+	processSegment(playlist *MediaPlaylist, segment MediaSegment) error
+
+	// finish is called once no more segments will be added to the playlist.
+	finish(playlist *MediaPlaylist) error
+}
+
+// sessionProcessors returns the processors that are run for every new session.
+func (f *FlipCam) sessionProcessors(session Session) []sessionProcessor {
+	return []sessionProcessor{
+		newIframeIndexer(session),
+		newDashWriter(session),
+		&thumbnailQueuer{jobs: f.thumbnailJobs, session: session},
+	}
+}
+
+// watchSession polls the playlist of the session and passes every new segment to the session
+// processors. It returns after muxerStopped is closed and the remaining segments are processed.
+// The caller must call shutdownWg.Add(1).
+func (f *FlipCam) watchSession(session Session, muxerStopped <-chan struct{}) {
+	defer f.shutdownWg.Done()
+
+	processors := f.sessionProcessors(session)
+	failed := make([]bool, len(processors))
+	processed := 0
+
+	poll := func() *MediaPlaylist {
+		playlist, err := session.readPlaylist()
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			// Muxer did not receive anything yet
+			return nil
+		case err != nil:
+			log.Printf("[session %s]: failed to read playlist: %v\n", session.Id, err)
+			return nil
+		}
+
+		for _, segment := range playlist.Segments[processed:] {
+			for i, processor := range processors {
+				if failed[i] {
+					continue
+				}
+
+				err := processor.processSegment(playlist, segment)
+				if err != nil {
+					// Don't keep on processing, this would flood the log
+					log.Printf("[session %s]: %T failed, disabling it: %v\n", session.Id, processor, err)
+					failed[i] = true
+				}
+			}
+		}
+		processed = len(playlist.Segments)
+		return playlist
+	}
+
+	for {
+		select {
+		case <-muxerStopped:
+			playlist := poll()
+			if playlist == nil {
+				return
+			}
+
+			for i, processor := range processors {
+				if failed[i] {
+					continue
+				}
+
+				err := processor.finish(playlist)
+				if err != nil {
+					log.Printf("[session %s]: %T failed to finish: %v\n", session.Id, processor, err)
+				}
+			}
+			return
+		case <-time.After(500 * time.Millisecond):
+			poll()
+		}
+	}
+}
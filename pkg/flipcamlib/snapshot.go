@@ -0,0 +1,160 @@
+package flipcamlib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"time"
+)
+
+const snapshotsDirSuffix = "_snapshots"
+
+// parseSnapshotTime parses the at parameter of a snapshot request. It is either an RFC 3339
+// timestamp or a delay, such as 5s or 2.5, relative to now. absolute is false for a delay, the
+// same value then refers to another instant on every request.
+func parseSnapshotTime(value string, now time.Time) (at time.Time, absolute bool, err error) {
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, true, nil
+	}
+
+	delay, err := time.ParseDuration(value)
+	if err != nil {
+		seconds, floatErr := strconv.ParseFloat(value, 64)
+		if floatErr != nil {
+			return time.Time{}, false, fmt.Errorf(
+				"at must be an RFC 3339 timestamp or a delay such as 5s, got %q",
+				value,
+			)
+		}
+		delay = time.Duration(seconds * float64(time.Second))
+	}
+
+	if delay < 0 {
+		return time.Time{}, false, fmt.Errorf("delay must not be negative")
+	}
+
+	return now.Add(-delay), false, nil
+}
+
+// handleSnapshot returns a still image of the session at the requested time.
+//
+// Query parameters:
+//   - at: RFC 3339 timestamp or delay relative to now, e.g. 5s. Only snapshots of a timestamp
+//     may be cached by the client.
+//   - width: optional width in pixels, the original size is used when omitted.
+//   - format: jpeg (default) or png.
+//
+// Snapshots are cached on disk next to the session.
+func (f *FlipCam) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	session, err := f.getSession(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	at, absolute, err := parseSnapshotTime(query.Get("at"), time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	width, err := parseImageWidth(query.Get("width"), 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	format, err := ParseImageFormat(query.Get("format"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	playlist, err := session.readPlaylist()
+	if err != nil {
+		log.Printf("web: snapshot: failed to read playlist: %v\n", err)
+		http.Error(w, "failed to read playlist", http.StatusInternalServerError)
+		return
+	}
+
+	segment, found := playlist.SegmentAt(at)
+	if !found {
+		http.Error(w, "the session has no video at the requested time", http.StatusNotFound)
+		return
+	}
+
+	// Rounding increases the odds of a cache hit, it is smaller than the duration of a frame
+	offset := at.Sub(segment.ProgramDateTime).Round(10 * time.Millisecond)
+	snapshotDir := session.filePath(snapshotsDirSuffix)
+	snapshotPath := path.Join(snapshotDir, fmt.Sprintf(
+		"%d_%d_%d.%s",
+		segment.Sequence,
+		offset.Milliseconds(),
+		width,
+		format,
+	))
+
+	image, err := os.ReadFile(snapshotPath)
+	if errors.Is(err, os.ErrNotExist) {
+		image, err = createSnapshot(r.Context(), session, playlist.MapUri, segment, offset, width, format)
+		if err != nil {
+			log.Printf("web: snapshot: %v\n", err)
+			http.Error(w, "failed to create snapshot", http.StatusInternalServerError)
+			return
+		}
+
+		err = os.MkdirAll(snapshotDir, 0o755)
+		if err == nil {
+			err = writeFileAtomic(snapshotPath, image, 0o644)
+		}
+		if err != nil {
+			log.Printf("web: snapshot: failed to cache: %v\n", err)
+		}
+	} else if err != nil {
+		log.Printf("web: snapshot: failed to read cache: %v\n", err)
+		http.Error(w, "failed to read snapshot", http.StatusInternalServerError)
+		return
+	}
+
+	filename := fmt.Sprintf(
+		"flipcam_%s_%s.%s",
+		session.Id,
+		segment.ProgramDateTime.Add(offset).Format("20060102_150405.000"),
+		format,
+	)
+	if absolute {
+		w.Header().Set("Cache-Control", "max-age=86400")
+	} else {
+		w.Header().Set("Cache-Control", "no-store")
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", filename))
+	w.Header().Set("Content-Type", format.ContentType())
+	_, err = w.Write(image)
+	if err != nil {
+		log.Printf("web: snapshot: failed to write: %v\n", err)
+	}
+}
+
+func createSnapshot(
+	ctx context.Context,
+	session Session,
+	mapUri string,
+	segment MediaSegment,
+	offset time.Duration,
+	width int,
+	format ImageFormat,
+) ([]byte, error) {
+	input, err := session.openSegments(mapUri, []MediaSegment{segment})
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	return extractFrame(ctx, input, offset, width, format)
+}
@@ -0,0 +1,156 @@
+package flipcamlib
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/MatthiasKunnen/flipcam/static"
+)
+
+// staticHashLength is the number of hex characters of the content hash in the URL of a static
+// file.
+const staticHashLength = 12
+
+// staticAssets are the files of the UI, served at /static/. Their URLs contain a hash of the
+// content so that browsers can cache them until the file changes, e.g. after an upgrade.
+type staticAssets struct {
+	fsys fs.FS
+
+	// immutable is true if the files can't change while flipcam runs, their hashes are cached.
+	immutable bool
+	hashes    map[string]string
+	hashesMu  sync.Mutex
+}
+
+// staticAssetExtensions are the extensions of the files of the UI, the files that package static
+// embeds.
+var staticAssetExtensions = []string{".css", ".mjs"}
+
+// staticAssetsFS only opens the files of the UI, so that other files in the static directory,
+// such as embed.go, are not served.
+type staticAssetsFS struct {
+	fs.FS
+}
+
+func (f staticAssetsFS) Open(name string) (fs.File, error) {
+	if strings.Contains(name, "/") || !slices.Contains(staticAssetExtensions, path.Ext(name)) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return f.FS.Open(name)
+}
+
+// newStaticAssets returns the embedded files, or the files of dir if it is not empty. The
+// files of dir are read on every request so that changes show up without restarting. Only files
+// with one of staticAssetExtensions are served from dir.
+func newStaticAssets(dir string) (*staticAssets, error) {
+	if dir == "" {
+		return &staticAssets{
+			fsys:      static.Files,
+			immutable: true,
+			hashes:    make(map[string]string),
+		}, nil
+	}
+
+	_, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &staticAssets{fsys: staticAssetsFS{os.DirFS(dir)}}, nil
+}
+
+// hash returns the hash of the content of the file, or an empty string if it can't be read.
+func (a *staticAssets) hash(name string) string {
+	if a.immutable {
+		a.hashesMu.Lock()
+		defer a.hashesMu.Unlock()
+		if hash, ok := a.hashes[name]; ok {
+			return hash
+		}
+	}
+
+	data, err := fs.ReadFile(a.fsys, name)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])[:staticHashLength]
+	if a.immutable {
+		a.hashes[name] = hash
+	}
+
+	return hash
+}
+
+// url returns the URL of the file, which changes when the content of the file changes.
+func (a *staticAssets) url(name string) string {
+	hash := a.hash(name)
+	if hash == "" {
+		return "/static/" + name
+	}
+
+	return "/static/" + name + "?v=" + hash
+}
+
+// ServeHTTP serves the file of the request path, stripped of /static/. Requests for the current
+// version of a file may be cached forever.
+func (a *staticAssets) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	hash := a.hash(strings.TrimPrefix(r.URL.Path, "/"))
+	switch {
+	case hash == "":
+		// Not a file, the file server responds with an error
+	case r.URL.Query().Get("v") == hash:
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		w.Header().Set("ETag", `"`+hash+`"`)
+	default:
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("ETag", `"`+hash+`"`)
+	}
+
+	http.FileServerFS(a.fsys).ServeHTTP(w, r)
+}
+
+type staticAssetsKey struct{}
+
+// withStaticAssets makes the URLs of the static files available to the templates rendered by
+// handler.
+func withStaticAssets(handler http.Handler, assets *staticAssets) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), staticAssetsKey{}, assets)
+		handler.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// staticUrl returns the URL of a static file, e.g. main.mjs, for use in a template.
+func staticUrl(ctx context.Context, name string) string {
+	assets, ok := ctx.Value(staticAssetsKey{}).(*staticAssets)
+	if !ok {
+		return "/static/" + name
+	}
+
+	return assets.url(name)
+}
+
+// importMap returns the import map of the modules that are imported by the page scripts.
+func importMap(ctx context.Context) any {
+	return struct {
+		Imports map[string]string `json:"imports"`
+	}{
+		Imports: map[string]string{
+			"annotations": staticUrl(ctx, "annotations.mjs"),
+			"helpers":     staticUrl(ctx, "helpers.mjs"),
+			"hls":         staticUrl(ctx, "hls.light.mjs"),
+			"qrcode":      staticUrl(ctx, "qrcode.mjs"),
+			"roster":      staticUrl(ctx, "roster.mjs"),
+		},
+	}
+}
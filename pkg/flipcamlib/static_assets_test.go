@@ -0,0 +1,45 @@
+package flipcamlib
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestStaticAssetsDir(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"main.mjs", "style.css", "embed.go", "sub/other.css"} {
+		err := os.MkdirAll(path.Dir(path.Join(dir, name)), 0o755)
+		if err == nil {
+			err = os.WriteFile(path.Join(dir, name), []byte(name), 0o644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	assets, err := newStaticAssets(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := http.StripPrefix("/static/", assets)
+
+	tests := []struct {
+		path       string
+		wantStatus int
+	}{
+		{path: "/static/main.mjs", wantStatus: http.StatusOK},
+		{path: "/static/style.css", wantStatus: http.StatusOK},
+		{path: "/static/embed.go", wantStatus: http.StatusNotFound},
+		{path: "/static/sub/other.css", wantStatus: http.StatusNotFound},
+		{path: "/static/", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.wantStatus {
+			t.Errorf("GET %s = %d, want %d", tt.path, w.Code, tt.wantStatus)
+		}
+	}
+}
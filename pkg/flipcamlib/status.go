@@ -0,0 +1,35 @@
+package flipcamlib
+
+// Status describes the current state of FlipCam as exposed by the status API.
+type Status struct {
+	// Camera is the name of the camera that the playlist and stream info belong to.
+	Camera string `json:"camera"`
+
+	BroadcastDelay  BroadcastDelay  `json:"broadcastDelay"`
+	Overlay         Overlay         `json:"overlay"`
+	PlaylistPath    string          `json:"playlistPath"`
+	TranscodePolicy TranscodePolicy `json:"transcodePolicy"`
+	Transform       VideoTransform  `json:"transform"`
+
+	// Transcoding is true if the current muxer run re-encodes the video to H.264.
+	Transcoding bool `json:"transcoding"`
+
+	// VideoCodec is the codec of the incoming stream as named by ffmpeg. Empty when no stream
+	// has been received yet.
+	VideoCodec string `json:"videoCodec"`
+}
+
+// status returns the settings and the status of the camera.
+func (f *FlipCam) status(c *camera) Status {
+	camera := f.cameraStatus(c)
+	return Status{
+		Camera:          camera.Name,
+		BroadcastDelay:  f.getBroadcastDelay(),
+		Overlay:         c.getOverlay(),
+		PlaylistPath:    camera.PlaylistPath,
+		TranscodePolicy: f.transcodePolicy,
+		Transform:       c.getTransform(),
+		Transcoding:     camera.Transcoding,
+		VideoCodec:      camera.VideoCodec,
+	}
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/go-json-experiment/json/jsontext"
+	"go.etcd.io/bbolt"
+)
+
+// Backup writes a consistent copy of the database to w. It can be opened with Open.
+// Updates can continue during the backup.
+func (d *DB) Backup(w io.Writer) error {
+	return d.db.View(func(tx *bbolt.Tx) error {
+		_, err := tx.WriteTo(w)
+		return err
+	})
+}
+
+// Export writes the contents of the database to w as a JSON object that maps every bucket to an
+// object of its keys and values.
+func (d *DB) Export(w io.Writer) error {
+	return d.db.View(func(tx *bbolt.Tx) error {
+		enc := jsontext.NewEncoder(w, jsontext.Multiline(true))
+		err := enc.WriteToken(jsontext.BeginObject)
+		if err != nil {
+			return err
+		}
+
+		err = tx.ForEach(func(name []byte, b *bbolt.Bucket) error {
+			err := enc.WriteToken(jsontext.String(string(name)))
+			if err != nil {
+				return err
+			}
+			err = enc.WriteToken(jsontext.BeginObject)
+			if err != nil {
+				return err
+			}
+
+			err = b.ForEach(func(k, v []byte) error {
+				err := enc.WriteToken(jsontext.String(string(k)))
+				if err != nil {
+					return err
+				}
+
+				if string(name) == metaBucket {
+					return enc.WriteToken(jsontext.String(string(v)))
+				}
+				err = enc.WriteValue(v)
+				if err != nil {
+					return fmt.Errorf("invalid value %s/%s: %w", name, k, err)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			return enc.WriteToken(jsontext.EndObject)
+		})
+		if err != nil {
+			return err
+		}
+
+		return enc.WriteToken(jsontext.EndObject)
+	})
+}
@@ -0,0 +1,212 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+	"go.etcd.io/bbolt"
+)
+
+// metaBucket holds the schema version. It is not accessible through Tx.
+const metaBucket = "meta"
+
+const versionKey = "version"
+
+// migration changes the schema from the previous version. dir is the directory of the database.
+type migration func(tx *bbolt.Tx, dir string) error
+
+// migrations are run in order, the schema version is the number of migrations that have run.
+// Migrations must never be changed or removed once released, only appended.
+var migrations = []migration{
+	createBuckets,
+	importJsonFiles,
+}
+
+// migrate runs the migrations that have not yet run. Each migration runs in its own transaction
+// so that an interrupted migration is retried on the next start.
+func (d *DB) migrate() error {
+	dir := filepath.Dir(d.db.Path())
+	for {
+		done, err := d.migrateNext(dir)
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+	}
+}
+
+func (d *DB) migrateNext(dir string) (bool, error) {
+	done := false
+	err := d.db.Update(func(tx *bbolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists([]byte(metaBucket))
+		if err != nil {
+			return err
+		}
+
+		version := 0
+		if v := meta.Get([]byte(versionKey)); v != nil {
+			version, err = strconv.Atoi(string(v))
+			if err != nil {
+				return fmt.Errorf("invalid schema version %q: %w", v, err)
+			}
+		}
+		switch {
+		case version > len(migrations):
+			return fmt.Errorf(
+				"schema version %d is newer than this version of flipcam supports",
+				version,
+			)
+		case version == len(migrations):
+			done = true
+			return nil
+		}
+
+		err = migrations[version](tx, dir)
+		if err != nil {
+			return fmt.Errorf("failed to migrate to schema version %d: %w", version+1, err)
+		}
+
+		return meta.Put([]byte(versionKey), []byte(strconv.Itoa(version+1)))
+	})
+
+	return done, err
+}
+
+func createBuckets(tx *bbolt.Tx, _ string) error {
+	for _, bucket := range []Bucket{
+		BucketAnnotations,
+		BucketAssignments,
+		BucketAthletes,
+		BucketClips,
+		BucketComments,
+		BucketGroups,
+		BucketSessions,
+		BucketSettings,
+	} {
+		_, err := tx.CreateBucket([]byte(bucket))
+		if err != nil {
+			return fmt.Errorf("failed to create bucket %s: %w", bucket, err)
+		}
+	}
+
+	return nil
+}
+
+// importJsonFiles imports the JSON files in which earlier versions stored the metadata.
+// The files are left in place.
+func importJsonFiles(tx *bbolt.Tx, dir string) error {
+	err := importJsonFile(tx, filepath.Join(dir, "clips.json"), BucketClips, "")
+	if err != nil {
+		return err
+	}
+
+	var roster struct {
+		Athletes    []jsontext.Value `json:"athletes"`
+		Groups      []jsontext.Value `json:"groups"`
+		Assignments []jsontext.Value `json:"assignments"`
+	}
+	rosterPath := filepath.Join(dir, "roster.json")
+	found, err := readJsonFile(rosterPath, &roster)
+	if err != nil {
+		return err
+	}
+	if found {
+		for bucket, values := range map[Bucket][]jsontext.Value{
+			BucketAthletes:    roster.Athletes,
+			BucketGroups:      roster.Groups,
+			BucketAssignments: roster.Assignments,
+		} {
+			err = putJsonValues(tx, bucket, "", values)
+			if err != nil {
+				return fmt.Errorf("failed to import %s: %w", rosterPath, err)
+			}
+		}
+		log.Printf("[storage]: imported %s\n", rosterPath)
+	}
+
+	for suffix, bucket := range map[string]Bucket{
+		"_annotations.json": BucketAnnotations,
+		"_comments.json":    BucketComments,
+	} {
+		files, err := filepath.Glob(filepath.Join(dir, "*"+suffix))
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			sessionId := strings.TrimSuffix(filepath.Base(file), suffix)
+			err = importJsonFile(tx, file, bucket, sessionId+"/")
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// importJsonFile stores the objects of the JSON array in the file by their ID.
+func importJsonFile(tx *bbolt.Tx, file string, bucket Bucket, keyPrefix string) error {
+	var values []jsontext.Value
+	found, err := readJsonFile(file, &values)
+	if err != nil || !found {
+		return err
+	}
+
+	err = putJsonValues(tx, bucket, keyPrefix, values)
+	if err != nil {
+		return fmt.Errorf("failed to import %s: %w", file, err)
+	}
+
+	log.Printf("[storage]: imported %s\n", file)
+	return nil
+}
+
+func readJsonFile(file string, v any) (bool, error) {
+	data, err := os.ReadFile(file)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+
+	err = json.Unmarshal(data, v)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+
+	return true, nil
+}
+
+func putJsonValues(tx *bbolt.Tx, bucket Bucket, keyPrefix string, values []jsontext.Value) error {
+	b := tx.Bucket([]byte(bucket))
+	for _, value := range values {
+		var object struct {
+			Id string `json:"id"`
+		}
+		err := json.Unmarshal(value, &object)
+		if err != nil {
+			return err
+		}
+		if object.Id == "" {
+			return errors.New("object without ID")
+		}
+
+		err = b.Put([]byte(keyPrefix+object.Id), value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
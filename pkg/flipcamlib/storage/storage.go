@@ -0,0 +1,202 @@
+// Package storage keeps the metadata of FlipCam, such as clips, annotations, and the roster, in
+// an embedded database.
+//
+// The database is a single bbolt file. Every update is a transaction that is synced to disk
+// before it completes, a power loss therefore either keeps or discards an update as a whole.
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-json-experiment/json"
+	"go.etcd.io/bbolt"
+)
+
+// Bucket is a collection of values that are stored by key.
+type Bucket string
+
+const (
+	BucketAnnotations Bucket = "annotations"
+	BucketAssignments Bucket = "assignments"
+	BucketAthletes    Bucket = "athletes"
+	BucketClips       Bucket = "clips"
+	BucketComments    Bucket = "comments"
+	BucketGroups      Bucket = "groups"
+	BucketSessions    Bucket = "sessions"
+	BucketSettings    Bucket = "settings"
+)
+
+// ErrNotFound is returned by Tx.Get and Tx.Delete if the key does not exist.
+var ErrNotFound = errors.New("not found")
+
+// ErrLocked is returned by Open if another process uses the database.
+var ErrLocked = errors.New("database is in use by another process")
+
+// Repository gives access to the stored metadata.
+type Repository interface {
+	// View runs fn in a read-only transaction.
+	View(fn func(tx Tx) error) error
+
+	// Update runs fn in a read-write transaction. The changes are committed if fn returns nil.
+	// Only one update runs at a time.
+	Update(fn func(tx Tx) error) error
+}
+
+// Tx is a transaction. Values are stored as JSON.
+type Tx interface {
+	// Get decodes the value of the key into v.
+	Get(bucket Bucket, key string, v any) error
+
+	Put(bucket Bucket, key string, v any) error
+
+	Delete(bucket Bucket, key string) error
+
+	// ForEach calls fn, in key order, for each key that starts with prefix.
+	// The value is only valid during the call.
+	ForEach(bucket Bucket, prefix string, fn func(key string, value []byte) error) error
+}
+
+// List returns the values of the keys that start with prefix, in key order.
+func List[T any](tx Tx, bucket Bucket, prefix string) ([]T, error) {
+	values := []T{}
+	err := tx.ForEach(bucket, prefix, func(key string, value []byte) error {
+		var v T
+		err := json.Unmarshal(value, &v)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s/%s: %w", bucket, key, err)
+		}
+		values = append(values, v)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+// DB is a Repository backed by a bbolt database file.
+type DB struct {
+	db *bbolt.DB
+}
+
+// Open opens the database at path, creating it if needed, and migrates it to the latest schema.
+func Open(path string) (*DB, error) {
+	db, err := bbolt.Open(path, 0o600, &bbolt.Options{
+		Timeout: time.Second,
+	})
+	switch {
+	case errors.Is(err, bbolt.ErrTimeout):
+		return nil, fmt.Errorf("failed to open %s: %w", path, ErrLocked)
+	case err != nil:
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	d := &DB{db: db}
+	err = d.migrate()
+	if err != nil {
+		return nil, errors.Join(err, db.Close())
+	}
+
+	return d, nil
+}
+
+func (d *DB) Close() error {
+	return d.db.Close()
+}
+
+func (d *DB) View(fn func(tx Tx) error) error {
+	return d.db.View(func(tx *bbolt.Tx) error {
+		return fn(boltTx{tx: tx})
+	})
+}
+
+func (d *DB) Update(fn func(tx Tx) error) error {
+	return d.db.Update(func(tx *bbolt.Tx) error {
+		return fn(boltTx{tx: tx})
+	})
+}
+
+type boltTx struct {
+	tx *bbolt.Tx
+}
+
+func (t boltTx) bucket(bucket Bucket) (*bbolt.Bucket, error) {
+	b := t.tx.Bucket([]byte(bucket))
+	if b == nil {
+		return nil, fmt.Errorf("bucket %s does not exist", bucket)
+	}
+
+	return b, nil
+}
+
+func (t boltTx) Get(bucket Bucket, key string, v any) error {
+	b, err := t.bucket(bucket)
+	if err != nil {
+		return err
+	}
+
+	value := b.Get([]byte(key))
+	if value == nil {
+		return fmt.Errorf("%s/%s %w", bucket, key, ErrNotFound)
+	}
+
+	err = json.Unmarshal(value, v)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s/%s: %w", bucket, key, err)
+	}
+
+	return nil
+}
+
+func (t boltTx) Put(bucket Bucket, key string, v any) error {
+	b, err := t.bucket(bucket)
+	if err != nil {
+		return err
+	}
+
+	value, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s/%s: %w", bucket, key, err)
+	}
+
+	return b.Put([]byte(key), value)
+}
+
+func (t boltTx) Delete(bucket Bucket, key string) error {
+	b, err := t.bucket(bucket)
+	if err != nil {
+		return err
+	}
+
+	if b.Get([]byte(key)) == nil {
+		return fmt.Errorf("%s/%s %w", bucket, key, ErrNotFound)
+	}
+
+	return b.Delete([]byte(key))
+}
+
+func (t boltTx) ForEach(
+	bucket Bucket,
+	prefix string,
+	fn func(key string, value []byte) error,
+) error {
+	b, err := t.bucket(bucket)
+	if err != nil {
+		return err
+	}
+
+	c := b.Cursor()
+	p := []byte(prefix)
+	for k, v := c.Seek(p); k != nil && bytes.HasPrefix(k, p); k, v = c.Next() {
+		err := fn(string(k), v)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,231 @@
+package flipcamlib
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"time"
+
+	"github.com/MatthiasKunnen/flipcam/pkg/flipcamlib/storage"
+)
+
+// DatabaseFile is the name of the metadata database.
+const DatabaseFile = "flipcam.db"
+
+// Keys of the settings bucket. The video settings are stored per camera, see cameraSettingKey.
+const (
+	settingBroadcastDelay = "broadcastDelay"
+	settingOverlay        = "overlay"
+	settingTransform      = "transform"
+)
+
+// cameraSettingKey returns the key of a setting of the camera.
+func cameraSettingKey(c *camera, setting string) string {
+	return setting + "/" + c.name
+}
+
+// SessionRecord is the metadata of a session that is stored when the muxer starts it.
+type SessionRecord struct {
+	Id        string    `json:"id"`
+	StartedAt time.Time `json:"startedAt"`
+
+	// Camera is the name of the camera that recorded the session.
+	Camera string `json:"camera,omitempty"`
+
+	// VideoCodec is the codec of the incoming stream, empty until the stream is received.
+	VideoCodec string `json:"videoCodec,omitempty"`
+
+	// Transcoding is true if the muxer re-encoded the video.
+	Transcoding bool `json:"transcoding"`
+}
+
+// DefaultDatabasePath returns the path of the metadata database used when Opts.DatabasePath is
+// not set, $XDG_DATA_HOME/flipcam/flipcam.db.
+func DefaultDatabasePath() (string, error) {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dataHome = path.Join(home, ".local", "share")
+	}
+
+	return path.Join(dataHome, "flipcam", DatabaseFile), nil
+}
+
+// migrateDatabase moves the database from the HLS output directory, where it used to be stored,
+// to dbPath. Everything in the HLS output directory is served to the network.
+func migrateDatabase(hlsOutputDir string, dbPath string) error {
+	if path.Dir(path.Clean(dbPath)) == path.Clean(hlsOutputDir) {
+		return errors.New("the database must not be stored in the HLS output directory")
+	}
+
+	oldPath := path.Join(hlsOutputDir, DatabaseFile)
+	_, err := os.Stat(oldPath)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil
+	case err != nil:
+		return err
+	}
+
+	_, err = os.Stat(dbPath)
+	if err == nil {
+		return fmt.Errorf(
+			"both %s and %s exist, remove the one in the HLS output directory",
+			oldPath,
+			dbPath,
+		)
+	}
+
+	err = os.Rename(oldPath, dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to move %s to %s, move it manually: %w", oldPath, dbPath, err)
+	}
+	log.Printf("[storage]: moved the database from %s to %s\n", oldPath, dbPath)
+
+	return nil
+}
+
+// sessionKey returns the key of a value that belongs to the session, e.g. an annotation.
+func sessionKey(session Session, id string) string {
+	return sessionKeyPrefix(session) + id
+}
+
+// sessionKeyPrefix returns the prefix of the keys of the values that belong to the session.
+func sessionKeyPrefix(session Session) string {
+	return session.Id + "/"
+}
+
+// openStorage opens the database and restores the stored settings.
+func (f *FlipCam) openStorage() error {
+	dbPath := f.databasePath
+	if dbPath == "" {
+		var err error
+		dbPath, err = DefaultDatabasePath()
+		if err != nil {
+			return fmt.Errorf("failed to determine the database path: %w", err)
+		}
+	}
+	err := os.MkdirAll(path.Dir(dbPath), 0o700)
+	if err != nil {
+		return err
+	}
+	err = migrateDatabase(f.hlsOutputDir, dbPath)
+	if err != nil {
+		return err
+	}
+
+	db, err := storage.Open(dbPath)
+	if err != nil {
+		return err
+	}
+	f.db = db
+	f.store = db
+
+	return f.store.View(func(tx storage.Tx) error {
+		var broadcastDelay BroadcastDelay
+		err := tx.Get(storage.BucketSettings, settingBroadcastDelay, &broadcastDelay)
+		switch {
+		case err == nil:
+			f.setBroadcastDelay(broadcastDelay)
+		case !errors.Is(err, storage.ErrNotFound):
+			return err
+		}
+
+		for _, c := range f.cameras {
+			var overlay Overlay
+			found, err := getCameraSetting(tx, c, settingOverlay, &overlay)
+			switch {
+			case err != nil:
+				return err
+			case found:
+				c.setOverlay(overlay)
+			}
+
+			var transform VideoTransform
+			found, err = getCameraSetting(tx, c, settingTransform, &transform)
+			switch {
+			case err != nil:
+				return err
+			case found:
+				c.setTransform(transform)
+			}
+		}
+
+		return nil
+	})
+}
+
+// getCameraSetting reads a setting of the camera. Settings stored before they were kept per
+// camera apply to every camera.
+func getCameraSetting(tx storage.Tx, c *camera, setting string, v any) (bool, error) {
+	err := tx.Get(storage.BucketSettings, cameraSettingKey(c, setting), v)
+	if errors.Is(err, storage.ErrNotFound) {
+		err = tx.Get(storage.BucketSettings, setting, v)
+	}
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, storage.ErrNotFound):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// closeStorage closes the database once every part has stopped using it.
+func (f *FlipCam) closeStorage() {
+	if f.db == nil {
+		return
+	}
+
+	err := f.db.Close()
+	if err != nil {
+		f.addShutdownError(fmt.Errorf("[storage]: failed to close: %w", err))
+	}
+}
+
+// saveSetting stores a setting so that it survives a restart.
+func (f *FlipCam) saveSetting(key string, v any) error {
+	return f.store.Update(func(tx storage.Tx) error {
+		return tx.Put(storage.BucketSettings, key, v)
+	})
+}
+
+// recordSession stores the metadata of a session that the muxer started.
+func (f *FlipCam) recordSession(record SessionRecord) {
+	err := f.store.Update(func(tx storage.Tx) error {
+		return tx.Put(storage.BucketSessions, record.Id, record)
+	})
+	if err != nil {
+		log.Printf("[muxer]: failed to record session %s: %v\n", record.Id, err)
+	}
+}
+
+// handleBackup downloads a copy of the database, or a JSON export if format=json.
+func (f *FlipCam) handleBackup(w http.ResponseWriter, r *http.Request) {
+	filename := fmt.Sprintf("flipcam-%s", time.Now().Format("20060102-150405"))
+	var err error
+	switch r.URL.Query().Get("format") {
+	case "", "db":
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".db"))
+		err = f.db.Backup(w)
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".json"))
+		err = f.db.Export(w)
+	default:
+		http.Error(w, "format must be db or json", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("web: backup: %v\n", err)
+	}
+}
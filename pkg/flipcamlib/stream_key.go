@@ -0,0 +1,89 @@
+package flipcamlib
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// minStreamKeyLength is the minimum length of a stream key, shorter keys are easily guessed.
+const minStreamKeyLength = 8
+
+var streamKeyRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// validateStreamKeys returns an error if a key can't be used as the stream name of an RTMP URL,
+// is too short, or is used by two cameras. If keys are set, every camera must have one as a
+// camera without keys accepts any publisher.
+func (f *FlipCam) validateStreamKeys() error {
+	if len(f.streamKeys) == 0 {
+		return nil
+	}
+
+	for name := range f.streamKeys {
+		_, err := f.getCamera(name)
+		if err != nil {
+			return fmt.Errorf("stream key for unknown camera %q", name)
+		}
+	}
+
+	usedBy := make(map[string]string)
+	for _, c := range f.cameras {
+		if len(c.streamKeys) == 0 {
+			return fmt.Errorf(
+				"camera %q has no stream key, give every camera a key or none",
+				c.name,
+			)
+		}
+		for _, key := range c.streamKeys {
+			switch {
+			case !streamKeyRegexp.MatchString(key):
+				return errors.New("invalid stream key, only letters, digits, _, and - are allowed")
+			case len(key) < minStreamKeyLength:
+				return fmt.Errorf("stream keys must be at least %d characters", minStreamKeyLength)
+			case usedBy[key] != "" && usedBy[key] != c.name:
+				return fmt.Errorf("cameras %q and %q use the same stream key", usedBy[key], c.name)
+			}
+			usedBy[key] = c.name
+		}
+	}
+
+	return nil
+}
+
+// streamName returns the stream name that the camera publishes with, see cameraForStream. This is
+// the first stream key, or the name of the camera if there are several cameras without keys.
+func (f *FlipCam) streamName(c *camera) string {
+	switch {
+	case len(c.streamKeys) > 0:
+		return c.streamKeys[0]
+	case len(f.cameras) > 1:
+		return c.name
+	default:
+		return ""
+	}
+}
+
+// publishUrl returns the URL, including the stream name, that the camera must publish to.
+func (f *FlipCam) publishUrl(c *camera, host string) string {
+	return rtmpUrl(host) + f.streamName(c)
+}
+
+// CameraPublishUrl is the URL a camera must publish to.
+type CameraPublishUrl struct {
+	Camera string
+	Url    string
+}
+
+// PublishUrls returns the RTMP URL, including the stream key, of every camera. These are the
+// URLs to enter in the camera, e.g. in Quik or a GoPro Labs QR code.
+func (f *FlipCam) PublishUrls() []CameraPublishUrl {
+	urls := make([]CameraPublishUrl, len(f.cameras))
+	for i, c := range f.cameras {
+		urls[i] = CameraPublishUrl{
+			Camera: c.name,
+			Url:    f.publishUrl(c, f.RouterIp().Addr().String()),
+		}
+	}
+
+	return urls
+}
@@ -0,0 +1,109 @@
+package flipcamlib
+
+import "testing"
+
+func TestValidateStreamKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		cameras []string
+		keys    map[string][]string
+		wantErr bool
+	}{
+		{
+			name:    "no keys",
+			cameras: []string{"side", "front"},
+		},
+		{
+			name:    "key per camera",
+			cameras: []string{"side", "front"},
+			keys: map[string][]string{
+				"side":  {"side-key-1"},
+				"front": {"front_key", "Front-Key-2"},
+			},
+		},
+		{
+			name:    "camera without key",
+			cameras: []string{"side", "front"},
+			keys:    map[string][]string{"side": {"side-key-1"}},
+			wantErr: true,
+		},
+		{
+			name:    "unknown camera",
+			cameras: []string{"side"},
+			keys:    map[string][]string{"side": {"side-key-1"}, "back": {"back-key-1"}},
+			wantErr: true,
+		},
+		{
+			name:    "shared key",
+			cameras: []string{"side", "front"},
+			keys:    map[string][]string{"side": {"same-key-1"}, "front": {"same-key-1"}},
+			wantErr: true,
+		},
+		{
+			name:    "too short",
+			cameras: []string{"side"},
+			keys:    map[string][]string{"side": {"short"}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid character",
+			cameras: []string{"side"},
+			keys:    map[string][]string{"side": {"side/key-1"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := New(Opts{Cameras: tt.cameras, StreamKeys: tt.keys})
+			err := f.validateStreamKeys()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateStreamKeys() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPublishUrl(t *testing.T) {
+	tests := []struct {
+		name    string
+		cameras []string
+		keys    map[string][]string
+		want    []string
+	}{
+		{
+			name:    "keys",
+			cameras: []string{"side", "front"},
+			keys: map[string][]string{
+				"side":  {"side-key-1", "side-key-2"},
+				"front": {"front-key"},
+			},
+			want: []string{
+				"rtmp://192.168.23.1:1935/camera/side-key-1",
+				"rtmp://192.168.23.1:1935/camera/front-key",
+			},
+		},
+		{
+			name:    "cameras without keys",
+			cameras: []string{"side", "front"},
+			want: []string{
+				"rtmp://192.168.23.1:1935/camera/side",
+				"rtmp://192.168.23.1:1935/camera/front",
+			},
+		},
+		{
+			name: "one camera without keys",
+			want: []string{"rtmp://192.168.23.1:1935/camera/"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := New(Opts{Cameras: tt.cameras, StreamKeys: tt.keys})
+			for i, c := range f.cameras {
+				got := f.publishUrl(c, "192.168.23.1")
+				if got != tt.want[i] {
+					t.Errorf("publishUrl() of %s = %q, want %q", c.name, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
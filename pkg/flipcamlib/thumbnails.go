@@ -0,0 +1,253 @@
+package flipcamlib
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Thumbnails per row and column of a sprite sheet.
+	spriteColumns = 10
+	spriteRows    = 10
+
+	thumbnailWidth = 160
+
+	thumbnailsVttSuffix = "_thumbnails.vtt"
+)
+
+// MinThumbnailInterval is the shortest time between thumbnails. Every thumbnail runs ffmpeg, so
+// shorter intervals would mostly keep the CPU busy.
+const MinThumbnailInterval = time.Second
+
+// ValidateThumbnailInterval returns an error if the interval is shorter than
+// MinThumbnailInterval.
+func ValidateThumbnailInterval(interval time.Duration) error {
+	if interval < MinThumbnailInterval {
+		return fmt.Errorf(
+			"the thumbnail interval must be at least %s, got %s",
+			MinThumbnailInterval,
+			interval,
+		)
+	}
+
+	return nil
+}
+
+// thumbnailJob asks the thumbnail worker to create the thumbnails of a segment.
+type thumbnailJob struct {
+	session Session
+	mapUri  string
+	segment MediaSegment
+
+	// finished is set when no more segments will follow for the session.
+	finished bool
+}
+
+// thumbnailSession is the state of the sprite sheets of a session.
+type thumbnailSession struct {
+	// cues of the WebVTT file.
+	cues []string
+
+	// next is the index of the next thumbnail.
+	next int
+
+	// sheet is the sprite sheet that is being filled.
+	sheet *image.RGBA
+
+	thumbHeight int
+}
+
+// thumbnailQueuer is the session processor that queues the segments for the thumbnail worker.
+type thumbnailQueuer struct {
+	jobs    chan<- thumbnailJob
+	session Session
+}
+
+func (q *thumbnailQueuer) processSegment(playlist *MediaPlaylist, segment MediaSegment) error {
+	q.queue(thumbnailJob{session: q.session, mapUri: playlist.MapUri, segment: segment})
+	return nil
+}
+
+func (q *thumbnailQueuer) finish(*MediaPlaylist) error {
+	q.queue(thumbnailJob{session: q.session, finished: true})
+	return nil
+}
+
+func (q *thumbnailQueuer) queue(job thumbnailJob) {
+	select {
+	case q.jobs <- job:
+	default:
+		// Thumbnails are a nicety, ingest must never wait for them
+		log.Printf("[thumbnails]: queue full, skipping segment %s\n", job.segment.Uri)
+	}
+}
+
+// runThumbnailer creates thumbnails of the segments that are queued by the session watcher.
+// Thumbnails are extracted every thumbnailInterval and combined into sprite sheets which are
+// indexed by a WebVTT file per session.
+func (f *FlipCam) runThumbnailer(ctx context.Context) {
+	sessions := make(map[string]*thumbnailSession)
+	f.startupWg.Done()
+	defer f.shutdownWg.Done() // Add occurred in calling function
+
+	for {
+		var job thumbnailJob
+		select {
+		case <-f.stop:
+			return
+		case job = <-f.thumbnailJobs:
+		}
+
+		state, found := sessions[job.session.Id]
+		if job.finished {
+			delete(sessions, job.session.Id)
+			continue
+		}
+		if !found {
+			state = &thumbnailSession{}
+			sessions[job.session.Id] = state
+		}
+
+		err := f.createThumbnails(job, state)
+		if err != nil {
+			log.Printf("[thumbnails]: session %s: %v\n", job.session.Id, err)
+		}
+	}
+}
+
+func (f *FlipCam) createThumbnails(job thumbnailJob, state *thumbnailSession) error {
+	segment := job.segment
+	segmentEnd := segment.MediaTime + segment.Duration
+	for {
+		at := time.Duration(state.next) * f.thumbnailInterval
+		if at >= segmentEnd {
+			return nil
+		}
+
+		offset := max(at-segment.MediaTime, 0)
+		err := f.createThumbnail(job, state, offset)
+		if err != nil {
+			// Skip the thumbnail rather than retrying it forever
+			log.Printf("[thumbnails]: session %s: thumbnail %d: %v\n", job.session.Id, state.next, err)
+		}
+		state.next++
+	}
+}
+
+func (f *FlipCam) createThumbnail(job thumbnailJob, state *thumbnailSession, offset time.Duration) error {
+	input, err := job.session.openSegments(job.mapUri, []MediaSegment{job.segment})
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	frame, err := extractFrameLowPriority(ctx, input, offset, thumbnailWidth, ImageJpeg)
+	if err != nil {
+		return err
+	}
+
+	thumb, err := jpeg.Decode(bytes.NewReader(frame))
+	if err != nil {
+		return fmt.Errorf("failed to decode thumbnail: %w", err)
+	}
+
+	index := state.next
+	sheetIndex := index / (spriteColumns * spriteRows)
+	position := index % (spriteColumns * spriteRows)
+	if position == 0 || state.sheet == nil {
+		state.thumbHeight = thumb.Bounds().Dy()
+		state.sheet = image.NewRGBA(image.Rect(
+			0,
+			0,
+			thumbnailWidth*spriteColumns,
+			state.thumbHeight*spriteRows,
+		))
+	}
+
+	x := (position % spriteColumns) * thumbnailWidth
+	y := (position / spriteColumns) * state.thumbHeight
+	rect := image.Rect(x, y, x+thumbnailWidth, y+state.thumbHeight)
+	draw.Draw(state.sheet, rect, thumb, thumb.Bounds().Min, draw.Src)
+
+	var sheet bytes.Buffer
+	err = jpeg.Encode(&sheet, state.sheet, &jpeg.Options{Quality: 75})
+	if err != nil {
+		return fmt.Errorf("failed to encode sprite sheet: %w", err)
+	}
+	err = writeFileAtomic(job.session.filePath(spriteSuffix(sheetIndex)), sheet.Bytes(), 0o644)
+	if err != nil {
+		return err
+	}
+
+	start := time.Duration(index) * f.thumbnailInterval
+	state.cues = append(state.cues, fmt.Sprintf(
+		"%s --> %s\nthumbnails/%d.jpg#xywh=%d,%d,%d,%d\n",
+		formatVttTime(start),
+		formatVttTime(start+f.thumbnailInterval),
+		sheetIndex,
+		x,
+		y,
+		thumbnailWidth,
+		state.thumbHeight,
+	))
+
+	vtt := "WEBVTT\n\n" + strings.Join(state.cues, "\n")
+	return writeFileAtomic(job.session.filePath(thumbnailsVttSuffix), []byte(vtt), 0o644)
+}
+
+func spriteSuffix(sheetIndex int) string {
+	return "_sprite_" + strconv.Itoa(sheetIndex) + ".jpg"
+}
+
+func formatVttTime(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3_600_000, ms/60_000%60, ms/1000%60, ms%1000)
+}
+
+func (f *FlipCam) handleThumbnailsVtt(w http.ResponseWriter, r *http.Request) {
+	session, err := f.getSession(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Content-Type", "text/vtt")
+	http.ServeFile(w, r, session.filePath(thumbnailsVttSuffix))
+}
+
+func (f *FlipCam) handleSpriteSheet(w http.ResponseWriter, r *http.Request) {
+	session, err := f.getSession(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	sheetIndex, err := strconv.Atoi(strings.TrimSuffix(r.PathValue("file"), ".jpg"))
+	if err != nil || sheetIndex < 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	sheetPath := session.filePath(spriteSuffix(sheetIndex))
+	if _, err := os.Stat(sheetPath); err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	// The last sheet grows while recording
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeFile(w, r, sheetPath)
+}
@@ -0,0 +1,220 @@
+package flipcamlib
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// The calibration timecode is a barcode of vertical bars that encodes the time of the server, it
+// is drawn by static/calibrate.mjs. It is made of units of equal width, each black or white:
+//   - Start guard: black, black, white, black.
+//   - 24 bits, most significant first, Manchester encoded: 1 is black then white, 0 is white then
+//     black. The first 20 bits are the time in steps of timecodeStep, modulo 2^20. The last 4 are
+//     the sum of the nibbles of the time, modulo 16.
+//   - End guard: black, white, black.
+//
+// The barcode is surrounded by white. The guards differ so that a mirrored barcode is not read
+// as a different value.
+const (
+	timecodeBits      = 24
+	timecodeTimeBits  = 20
+	timecodeUnits     = 4 + 2*timecodeBits + 3
+	timecodeStep      = 10 * time.Millisecond
+	timecodeMinUnitPx = 1.5
+)
+
+// timecodePeriod is the duration after which the timecode wraps.
+const timecodePeriod = timecodeStep << timecodeTimeBits
+
+type grayFrame struct {
+	width  int
+	height int
+	pix    []byte
+}
+
+// decodeTimecode returns the value of a timecode in the frame. Rows and columns spread over the
+// frame are scanned, so the barcode can be rotated by multiples of 90° and mirrored.
+func decodeTimecode(frame grayFrame) (uint32, bool) {
+	const scanLines = 9
+	line := make([]byte, max(frame.width, frame.height))
+	for i := 1; i <= scanLines; i++ {
+		y := frame.height * i / (scanLines + 1)
+		value, ok := decodeTimecodeLine(frame.pix[y*frame.width : (y+1)*frame.width])
+		if ok {
+			return value, true
+		}
+
+		x := frame.width * i / (scanLines + 1)
+		column := line[:frame.height]
+		for y := range column {
+			column[y] = frame.pix[y*frame.width+x]
+		}
+		value, ok = decodeTimecodeLine(column)
+		if ok {
+			return value, true
+		}
+	}
+
+	return 0, false
+}
+
+type timecodeRun struct {
+	start int
+	end   int
+	black bool
+}
+
+// decodeTimecodeLine returns the value of a timecode that crosses the line of luma values.
+func decodeTimecodeLine(line []byte) (uint32, bool) {
+	if float64(len(line)) < timecodeUnits*timecodeMinUnitPx {
+		return 0, false
+	}
+	minLuma, maxLuma := slices.Min(line), slices.Max(line)
+	if maxLuma-minLuma < 48 {
+		return 0, false
+	}
+	threshold := minLuma/2 + maxLuma/2
+
+	var runs []timecodeRun
+	for i, luma := range line {
+		black := luma < threshold
+		if len(runs) == 0 || runs[len(runs)-1].black != black {
+			runs = append(runs, timecodeRun{start: i, black: black})
+		}
+		runs[len(runs)-1].end = i + 1
+	}
+
+	// Every bit has a transition, the guards have three runs each. Neighboring units of the same
+	// color merge into one run.
+	const minRuns = timecodeBits + 4
+	samples := make([]bool, timecodeUnits)
+	reversed := make([]bool, timecodeUnits)
+	for i, first := range runs {
+		if !first.black {
+			continue
+		}
+
+		for j := i + minRuns; j < len(runs) && j < i+timecodeUnits; j++ {
+			last := runs[j]
+			if !last.black {
+				continue
+			}
+
+			unit := float64(last.end-first.start) / timecodeUnits
+			if unit < timecodeMinUnitPx {
+				continue
+			}
+			for k := range samples {
+				samples[k] = line[first.start+int((float64(k)+0.5)*unit)] < threshold
+				reversed[timecodeUnits-1-k] = samples[k]
+			}
+
+			if value, ok := decodeTimecodeUnits(samples); ok {
+				return value, true
+			}
+			if value, ok := decodeTimecodeUnits(reversed); ok {
+				return value, true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// decodeTimecodeUnits decodes the colors of the units of a timecode, true being black.
+func decodeTimecodeUnits(units []bool) (uint32, bool) {
+	startGuard := []bool{true, true, false, true}
+	endGuard := []bool{true, false, true}
+	if !slices.Equal(units[:4], startGuard) || !slices.Equal(units[timecodeUnits-3:], endGuard) {
+		return 0, false
+	}
+
+	var bits uint32
+	for i := range timecodeBits {
+		first, second := units[4+2*i], units[5+2*i]
+		if first == second {
+			return 0, false
+		}
+		bits <<= 1
+		if first {
+			bits |= 1
+		}
+	}
+
+	value := bits >> (timecodeBits - timecodeTimeBits)
+	if bits&0xf != timecodeChecksum(value) {
+		return 0, false
+	}
+
+	return value, true
+}
+
+func timecodeChecksum(value uint32) uint32 {
+	var sum uint32
+	for ; value > 0; value >>= 4 {
+		sum += value & 0xf
+	}
+
+	return sum & 0xf
+}
+
+// timecodeTime returns the time that the timecode value represents, assuming it was displayed
+// at most a period before the frame was captured.
+func timecodeTime(value uint32, frameTime time.Time) time.Time {
+	// A timecode displayed slightly after the frame time is a measurement error, not a
+	// timecode from the previous period.
+	reference := frameTime.Add(time.Second)
+	sinceEpoch := reference.Sub(time.Unix(0, 0))
+	periodStart := sinceEpoch - sinceEpoch%timecodePeriod
+	t := periodStart + time.Duration(value)*timecodeStep
+	if t > sinceEpoch {
+		t -= timecodePeriod
+	}
+
+	return time.Unix(0, 0).Add(t)
+}
+
+// readPgmFrames parses a stream of binary PGM images as written by ffmpeg's pgm encoder.
+func readPgmFrames(data []byte) ([]grayFrame, error) {
+	var frames []grayFrame
+	for len(data) > 0 {
+		var frame grayFrame
+		var magic string
+		var maxValue int
+		header := string(data[:min(len(data), 64)])
+		_, err := fmt.Sscan(header, &magic, &frame.width, &frame.height, &maxValue)
+		if err != nil || magic != "P5" || maxValue != 255 {
+			return nil, fmt.Errorf("invalid PGM header in frame %d", len(frames))
+		}
+		if frame.width <= 0 || frame.height <= 0 || frame.width > len(data)/frame.height {
+			return nil, fmt.Errorf("invalid size of frame %d", len(frames))
+		}
+
+		// The header ends with the fourth whitespace-separated field and one whitespace byte
+		headerEnd := 0
+		for field := 0; field < 4; field++ {
+			for headerEnd < len(data) && isPgmSpace(data[headerEnd]) {
+				headerEnd++
+			}
+			for headerEnd < len(data) && !isPgmSpace(data[headerEnd]) {
+				headerEnd++
+			}
+		}
+		headerEnd++
+
+		size := frame.width * frame.height
+		if headerEnd+size > len(data) {
+			return nil, fmt.Errorf("frame %d is truncated", len(frames))
+		}
+		frame.pix = data[headerEnd : headerEnd+size]
+		frames = append(frames, frame)
+		data = data[headerEnd+size:]
+	}
+
+	return frames, nil
+}
+
+func isPgmSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
@@ -0,0 +1,216 @@
+package flipcamlib
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// testTimecodeUnits returns the colors of the units of the timecode of value, like
+// static/calibrate.mjs draws them.
+func testTimecodeUnits(value uint32, checksum uint32) []bool {
+	units := []bool{true, true, false, true}
+	bits := value<<(timecodeBits-timecodeTimeBits) | checksum&0xf
+	for i := timecodeBits - 1; i >= 0; i-- {
+		bit := bits>>i&1 == 1
+		units = append(units, bit, !bit)
+	}
+
+	return append(units, true, false, true)
+}
+
+// testTimecodeFrame returns a white frame with the units drawn as vertical bars of unitPx pixels
+// wide in the middle. black and white are the luma values.
+func testTimecodeFrame(units []bool, unitPx int, black byte, white byte) grayFrame {
+	frame := grayFrame{width: len(units)*unitPx + 40, height: 30}
+	frame.pix = make([]byte, frame.width*frame.height)
+	for y := range frame.height {
+		for x := range frame.width {
+			unit := (x - 20) / unitPx
+			luma := white
+			if x >= 20 && unit < len(units) && units[unit] {
+				luma = black
+			}
+			frame.pix[y*frame.width+x] = luma
+		}
+	}
+
+	return frame
+}
+
+// rotate returns the frame rotated by 90°.
+func (frame grayFrame) rotate() grayFrame {
+	rotated := grayFrame{width: frame.height, height: frame.width}
+	rotated.pix = make([]byte, len(frame.pix))
+	for y := range frame.height {
+		for x := range frame.width {
+			rotated.pix[x*rotated.width+frame.height-1-y] = frame.pix[y*frame.width+x]
+		}
+	}
+
+	return rotated
+}
+
+// mirror returns the frame mirrored horizontally.
+func (frame grayFrame) mirror() grayFrame {
+	mirrored := grayFrame{width: frame.width, height: frame.height}
+	mirrored.pix = make([]byte, len(frame.pix))
+	for y := range frame.height {
+		for x := range frame.width {
+			mirrored.pix[y*frame.width+frame.width-1-x] = frame.pix[y*frame.width+x]
+		}
+	}
+
+	return mirrored
+}
+
+func TestDecodeTimecode(t *testing.T) {
+	const value = 0x9a5c3
+	units := testTimecodeUnits(value, timecodeChecksum(value))
+	wrongChecksum := testTimecodeUnits(value, timecodeChecksum(value)+1)
+
+	tests := []struct {
+		name      string
+		frame     grayFrame
+		wantFound bool
+	}{
+		{
+			name:      "horizontal",
+			frame:     testTimecodeFrame(units, 3, 10, 240),
+			wantFound: true,
+		},
+		{
+			name:      "mirrored",
+			frame:     testTimecodeFrame(units, 3, 10, 240).mirror(),
+			wantFound: true,
+		},
+		{
+			name:      "rotated",
+			frame:     testTimecodeFrame(units, 3, 10, 240).rotate(),
+			wantFound: true,
+		},
+		{
+			name:      "low contrast",
+			frame:     testTimecodeFrame(units, 3, 90, 150),
+			wantFound: true,
+		},
+		{
+			name:  "no contrast",
+			frame: testTimecodeFrame(units, 3, 120, 140),
+		},
+		{
+			name:  "units too narrow",
+			frame: testTimecodeFrame(units, 1, 10, 240),
+		},
+		{
+			name:  "wrong checksum",
+			frame: testTimecodeFrame(wrongChecksum, 3, 10, 240),
+		},
+		{
+			name:  "blank",
+			frame: testTimecodeFrame(make([]bool, len(units)), 3, 10, 240),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := decodeTimecode(tt.frame)
+			switch {
+			case found != tt.wantFound:
+				t.Errorf("decodeTimecode() found = %v, want %v", found, tt.wantFound)
+			case found && got != value:
+				t.Errorf("decodeTimecode() = %#x, want %#x", got, value)
+			}
+		})
+	}
+}
+
+func TestTimecodeTime(t *testing.T) {
+	frameTime := time.Unix(1750000000, 0)
+	sinceEpoch := time.Duration(frameTime.UnixNano())
+	value := uint32(sinceEpoch % timecodePeriod / timecodeStep)
+
+	tests := []struct {
+		name  string
+		value uint32
+		want  time.Time
+	}{
+		{
+			name:  "frame time",
+			value: value,
+			want:  frameTime,
+		},
+		{
+			name:  "before the frame",
+			value: value - 50,
+			want:  frameTime.Add(-500 * time.Millisecond),
+		},
+		{
+			name:  "slightly after the frame",
+			value: value + 20,
+			want:  frameTime.Add(200 * time.Millisecond),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := timecodeTime(tt.value, frameTime)
+			if !got.Equal(tt.want) {
+				t.Errorf("timecodeTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadPgmFrames(t *testing.T) {
+	frame := func(header string, pixels int) []byte {
+		return append([]byte(header), make([]byte, pixels)...)
+	}
+
+	tests := []struct {
+		name       string
+		data       []byte
+		wantFrames int
+		wantErr    bool
+	}{
+		{
+			name:       "two frames",
+			data:       append(frame("P5\n4 2\n255\n", 8), frame("P5 4 2 255 ", 8)...),
+			wantFrames: 2,
+		},
+		{
+			name:    "truncated",
+			data:    frame("P5\n4 2\n255\n", 7),
+			wantErr: true,
+		},
+		{
+			name:    "other format",
+			data:    frame("P6\n4 2\n255\n", 24),
+			wantErr: true,
+		},
+		{
+			name:    "negative size",
+			data:    frame("P5\n-4 2\n255\n", 8),
+			wantErr: true,
+		},
+		{
+			name:    "size overflows",
+			data:    frame(fmt.Sprintf("P5\n%d %d\n255\n", 1<<32, 1<<32), 0),
+			wantErr: true,
+		},
+		{
+			name:    "header only",
+			data:    []byte("P5\n4"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readPgmFrames(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readPgmFrames() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != tt.wantFrames {
+				t.Errorf("readPgmFrames() returned %d frames, want %d", len(got), tt.wantFrames)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package flipcamlib
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+// TimeSample is the response of the time API. Clients use it like an NTP exchange to estimate
+// the offset between their clock and the server's clock, see static/helpers.mjs.
+// Times are Unix times in milliseconds.
+type TimeSample struct {
+	// ReceiveTime is the time at which the request was received.
+	ReceiveTime float64 `json:"receiveTime"`
+
+	// TransmitTime is the time at which the response was sent.
+	TransmitTime float64 `json:"transmitTime"`
+}
+
+func unixMillis(t time.Time) float64 {
+	return float64(t.UnixMicro()) / 1000
+}
+
+func (f *FlipCam) handleTime(w http.ResponseWriter, r *http.Request) {
+	receiveTime := time.Now()
+	w.Header().Set("Cache-Control", "no-store")
+	writeJson(w, TimeSample{
+		ReceiveTime:  unixMillis(receiveTime),
+		TransmitTime: unixMillis(time.Now()),
+	})
+}
+
+// ntpEpochOffset is the number of seconds between the NTP epoch, 1900, and the Unix epoch.
+const ntpEpochOffset = 2208988800
+
+const sntpPacketSize = 48
+
+// runSntpServer answers SNTP requests, RFC 4330, so that devices without internet access can
+// sync their clock to the server. It only runs if an address is configured.
+func (f *FlipCam) runSntpServer(ctx context.Context) {
+	if f.sntpAddr == "" {
+		f.startupWg.Done()
+		f.shutdownWg.Done()
+		return
+	}
+
+	conn, err := (&net.ListenConfig{}).ListenPacket(ctx, "udp", f.sntpAddr)
+	if err != nil {
+		f.shutdownWg.Done()
+		f.stopWithError(fmt.Errorf("[sntp]: failed to listen on %s: %w", f.sntpAddr, err))
+		return
+	}
+	log.Printf("[sntp]: listening on %s\n", f.sntpAddr)
+	f.startupWg.Done()
+
+	go func() {
+		<-f.stop
+		err := conn.Close()
+		if err != nil {
+			f.addShutdownError(fmt.Errorf("[sntp]: failed to close: %w", err))
+		}
+	}()
+
+	defer f.shutdownWg.Done()
+	request := make([]byte, 512)
+	for {
+		n, addr, err := conn.ReadFrom(request)
+		receiveTime := time.Now()
+		switch {
+		case errors.Is(err, net.ErrClosed):
+			log.Println("[sntp]: shutdown cleanly")
+			return
+		case err != nil:
+			log.Printf("[sntp]: failed to read: %v\n", err)
+			continue
+		}
+
+		response, ok := sntpResponse(request[:n], receiveTime)
+		if !ok {
+			continue
+		}
+
+		_, err = conn.WriteTo(response, addr)
+		if err != nil {
+			log.Printf("[sntp]: failed to respond to %s: %v\n", addr, err)
+		}
+	}
+}
+
+// sntpResponse returns the server response to an SNTP client request. ok is false if the request
+// must be ignored.
+func sntpResponse(request []byte, receiveTime time.Time) (response []byte, ok bool) {
+	if len(request) < sntpPacketSize {
+		return nil, false
+	}
+	version := request[0] >> 3 & 0b111
+	mode := request[0] & 0b111
+	if mode != 3 || version < 1 || version > 4 {
+		return nil, false
+	}
+
+	response = make([]byte, sntpPacketSize)
+	// Leap indicator 0, the version of the client, mode 4 (server)
+	response[0] = version<<3 | 4
+	// The clock is not synchronized to a reference, report a stratum similar to an
+	// undisciplined local clock.
+	response[1] = 10
+	// Poll interval of the client
+	response[2] = request[2]
+	// Precision, 2^-20 seconds
+	response[3] = 0xec
+	// Root dispersion of 10 ms, in 16.16 fixed point seconds
+	binary.BigEndian.PutUint32(response[8:], 655)
+	copy(response[12:16], "LOCL")
+	putNtpTime(response[16:], receiveTime)
+	// Originate timestamp is the transmit timestamp of the client
+	copy(response[24:32], request[40:48])
+	putNtpTime(response[32:], receiveTime)
+	putNtpTime(response[40:], time.Now())
+
+	return response, true
+}
+
+// putNtpTime writes t as a 64-bit NTP timestamp.
+func putNtpTime(b []byte, t time.Time) {
+	seconds := uint64(t.Unix() + ntpEpochOffset)
+	fraction := uint64(t.Nanosecond()) << 32 / uint64(time.Second)
+	binary.BigEndian.PutUint64(b, seconds<<32|fraction)
+}
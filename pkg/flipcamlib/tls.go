@@ -0,0 +1,201 @@
+package flipcamlib
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	caCertFile = "ca.crt"
+	caKeyFile  = "ca.key"
+
+	// caValidity is long because every device has to trust the CA again when it changes.
+	caValidity = 10 * 365 * 24 * time.Hour
+
+	// leafValidity is the maximum that Apple devices accept. The leaf certificate is issued
+	// again on every start.
+	leafValidity = 397 * 24 * time.Hour
+)
+
+// localCa is the certificate authority that issues the certificate of the web UI when
+// Opts.TlsDir is set. Devices trust it once by installing the root certificate from /ca.crt.
+type localCa struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+// loadOrCreateCa reads the CA from the directory or, if it doesn't exist yet, generates it and
+// writes it to the directory.
+func loadOrCreateCa(dir string) (*localCa, error) {
+	certPem, err := os.ReadFile(filepath.Join(dir, caCertFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return createCa(dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	keyPem, err := os.ReadFile(filepath.Join(dir, caKeyFile))
+	if err != nil {
+		return nil, err
+	}
+
+	keyPair, err := tls.X509KeyPair(certPem, keyPem)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CA in %s: %w", dir, err)
+	}
+	signer, ok := keyPair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("invalid CA in %s: unsupported key type", dir)
+	}
+
+	return &localCa{
+		cert: keyPair.Leaf,
+		key:  signer,
+	}, nil
+}
+
+func createCa(dir string) (*localCa, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: randomSerial(),
+		Subject: pkix.Name{
+			CommonName:   "FlipCam Local CA",
+			Organization: []string{"FlipCam"},
+		},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDer})
+	err = os.MkdirAll(dir, 0o700)
+	if err != nil {
+		return nil, err
+	}
+	// The key is written first so that a CA certificate on disk always has its key.
+	err = os.WriteFile(filepath.Join(dir, caKeyFile), keyPem, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	err = os.WriteFile(filepath.Join(dir, caCertFile), certPem, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &localCa{
+		cert: cert,
+		key:  key,
+	}, nil
+}
+
+// issue returns a certificate for the IP addresses and DNS names signed by the CA.
+func (ca *localCa) issue(ips []net.IP, dnsNames []string) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: randomSerial(),
+		Subject:      pkix.Name{CommonName: "FlipCam"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(leafValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  ips,
+		DNSNames:     dnsNames,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, key.Public(), ca.key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{
+		Certificate: [][]byte{der, ca.cert.Raw},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}, nil
+}
+
+func randomSerial() *big.Int {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		panic(err) // crypto/rand never fails on Linux
+	}
+
+	return serial
+}
+
+// setupTls loads the CA and issues the certificate of the web UI for the router IP and the
+// hostname.
+func (f *FlipCam) setupTls() error {
+	if f.tlsDir == "" {
+		return nil
+	}
+	if !f.serveHls {
+		return errors.New("TLS is only available when the HLS files are served by flipcam, " +
+			"otherwise Caddy terminates TLS")
+	}
+
+	ca, err := loadOrCreateCa(f.tlsDir)
+	if err != nil {
+		return fmt.Errorf("failed to load the CA: %w", err)
+	}
+
+	var dnsNames []string
+	if f.hostname != "" {
+		dnsNames = append(dnsNames, f.hostname)
+	}
+	cert, err := ca.issue([]net.IP{f.RouterIp().Addr().AsSlice()}, dnsNames)
+	if err != nil {
+		return fmt.Errorf("failed to issue the certificate: %w", err)
+	}
+
+	f.ca = ca
+	f.tlsConfig = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package flipcamlib
+
+import (
+	"fmt"
+)
+
+// TranscodePolicy determines when the muxer re-encodes the incoming video to H.264.
+type TranscodePolicy string
+
+const (
+	// TranscodeAuto transcodes only when the incoming codec cannot be played by all clients.
+	TranscodeAuto TranscodePolicy = "auto"
+
+	// TranscodeAlways always transcodes, regardless of the incoming codec.
+	TranscodeAlways TranscodePolicy = "always"
+
+	// TranscodeNever always copies the incoming codec.
+	TranscodeNever TranscodePolicy = "never"
+)
+
+func ParseTranscodePolicy(v string) (TranscodePolicy, error) {
+	switch policy := TranscodePolicy(v); policy {
+	case TranscodeAuto, TranscodeAlways, TranscodeNever:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown transcode policy %q, must be auto, always, or never", v)
+	}
+}
+
+// codecNeedsTranscode returns true if the video codec, as named by ffmpeg, cannot be decoded
+// through MSE on all clients.
+// HEVC is not supported by Firefox and many older Android devices.
+func codecNeedsTranscode(codec string) bool {
+	switch codec {
+	case "hevc", "h265":
+		return true
+	default:
+		return false
+	}
+}
+
+// shouldTranscode returns whether the next muxer run of the camera should transcode.
+// When the policy is auto, the codec that was last detected is used. If the codec is not yet
+// known, the muxer copies. runMuxer restarts the muxer when the codec of the publisher turns out
+// to need the other mode, e.g. when an H.264 camera publishes after an HEVC camera.
+func (f *FlipCam) shouldTranscode(c *camera) bool {
+	switch f.transcodePolicy {
+	case TranscodeAlways:
+		return true
+	case TranscodeNever:
+		return false
+	default:
+		c.streamInfoMu.RLock()
+		defer c.streamInfoMu.RUnlock()
+		return codecNeedsTranscode(c.videoCodec)
+	}
+}
+
+func (c *camera) setVideoCodec(codec string) {
+	c.streamInfoMu.Lock()
+	defer c.streamInfoMu.Unlock()
+	c.videoCodec = codec
+}
+
+func (c *camera) setTranscoding(transcoding bool) {
+	c.streamInfoMu.Lock()
+	defer c.streamInfoMu.Unlock()
+	c.transcoding = transcoding
+}
@@ -0,0 +1,113 @@
+package flipcamlib
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// VideoTransform describes how the video is transformed by the muxer.
+// Any active transform requires the video to be re-encoded.
+type VideoTransform struct {
+	// Crop, if set, limits the video to a region of interest. It is applied before flipping
+	// and rotating.
+	Crop *CropRegion `json:"crop,omitempty"`
+
+	// FlipHorizontal mirrors the video so that left is left, as in a mirror.
+	FlipHorizontal bool `json:"flipHorizontal"`
+
+	// FlipVertical flips the video upside down.
+	FlipVertical bool `json:"flipVertical"`
+
+	// Rotation is the clockwise rotation in degrees. One of 0, 90, 180, or 270.
+	Rotation int `json:"rotation"`
+}
+
+// CropRegion is a region of the video expressed in fractions of the input width and height.
+// E.g. a Width of 0.5 keeps half of the input width.
+type CropRegion struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+func (t VideoTransform) Validate() error {
+	switch t.Rotation {
+	case 0, 90, 180, 270:
+	default:
+		return fmt.Errorf("rotation must be 0, 90, 180, or 270, got %d", t.Rotation)
+	}
+
+	if t.Crop != nil {
+		c := t.Crop
+		switch {
+		case c.X < 0 || c.Y < 0:
+			return fmt.Errorf("crop x and y must not be negative")
+		case c.Width <= 0 || c.Height <= 0:
+			return fmt.Errorf("crop width and height must be larger than zero")
+		case c.X+c.Width > 1 || c.Y+c.Height > 1:
+			return fmt.Errorf("crop region must lie within the video")
+		}
+	}
+
+	return nil
+}
+
+// IsActive returns true if the transform changes the video.
+func (t VideoTransform) IsActive() bool {
+	return len(t.filters()) > 0
+}
+
+// filters returns the ffmpeg video filters that apply the transform.
+func (t VideoTransform) filters() []string {
+	var filters []string
+
+	if t.Crop != nil && (t.Crop.Width < 1 || t.Crop.Height < 1) {
+		// Dimensions are kept even, yuv420p does not support odd dimensions
+		filters = append(filters, fmt.Sprintf(
+			"crop=w=trunc(iw*%[1]s/2)*2:h=trunc(ih*%[2]s/2)*2:x=iw*%[3]s:y=ih*%[4]s",
+			formatFilterFloat(t.Crop.Width),
+			formatFilterFloat(t.Crop.Height),
+			formatFilterFloat(t.Crop.X),
+			formatFilterFloat(t.Crop.Y),
+		))
+	}
+
+	hflip := t.FlipHorizontal
+	vflip := t.FlipVertical
+	switch t.Rotation {
+	case 90:
+		filters = append(filters, "transpose=clock")
+	case 180:
+		// A rotation of 180 degrees equals flipping both ways
+		hflip = !hflip
+		vflip = !vflip
+	case 270:
+		filters = append(filters, "transpose=cclock")
+	}
+
+	if hflip {
+		filters = append(filters, "hflip")
+	}
+	if vflip {
+		filters = append(filters, "vflip")
+	}
+
+	return filters
+}
+
+func formatFilterFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func (c *camera) getTransform() VideoTransform {
+	c.videoSettingsMu.RLock()
+	defer c.videoSettingsMu.RUnlock()
+	return c.transform
+}
+
+func (c *camera) setTransform(t VideoTransform) {
+	c.videoSettingsMu.Lock()
+	defer c.videoSettingsMu.Unlock()
+	c.transform = t
+}
@@ -0,0 +1,215 @@
+package flipcamlib
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// defaultCaddyRootCert is where the Caddy service copies the root certificate of its local CA
+// on start. Caddy's own storage is only readable by the caddy user.
+const defaultCaddyRootCert = "/run/caddy/root.crt"
+
+// Platform is the kind of device that the trust page gives instructions for.
+type Platform string
+
+const (
+	PlatformAndroid Platform = "android"
+	PlatformDesktop Platform = "desktop"
+	PlatformIos     Platform = "ios"
+)
+
+// detectPlatform guesses the platform from the User-Agent header. iPads that request the desktop
+// site claim to be a Mac, trust.mjs corrects that.
+func detectPlatform(userAgent string) Platform {
+	switch {
+	case strings.Contains(userAgent, "iPhone"),
+		strings.Contains(userAgent, "iPad"),
+		strings.Contains(userAgent, "iPod"):
+		return PlatformIos
+	case strings.Contains(userAgent, "Android"):
+		return PlatformAndroid
+	default:
+		return PlatformDesktop
+	}
+}
+
+// rootCert returns the root certificate that devices must trust. This is the flipcam CA if
+// flipcam serves TLS itself, see Opts.TlsDir, and the local CA of Caddy otherwise.
+func (f *FlipCam) rootCert() (*x509.Certificate, error) {
+	if f.ca != nil {
+		return f.ca.cert, nil
+	}
+
+	certPem, err := os.ReadFile(f.caddyRootCert)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the root certificate of Caddy: %w", err)
+	}
+	block, _ := pem.Decode(certPem)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("%s does not contain a PEM certificate", f.caddyRootCert)
+	}
+
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// fingerprint returns the SHA-256 fingerprint of the certificate in the format shown by most
+// certificate viewers, e.g. 1A:2B:….
+func fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+
+	return strings.Join(parts, ":")
+}
+
+func (f *FlipCam) handleTrustPage(w http.ResponseWriter, r *http.Request) {
+	cert, err := f.rootCert()
+	if err != nil {
+		log.Printf("web: trust page: %v\n", err)
+	}
+	certFingerprint := ""
+	if cert != nil {
+		certFingerprint = fingerprint(cert)
+	}
+
+	err = TrustPage(detectPlatform(r.UserAgent()), certFingerprint).Render(r.Context(), w)
+	if err != nil {
+		log.Printf("web: failed to render: %v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// writeRootCert responds with the root certificate, or an error if it is not available.
+func (f *FlipCam) writeRootCert(
+	w http.ResponseWriter,
+	contentType string,
+	filename string,
+	encode func(cert *x509.Certificate) ([]byte, error),
+) {
+	cert, err := f.rootCert()
+	if err != nil {
+		log.Printf("web: %v\n", err)
+		http.Error(w, "the root certificate is not available", http.StatusNotFound)
+		return
+	}
+	content, err := encode(cert)
+	if err != nil {
+		log.Printf("web: failed to encode the root certificate: %v\n", err)
+		http.Error(w, "failed to encode the root certificate", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write(content)
+}
+
+// handleRootCertPem serves the root certificate in PEM, which desktop systems and browsers
+// import.
+func (f *FlipCam) handleRootCertPem(w http.ResponseWriter, _ *http.Request) {
+	f.writeRootCert(w, "application/x-x509-ca-cert", "flipcam-ca.crt", encodePem)
+}
+
+// handleRootCertDer serves the root certificate in DER, which every Android version installs.
+func (f *FlipCam) handleRootCertDer(w http.ResponseWriter, _ *http.Request) {
+	f.writeRootCert(w, "application/x-x509-ca-cert", "flipcam-ca.cer", encodeDer)
+}
+
+// handleMobileConfig serves a configuration profile that installs the root certificate on iOS.
+func (f *FlipCam) handleMobileConfig(w http.ResponseWriter, _ *http.Request) {
+	f.writeRootCert(w, "application/x-apple-aspen-config", "flipcam.mobileconfig", mobileConfig)
+}
+
+func encodePem(cert *x509.Certificate) ([]byte, error) {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), nil
+}
+
+func encodeDer(cert *x509.Certificate) ([]byte, error) {
+	return cert.Raw, nil
+}
+
+var mobileConfigTmpl = template.Must(template.New("mobileconfig").Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>PayloadContent</key>
+	<array>
+		<dict>
+			<key>PayloadCertificateFileName</key>
+			<string>flipcam-ca.cer</string>
+			<key>PayloadContent</key>
+			<data>{{.Certificate}}</data>
+			<key>PayloadDisplayName</key>
+			<string>{{.Name | html}}</string>
+			<key>PayloadIdentifier</key>
+			<string>flipcam.ca.{{.Id}}</string>
+			<key>PayloadType</key>
+			<string>com.apple.security.root</string>
+			<key>PayloadUUID</key>
+			<string>{{.CertificateUuid}}</string>
+			<key>PayloadVersion</key>
+			<integer>1</integer>
+		</dict>
+	</array>
+	<key>PayloadDescription</key>
+	<string>Trusts the certificate of the FlipCam server so that its video plays over HTTPS.</string>
+	<key>PayloadDisplayName</key>
+	<string>FlipCam</string>
+	<key>PayloadIdentifier</key>
+	<string>flipcam.trust.{{.Id}}</string>
+	<key>PayloadRemovalDisallowed</key>
+	<false/>
+	<key>PayloadType</key>
+	<string>Configuration</string>
+	<key>PayloadUUID</key>
+	<string>{{.ProfileUuid}}</string>
+	<key>PayloadVersion</key>
+	<integer>1</integer>
+</dict>
+</plist>
+`))
+
+// mobileConfig returns an unsigned iOS configuration profile containing the root certificate.
+// The identifiers are derived from the certificate so that installing the profile again replaces
+// it instead of adding a copy.
+func mobileConfig(cert *x509.Certificate) ([]byte, error) {
+	sum := sha256.Sum256(cert.Raw)
+	var b strings.Builder
+	err := mobileConfigTmpl.Execute(&b, struct {
+		Certificate     string
+		CertificateUuid string
+		Id              string
+		Name            string
+		ProfileUuid     string
+	}{
+		Certificate:     base64.StdEncoding.EncodeToString(cert.Raw),
+		CertificateUuid: uuidFromHash(sum[:16]),
+		Id:              hex.EncodeToString(sum[:8]),
+		Name:            cert.Subject.CommonName,
+		ProfileUuid:     uuidFromHash(sum[16:]),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(b.String()), nil
+}
+
+// uuidFromHash formats 16 bytes of a hash as a UUID.
+func uuidFromHash(hash []byte) string {
+	h := strings.ToUpper(hex.EncodeToString(hash[:16]))
+
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
@@ -2,7 +2,11 @@ package flipcamlib
 
 import (
 	"context"
+	"fmt"
+	"os"
 	"os/exec"
+	"path"
+	"time"
 )
 
 func defaultString(value string, defaultVal string) string {
@@ -13,9 +17,44 @@ func defaultString(value string, defaultVal string) string {
 	return value
 }
 
+func defaultDuration(value time.Duration, defaultVal time.Duration) time.Duration {
+	if value == 0 {
+		return defaultVal
+	}
+
+	return value
+}
+
 func sudoCommand(ctx context.Context, cmd []string) *exec.Cmd {
 	args := make([]string, len(cmd)+1)
 	args[0] = "--non-interactive"
 	copy(args[1:], cmd)
 	return exec.CommandContext(ctx, "sudo", args...)
 }
+
+// writeFileAtomic writes the file such that readers never observe a partially written file.
+func writeFileAtomic(filePath string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(path.Dir(filePath), "."+path.Base(filePath)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", tmp.Name(), err)
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close %s: %w", tmp.Name(), err)
+	}
+
+	err = os.Chmod(tmp.Name(), perm)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filePath)
+}
@@ -4,64 +4,56 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"github.com/go-json-experiment/json"
 	"log"
+	"net"
 	"net/http"
-	"os"
 	"time"
 )
 
 func (f *FlipCam) startWebserver(ctx context.Context) {
-	srv := http.Server{Addr: f.uiPort}
-	staticDirs := []string{
-		"./static",
-		"/srv/flipcam/static",
-	}
-	var httpDir http.Dir
-
-	for _, dir := range staticDirs {
-		_, err := os.Stat(dir)
-		if err != nil {
-			continue
+	_, err := newStaticAssets(f.staticDir)
+	if err != nil {
+		if f.listener != nil {
+			_ = f.listener.Close()
 		}
-
-		httpDir = http.Dir(dir)
-		break
-	}
-
-	if httpDir == "" {
-		f.stopWithError(fmt.Errorf("no static files found to serve"))
+		f.stopWithError(fmt.Errorf("[web]: invalid static directory: %w", err))
 		return
 	}
 
-	static := http.FileServer(httpDir)
-	http.Handle("/static/", http.StripPrefix("/static/", static))
-
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		playlistUrlPath := f.getPlayListUrlPath()
-		err := Index(playlistUrlPath).Render(r.Context(), w)
-		if err != nil {
-			log.Printf("web: failed to render: %v\n", err)
-			w.WriteHeader(http.StatusInternalServerError)
-		}
-	})
-
-	http.HandleFunc("/restart-muxer", func(w http.ResponseWriter, r *http.Request) {
-		done := make(chan struct{})
-		f.restartMuxer <- done
-		<-done
-		w.Header().Set("Content-Type", "text/plain")
-		_, err := w.Write([]byte(f.getPlayListUrlPath()))
+	listener := f.listener
+	if listener == nil {
+		listener, err = net.Listen("tcp", f.uiPort)
 		if err != nil {
-			log.Printf("web: failed to write new playlist path: %v\n", err)
+			f.stopWithError(fmt.Errorf("[web]: failed to listen: %w", err))
+			return
 		}
-	})
+	}
 
+	srv := http.Server{
+		Handler:   f.Handler(),
+		TLSConfig: f.tlsConfig,
+	}
 	f.shutdownWg.Add(1)
 	go func() {
 		defer f.shutdownWg.Done()
-		log.Println("Listening on :3000")
+		log.Printf("Listening on %s\n", listener.Addr())
+		if f.coachPinGenerated {
+			log.Printf("Coach PIN: %s\n", f.coachPin)
+		}
+		scheme := "http"
+		if f.tlsConfig != nil {
+			scheme = "https"
+		}
+		log.Printf("Coach pairing link: %s://%s%s\n", scheme, f.RouterIp().Addr(), f.pairingPath())
 		f.startupWg.Done()
-		err := srv.ListenAndServe()
+		var err error
+		if f.tlsConfig != nil {
+			// The certificate is in TLSConfig. ServeTLS also enables HTTP/2.
+			err = srv.ServeTLS(listener, "", "")
+		} else {
+			err = srv.Serve(listener)
+		}
 		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			f.stopWithError(fmt.Errorf("[web]: http listener failed: %w", err))
 		}
@@ -81,3 +73,197 @@ func (f *FlipCam) startWebserver(ctx context.Context) {
 		}
 	}()
 }
+
+// Handler returns the handler of the web UI and API. It can be mounted in another server, see
+// Opts.Listener to only replace the listener. Start must have been called before requests are
+// handled.
+func (f *FlipCam) Handler() http.Handler {
+	f.handlerOnce.Do(func() {
+		f.handler = f.newHandler()
+	})
+
+	return f.handler
+}
+
+func (f *FlipCam) newHandler() http.Handler {
+	assets, err := newStaticAssets(f.staticDir)
+	if err != nil {
+		log.Printf("web: invalid static directory, using the embedded files: %v\n", err)
+		assets, _ = newStaticAssets("")
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/static/", http.StripPrefix("/static/", assets))
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		err := Index(f.cameraStatuses(), f.isCoach(r)).Render(r.Context(), w)
+		if err != nil {
+			log.Printf("web: failed to render: %v\n", err)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+
+	mux.HandleFunc("GET /api/status", f.handleGetStatus)
+
+	mux.HandleFunc("GET /coach", f.handleCoachPage)
+	mux.HandleFunc("GET "+pairPath, f.handlePair)
+	mux.HandleFunc("GET /api/coach", f.handleGetRole)
+	mux.HandleFunc("POST /api/coach", f.handleUnlockCoach)
+	mux.HandleFunc("DELETE /api/coach", f.handleLeaveCoach)
+
+	mux.HandleFunc("GET /api/cameras", f.handleGetCameras)
+	mux.HandleFunc("GET /cameras", f.handleCamerasPage)
+	mux.HandleFunc("GET /api/align", f.handleAlign)
+	mux.HandleFunc("GET "+f.hlsUrlPathPrefix+"/"+delayedPathSegment+"/{file}", f.handleDelayedPlaylist)
+	mux.HandleFunc(
+		"GET "+f.hlsUrlPathPrefix+"/{camera}/"+delayedPathSegment+"/{file}",
+		f.handleDelayedPlaylist,
+	)
+	if f.serveHls {
+		for _, prefix := range f.hlsPrefixes() {
+			mux.HandleFunc("GET "+prefix+"/{file}", f.handleHlsFile)
+		}
+		// Like the Caddy configuration, nothing else below the HLS prefix is public
+		mux.HandleFunc(f.hlsUrlPathPrefix+"/", http.NotFound)
+	}
+	mux.HandleFunc("GET /api/time", f.handleTime)
+	mux.HandleFunc("GET /ca.crt", f.handleRootCertPem)
+	mux.HandleFunc("GET /trust", f.handleTrustPage)
+	mux.HandleFunc("GET /trust/flipcam-ca.cer", f.handleRootCertDer)
+	mux.HandleFunc("GET /trust/flipcam.mobileconfig", f.handleMobileConfig)
+	mux.HandleFunc("GET /api/broadcast-delay", f.handleGetBroadcastDelay)
+	mux.HandleFunc("PUT /api/broadcast-delay", f.coachOnly(f.handlePutBroadcastDelay))
+	mux.HandleFunc("GET /api/latency", f.handleGetLatency)
+	mux.HandleFunc("POST /api/latency/calibrate", f.coachOnly(f.handleCalibrateLatency))
+	mux.HandleFunc("GET /calibrate", f.handleCalibratePage)
+	mux.HandleFunc("GET /api/backup", f.coachOnly(f.handleBackup))
+	mux.HandleFunc("GET /compare", f.handleComparePage)
+	mux.HandleFunc("GET /athletes", f.handleRosterPage)
+	mux.HandleFunc("GET /athletes/{id}", f.handleAthletePage)
+
+	mux.HandleFunc("GET /api/roster", f.handleGetRoster)
+	mux.HandleFunc("POST /api/athletes", f.coachOnly(f.handleSaveAthlete))
+	mux.HandleFunc("PUT /api/athletes/{id}", f.coachOnly(f.handleSaveAthlete))
+	mux.HandleFunc("DELETE /api/athletes/{id}", f.coachOnly(f.handleDeleteAthlete))
+	mux.HandleFunc("POST /api/groups", f.coachOnly(f.handleCreateGroup))
+	mux.HandleFunc("DELETE /api/groups/{id}", f.coachOnly(f.handleDeleteGroup))
+	mux.HandleFunc("POST /api/assignments", f.coachOnly(f.handleCreateAssignment))
+	mux.HandleFunc("DELETE /api/assignments/{id}", f.coachOnly(f.handleDeleteAssignment))
+
+	mux.HandleFunc("GET /api/clips", f.handleGetClips)
+	mux.HandleFunc("POST /api/clips", f.coachOnly(f.handleCreateClip))
+	mux.HandleFunc("DELETE /api/clips/{id}", f.coachOnly(f.handleDeleteClip))
+
+	mux.HandleFunc("POST /api/jobs/clip", f.coachOnly(f.handleCreateClipExport))
+	mux.HandleFunc("POST /api/jobs/compare", f.coachOnly(f.handleCreateCompare))
+	mux.HandleFunc("POST /api/jobs/composite", f.coachOnly(f.handleCreateComposite))
+	mux.HandleFunc("GET /api/jobs/{id}", f.handleGetJob)
+	mux.HandleFunc("GET /api/jobs/{id}/result", f.handleJobResult)
+
+	mux.HandleFunc("DELETE /api/sessions/{id}", f.coachOnly(f.handleDeleteSession))
+	mux.HandleFunc("GET /api/sessions/{id}/annotations", f.handleGetAnnotations)
+	mux.HandleFunc("POST /api/sessions/{id}/annotations", f.coachOnly(f.handleCreateAnnotation))
+	mux.HandleFunc(
+		"DELETE /api/sessions/{id}/annotations/{annotationId}",
+		f.coachOnly(f.handleDeleteAnnotation),
+	)
+	mux.HandleFunc("GET /api/sessions/{id}/comments", f.handleGetComments)
+	mux.HandleFunc("POST /api/sessions/{id}/comments", f.coachOnly(f.handleCreateComment))
+	mux.HandleFunc("DELETE /api/sessions/{id}/comments/{commentId}", f.coachOnly(f.handleDeleteComment))
+	mux.HandleFunc("GET /api/sessions/{id}/comments/{commentId}/audio", f.handleCommentAudio)
+	mux.HandleFunc("GET /api/sessions/{id}/scrub", f.handleScrub)
+	mux.HandleFunc("GET /api/sessions/{id}/snapshot", f.handleSnapshot)
+	mux.HandleFunc("GET /api/sessions/{id}/thumbnails.vtt", f.handleThumbnailsVtt)
+	mux.HandleFunc("GET /api/sessions/{id}/thumbnails/{file}", f.handleSpriteSheet)
+
+	mux.HandleFunc("GET /api/transform", func(w http.ResponseWriter, r *http.Request) {
+		c, err := f.requestCamera(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		writeJson(w, c.getTransform())
+	})
+
+	mux.HandleFunc("PUT /api/transform", f.coachOnly(func(w http.ResponseWriter, r *http.Request) {
+		c, err := f.requestCamera(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		var transform VideoTransform
+		err = json.UnmarshalRead(r.Body, &transform)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid transform: %v", err), http.StatusBadRequest)
+			return
+		}
+		err = transform.Validate()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = f.saveSetting(cameraSettingKey(c, settingTransform), transform)
+		if err != nil {
+			log.Printf("web: failed to save transform: %v\n", err)
+			http.Error(w, "failed to save transform", http.StatusInternalServerError)
+			return
+		}
+		c.setTransform(transform)
+		log.Printf("web: transform of camera %s changed, restarting muxer\n", c.name)
+		f.restartMuxerAndWait(c)
+		f.handleGetStatus(w, r)
+	}))
+
+	mux.HandleFunc("GET /api/overlay", func(w http.ResponseWriter, r *http.Request) {
+		c, err := f.requestCamera(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		writeJson(w, c.getOverlay())
+	})
+
+	mux.HandleFunc("PUT /api/overlay", f.coachOnly(func(w http.ResponseWriter, r *http.Request) {
+		c, err := f.requestCamera(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		var overlay Overlay
+		err = json.UnmarshalRead(r.Body, &overlay)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid overlay: %v", err), http.StatusBadRequest)
+			return
+		}
+		err = overlay.Validate()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = f.saveSetting(cameraSettingKey(c, settingOverlay), overlay)
+		if err != nil {
+			log.Printf("web: failed to save overlay: %v\n", err)
+			http.Error(w, "failed to save overlay", http.StatusInternalServerError)
+			return
+		}
+		c.setOverlay(overlay)
+		log.Printf("web: overlay of camera %s changed, restarting muxer\n", c.name)
+		f.restartMuxerAndWait(c)
+		f.handleGetStatus(w, r)
+	}))
+
+	mux.HandleFunc("/restart-muxer", f.coachOnly(f.handleRestartMuxer))
+
+	return withStaticAssets(mux, assets)
+}
+
+func writeJson(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.MarshalWrite(w, v)
+	if err != nil {
+		log.Printf("web: failed to write JSON: %v\n", err)
+	}
+}
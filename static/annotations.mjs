@@ -0,0 +1,269 @@
+/**
+ * @typedef {Object} AnnotationPoint
+ * @property {number} x Fraction of the video width.
+ * @property {number} y Fraction of the video height.
+ */
+
+/**
+ * @typedef {Object} Annotation
+ * @property {string} id
+ * @property {'angle' | 'circle' | 'freehand' | 'line' | 'text'} kind
+ * @property {number} time Media time in seconds.
+ * @property {number} duration Seconds.
+ * @property {AnnotationPoint[]} points
+ * @property {string} [text]
+ * @property {string} color
+ */
+
+/**
+ * Draws the annotations of a session on a canvas on top of the video while it plays.
+ */
+export class AnnotationLayer {
+	/** @type {Annotation[]} */
+	annotations = []
+	sessionId = null
+
+	/**
+	 * The annotation that is being drawn.
+	 * @type {Annotation | null}
+	 */
+	pending = null
+
+	/**
+	 * @param {HTMLVideoElement} video
+	 * @param {HTMLCanvasElement} canvas Absolutely positioned sibling of the video.
+	 */
+	constructor(video, canvas) {
+		this.video = video
+		this.canvas = canvas
+		const render = () => {
+			this.render()
+			requestAnimationFrame(render)
+		}
+		requestAnimationFrame(render)
+	}
+
+	/**
+	 * @param {string} sessionId
+	 */
+	async load(sessionId) {
+		const response = await window.fetch(`/api/sessions/${sessionId}/annotations`)
+		if (response.status !== 200) {
+			return
+		}
+		this.annotations = await response.json()
+		this.sessionId = sessionId
+	}
+
+	/**
+	 * Returns the annotations that are visible at the current time of the video.
+	 * @returns {Annotation[]}
+	 */
+	visible() {
+		const t = this.video.currentTime
+		return this.annotations.filter(a => a.time <= t && t < a.time + a.duration)
+	}
+
+	/**
+	 * Returns the area of the canvas in which the video frame is shown. The video is letterboxed
+	 * when its aspect ratio differs from the element's.
+	 */
+	contentRect() {
+		const width = this.canvas.width
+		const height = this.canvas.height
+		if (this.video.videoWidth === 0 || this.video.videoHeight === 0) {
+			return {x: 0, y: 0, width, height}
+		}
+
+		const scale = Math.min(width / this.video.videoWidth, height / this.video.videoHeight)
+		const contentWidth = this.video.videoWidth * scale
+		const contentHeight = this.video.videoHeight * scale
+		return {
+			x: (width - contentWidth) / 2,
+			y: (height - contentHeight) / 2,
+			width: contentWidth,
+			height: contentHeight,
+		}
+	}
+
+	/**
+	 * Converts a pointer position to a point in the video.
+	 * @param {PointerEvent} event
+	 * @returns {AnnotationPoint}
+	 */
+	toPoint(event) {
+		const bounds = this.canvas.getBoundingClientRect()
+		const ratio = this.canvas.width / bounds.width
+		const rect = this.contentRect()
+		const clamp = v => Math.min(Math.max(v, 0), 1)
+		return {
+			x: clamp(((event.clientX - bounds.left) * ratio - rect.x) / rect.width),
+			y: clamp(((event.clientY - bounds.top) * ratio - rect.y) / rect.height),
+		}
+	}
+
+	render() {
+		// Follow the video element, its size depends on the video and the viewport
+		const style = this.canvas.style
+		style.left = `${this.video.offsetLeft}px`
+		style.top = `${this.video.offsetTop}px`
+		style.width = `${this.video.offsetWidth}px`
+		style.height = `${this.video.offsetHeight}px`
+
+		const width = Math.round(this.canvas.clientWidth * window.devicePixelRatio)
+		const height = Math.round(this.canvas.clientHeight * window.devicePixelRatio)
+		if (this.canvas.width !== width || this.canvas.height !== height) {
+			this.canvas.width = width
+			this.canvas.height = height
+		}
+
+		const ctx = this.canvas.getContext('2d')
+		ctx.clearRect(0, 0, width, height)
+		const annotations = this.visible()
+		if (this.pending != null) {
+			annotations.push(this.pending)
+		}
+		if (annotations.length === 0) {
+			return
+		}
+
+		const rect = this.contentRect()
+		const toCanvas = p => [rect.x + p.x * rect.width, rect.y + p.y * rect.height]
+		const lineWidth = Math.max(2, rect.height / 180)
+		const fontSize = rect.height * 0.04
+		ctx.lineWidth = lineWidth
+		ctx.lineCap = 'round'
+		ctx.lineJoin = 'round'
+		ctx.font = `${fontSize}px sans-serif`
+		ctx.textBaseline = 'top'
+
+		const drawLabel = (text, [x, y], color) => {
+			const metrics = ctx.measureText(text)
+			ctx.fillStyle = 'rgb(0 0 0 / 50%)'
+			ctx.fillRect(x - 4, y - 4, metrics.width + 8, fontSize + 8)
+			ctx.fillStyle = color
+			ctx.fillText(text, x, y)
+		}
+
+		for (const annotation of annotations) {
+			const points = annotation.points.map(toCanvas)
+			ctx.strokeStyle = annotation.color
+			switch (annotation.kind) {
+				case 'circle': {
+					const [[cx, cy], [ex, ey]] = points
+					ctx.beginPath()
+					ctx.arc(cx, cy, Math.hypot(ex - cx, ey - cy), 0, 2 * Math.PI)
+					ctx.stroke()
+					break
+				}
+				case 'text':
+					drawLabel(annotation.text ?? '', points[0], annotation.color)
+					break
+				default:
+					ctx.beginPath()
+					ctx.moveTo(...points[0])
+					for (const point of points.slice(1)) {
+						ctx.lineTo(...point)
+					}
+					ctx.stroke()
+					if (annotation.kind === 'angle' && points.length === 3) {
+						const [x, y] = points[1]
+						drawLabel(
+							`${Math.round(angleDegrees(points))}°`,
+							[x + lineWidth * 3, y],
+							annotation.color,
+						)
+					}
+			}
+		}
+	}
+
+	/**
+	 * Lets the user draw an annotation on the canvas.
+	 * @param {Annotation['kind']} kind
+	 * @param {string} color
+	 * @param {string} text Text of a text annotation.
+	 * @returns {Promise<AnnotationPoint[]>} The points of the shape.
+	 */
+	draw(kind, color, text) {
+		this.cancelDraw()
+		this.canvas.classList.add('drawing')
+		this.pending = {kind, color, points: [], text, time: 0, duration: 0}
+		const points = this.pending.points
+
+		return new Promise((resolve, reject) => {
+			const controller = new AbortController()
+			const finish = () => {
+				controller.abort()
+				this.canvas.classList.remove('drawing')
+				this.pending = null
+				this.cancelDraw = () => {}
+				resolve(points)
+			}
+			this.cancelDraw = () => {
+				controller.abort()
+				this.canvas.classList.remove('drawing')
+				this.pending = null
+				this.cancelDraw = () => {}
+				reject(new Error('drawing canceled'))
+			}
+
+			let dragging = false
+			this.canvas.addEventListener('pointerdown', event => {
+				event.preventDefault()
+				this.canvas.setPointerCapture(event.pointerId)
+				const point = this.toPoint(event)
+				dragging = true
+				if (points.length === 0) {
+					points.push(point)
+					if (kind !== 'text') {
+						points.push({...point})
+					}
+				}
+			}, {signal: controller.signal})
+
+			this.canvas.addEventListener('pointermove', event => {
+				if (points.length === 0) {
+					return
+				}
+				const point = this.toPoint(event)
+				if (kind === 'freehand') {
+					if (dragging) {
+						const last = points[points.length - 1]
+						if (Math.hypot(point.x - last.x, point.y - last.y) > 0.003) {
+							points.push(point)
+						}
+					}
+				} else if (dragging || (kind === 'angle' && points.length === 3)) {
+					points[points.length - 1] = point
+				}
+			}, {signal: controller.signal})
+
+			this.canvas.addEventListener('pointerup', event => {
+				dragging = false
+				if (kind === 'angle' && points.length === 2) {
+					// The first drag is the first arm, ending at the vertex. Follow the pointer
+					// for the second arm until the next click.
+					points.push({...this.toPoint(event)})
+					return
+				}
+				finish()
+			}, {signal: controller.signal})
+		})
+	}
+
+	/**
+	 * Stops the drawing that is in progress, if any.
+	 */
+	cancelDraw() {
+	}
+}
+
+/**
+ * Returns the angle at the vertex, the second point, in degrees between 0 and 180.
+ * @param {number[][]} points
+ */
+function angleDegrees([[ax, ay], [vx, vy], [bx, by]]) {
+	const degrees = Math.abs(Math.atan2(ay - vy, ax - vx) - Math.atan2(by - vy, bx - vx)) * 180 / Math.PI
+	return degrees > 180 ? 360 - degrees : degrees
+}
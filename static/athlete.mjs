@@ -0,0 +1,84 @@
+import Hls from 'hls'
+import {runJob} from 'helpers'
+import {athleteFromForm, updateRoster} from 'roster'
+
+const athleteElement = document.getElementById('athlete')
+const athleteId = athleteElement.dataset.id
+const hlsPrefix = athleteElement.dataset.hlsPrefix.replace(/\/$/, '')
+const video = document.getElementById('athlete-video')
+const clipJobsElement = document.getElementById('clip-jobs')
+const hls = Hls.isSupported() ? new Hls() : null
+
+/**
+ * The clip that is playing. Times are media times in seconds.
+ */
+let playingClip = null
+
+function getClip(clipElement) {
+	return {
+		sessionId: clipElement.dataset.session,
+		start: Number(clipElement.dataset.start),
+		end: Number(clipElement.dataset.end),
+	}
+}
+
+function playClip(clip) {
+	const url = new URL(`${hlsPrefix}/${clip.sessionId}.m3u8`, document.location.href)
+	const start = () => {
+		video.currentTime = clip.start
+		video.play().catch(console.error)
+	}
+
+	if (playingClip?.sessionId === clip.sessionId) {
+		playingClip = clip
+		start()
+		return
+	}
+
+	playingClip = clip
+	if (hls == null) {
+		video.src = url.toString()
+	} else {
+		hls.loadSource(url.toString())
+		hls.attachMedia(video)
+	}
+	video.addEventListener('loadedmetadata', start, {once: true})
+}
+
+video.addEventListener('timeupdate', () => {
+	if (playingClip != null && video.currentTime >= playingClip.end) {
+		video.pause()
+		video.currentTime = playingClip.start
+	}
+})
+
+for (const clipElement of document.querySelectorAll('#athlete-clips > li')) {
+	clipElement.querySelector('.clip-play').addEventListener('click', () => {
+		playClip(getClip(clipElement))
+	})
+	clipElement.querySelector('.clip-export').addEventListener('click', () => {
+		runJob('/api/jobs/clip', getClip(clipElement), 'Clip', clipJobsElement)
+			.catch(console.error)
+	})
+	clipElement.querySelector('.assignment-delete').addEventListener('click', () => {
+		updateRoster(`/api/assignments/${clipElement.dataset.id}`, 'DELETE')
+			.then(ok => ok && clipElement.remove())
+			.catch(console.error)
+	})
+}
+
+document.getElementById('athlete-form').addEventListener('submit', event => {
+	event.preventDefault()
+	updateRoster(`/api/athletes/${athleteId}`, 'PUT', athleteFromForm(event.target))
+		.then(ok => ok && window.location.reload())
+		.catch(console.error)
+})
+
+document.getElementById('athlete-delete').addEventListener('click', () => {
+	if (!window.confirm('Delete the athlete and their clip list? Recordings are kept.')) {
+		return
+	}
+	updateRoster(`/api/athletes/${athleteId}`, 'DELETE')
+		.then(ok => ok && window.location.assign('/athletes'))
+		.catch(console.error)
+})
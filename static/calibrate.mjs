@@ -0,0 +1,77 @@
+import {serverNow, syncServerTime} from 'helpers'
+
+// Must match the timecode format in pkg/flipcamlib/timecode.go.
+const timecodeBits = 24
+const timecodeTimeBits = 20
+const timecodeStepMs = 10
+const quietZoneUnits = 6
+
+const canvas = document.getElementById('calibrate-timecode')
+const context = canvas.getContext('2d')
+const resultElement = document.getElementById('calibrate-result')
+
+/**
+ * Returns the colors of the units of the timecode for the given server time, true being black.
+ * @param {number} time Unix time in milliseconds
+ * @returns {boolean[]}
+ */
+function timecodeUnits(time) {
+	const value = Math.floor(time / timecodeStepMs) % (2 ** timecodeTimeBits)
+	let checksum = 0
+	for (let v = value; v > 0; v >>>= 4) {
+		checksum += v & 0xf
+	}
+	const bits = value << (timecodeBits - timecodeTimeBits) | (checksum & 0xf)
+
+	const units = [true, true, false, true]
+	for (let i = timecodeBits - 1; i >= 0; i--) {
+		const bit = (bits >>> i & 1) === 1
+		units.push(bit, !bit)
+	}
+	units.push(true, false, true)
+
+	return units
+}
+
+function draw() {
+	const width = canvas.clientWidth * window.devicePixelRatio
+	const height = canvas.clientHeight * window.devicePixelRatio
+	if (canvas.width !== width || canvas.height !== height) {
+		canvas.width = width
+		canvas.height = height
+	}
+
+	const units = timecodeUnits(serverNow())
+	const unitWidth = width / (units.length + 2 * quietZoneUnits)
+	context.fillStyle = 'white'
+	context.fillRect(0, 0, width, height)
+	context.fillStyle = 'black'
+	units.forEach((black, i) => {
+		if (black) {
+			const x = Math.floor((quietZoneUnits + i) * unitWidth)
+			const nextX = Math.floor((quietZoneUnits + i + 1) * unitWidth)
+			context.fillRect(x, 0, nextX - x, height)
+		}
+	})
+
+	window.requestAnimationFrame(draw)
+}
+
+syncServerTime()
+	.catch(console.error)
+	.finally(() => window.requestAnimationFrame(draw))
+
+document.getElementById('calibrate-measure').addEventListener('click', async () => {
+	resultElement.innerText = 'Measuring…'
+	// The camera to calibrate is passed on from the main page
+	const response = await window.fetch(`/api/latency/calibrate${document.location.search}`, {
+		method: 'POST',
+	})
+	if (response.status !== 200) {
+		resultElement.innerText = await response.text()
+		return
+	}
+
+	const {cameraToServerMs} = await response.json()
+	resultElement.innerText = `Camera to server latency: ${cameraToServerMs.toFixed(0)} ms`
+})
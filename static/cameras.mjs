@@ -0,0 +1,192 @@
+import Hls from 'hls'
+import {serverNow, syncServerTime} from 'helpers'
+
+/**
+ * Differences in wall-clock time, in seconds, between a video and the shared clock that are
+ * tolerated before the video is moved.
+ */
+const maxDrift = 0.15
+
+/**
+ * Differences in wall-clock time, in seconds, that are not corrected by moving the video but by
+ * asking the server where the clock is, e.g. because the session of a camera ended.
+ */
+const maxSeekDrift = 10
+
+/**
+ * Segments are one second long and only listed once complete, so the last seconds can't be
+ * played yet.
+ */
+const minDelay = 3
+
+const delayInput = document.getElementById('cameras-delay')
+const speedSelect = document.getElementById('cameras-speed')
+const anglesSelect = document.getElementById('cameras-angles')
+const behind = document.getElementById('cameras-behind')
+
+const slots = [...document.querySelectorAll('#camera-grid .camera-view')].map(figure => {
+	const video = figure.querySelector('video')
+	const hls = new Hls({backBufferLength: 60})
+	hls.attachMedia(video)
+	return {
+		figure,
+		hls,
+		select: figure.querySelector('.camera-select'),
+		video,
+		/** @type {string|null} */
+		playlistPath: null,
+	}
+})
+
+/**
+ * The wall-clock time, as a server Unix time in milliseconds, that all videos show. It advances
+ * at the playback speed from the moment it was set.
+ */
+const clock = {
+	time: 0,
+	setAt: 0,
+	speed: 1,
+}
+
+function clockNow() {
+	return clock.time + (performance.now() - clock.setAt) * clock.speed
+}
+
+function setClock(time, speed) {
+	clock.time = time
+	clock.setAt = performance.now()
+	clock.speed = speed
+}
+
+/**
+ * True while synchronize waits for a seek, so that it doesn't start another.
+ */
+let seeking = false
+
+function visibleSlots() {
+	return slots.filter(slot => !slot.figure.hidden)
+}
+
+/**
+ * Moves every video to the time of the clock. The server maps the time to the session and media
+ * time of each camera using the program date time of their playlists.
+ */
+async function seek() {
+	const time = Math.round(clockNow())
+	const response = await fetch(`/api/align?t=${time}`)
+	if (!response.ok) {
+		console.error(`Failed to align cameras: ${await response.text()}`)
+		return
+	}
+	const alignment = await response.json()
+	const elapsed = (clockNow() - time) / 1000
+
+	for (const slot of visibleSlots()) {
+		const aligned = alignment.cameras.find(c => c.camera === slot.select.value)
+		if (aligned?.playlistPath == null) {
+			continue
+		}
+		const mediaTime = aligned.mediaTime == null ? null : aligned.mediaTime + elapsed
+
+		if (slot.playlistPath !== aligned.playlistPath) {
+			slot.playlistPath = aligned.playlistPath
+			slot.hls.config.startPosition = mediaTime ?? -1
+			slot.hls.loadSource(new URL(aligned.playlistPath, document.location.href).toString())
+		} else {
+			slot.hls.startLoad()
+			if (mediaTime != null) {
+				slot.video.currentTime = mediaTime
+			}
+		}
+		slot.video.defaultPlaybackRate = clock.speed
+		slot.video.playbackRate = clock.speed
+		slot.video.play().catch(console.error)
+	}
+}
+
+/**
+ * Keeps the videos at the time of the clock. Videos drift apart because they buffer and decode
+ * independently.
+ */
+function synchronize() {
+	const time = clockNow()
+	behind.textContent = `${((serverNow() - time) / 1000).toFixed(1)} s behind live`
+
+	let needsSeek = false
+	for (const slot of visibleSlots()) {
+		if (slot.hls.playingDate == null || slot.video.paused) {
+			continue
+		}
+
+		const drift = (time - slot.hls.playingDate.getTime()) / 1000
+		if (Math.abs(drift) > maxSeekDrift || slot.video.ended) {
+			needsSeek = true
+		} else if (Math.abs(drift) > maxDrift) {
+			slot.video.currentTime += drift
+		}
+	}
+
+	if (needsSeek && !seeking) {
+		seeking = true
+		seek()
+			.catch(console.error)
+			.finally(() => seeking = false)
+	}
+}
+
+function applyDelay() {
+	const delay = Math.max(minDelay, Number(delayInput.value))
+	setClock(serverNow() - delay * 1000, clock.speed)
+	seek().catch(console.error)
+}
+
+function showAngles() {
+	const count = anglesSelect == null ? slots.length : Number(anglesSelect.value)
+	slots.forEach((slot, i) => {
+		slot.figure.hidden = i >= count
+		if (slot.figure.hidden) {
+			slot.hls.stopLoad()
+			slot.video.pause()
+		}
+	})
+}
+
+delayInput.addEventListener('change', applyDelay)
+
+speedSelect.addEventListener('change', () => {
+	setClock(clockNow(), Number(speedSelect.value))
+	for (const {video} of slots) {
+		video.defaultPlaybackRate = clock.speed
+		video.playbackRate = clock.speed
+	}
+})
+
+anglesSelect?.addEventListener('change', () => {
+	showAngles()
+	seek().catch(console.error)
+})
+
+for (const slot of slots) {
+	slot.select.addEventListener('change', () => {
+		seek().catch(console.error)
+	})
+}
+
+document.getElementById('cameras-play').addEventListener('click', () => {
+	for (const {video} of visibleSlots()) {
+		video.play().catch(console.error)
+	}
+})
+
+document.getElementById('cameras-live').addEventListener('click', () => {
+	delayInput.value = String(minDelay)
+	applyDelay()
+})
+
+showAngles()
+syncServerTime()
+	.catch(console.error)
+	.finally(() => {
+		applyDelay()
+		setInterval(synchronize, 1000)
+	})
@@ -0,0 +1,29 @@
+import {qrcode} from 'qrcode'
+
+const pairingQr = document.getElementById('coach-pairing-qr')
+if (pairingQr != null) {
+	const qr = qrcode(0, 'M')
+	qr.addData(new URL(pairingQr.dataset.pairingPath, document.location.href).toString())
+	qr.make()
+	pairingQr.innerHTML = qr.createSvgTag({margin: 2})
+}
+
+document.getElementById('coach-unlock')?.addEventListener('submit', async event => {
+	event.preventDefault()
+	const result = document.getElementById('coach-result')
+	const response = await window.fetch('/api/coach', {
+		method: 'POST',
+		body: JSON.stringify({pin: new FormData(event.target).get('pin')}),
+	})
+	if (response.status !== 200) {
+		result.innerText = await response.text()
+		return
+	}
+
+	document.location.reload()
+})
+
+document.getElementById('coach-leave')?.addEventListener('click', async () => {
+	await window.fetch('/api/coach', {method: 'DELETE'})
+	document.location.reload()
+})
@@ -0,0 +1,228 @@
+import {AnnotationLayer} from 'annotations'
+import Hls from 'hls'
+import {runJob} from 'helpers'
+
+const hlsPrefix = document.getElementById('compare').dataset.hlsPrefix.replace(/\/$/, '')
+const compareVideos = document.getElementById('compare-videos')
+const playButton = document.getElementById('compare-play')
+
+/**
+ * The clips that are compared. Times are media times in seconds.
+ */
+const sides = ['a', 'b'].map(side => ({
+	alignButton: document.getElementById(`compare-set-align-${side}`),
+	alignInput: document.getElementById(`compare-align-${side}`),
+	annotations: new AnnotationLayer(
+		document.getElementById(`compare-video-${side}`),
+		document.getElementById(`compare-canvas-${side}`),
+	),
+	clip: null,
+	hls: Hls.isSupported() ? new Hls() : null,
+	select: document.getElementById(`compare-select-${side}`),
+	startTimer: null,
+	video: document.getElementById(`compare-video-${side}`),
+}))
+let playing = false
+
+function loadClip(side) {
+	const option = side.select.selectedOptions[0]
+	if (option == null) {
+		return
+	}
+
+	side.clip = {
+		sessionId: option.dataset.session,
+		start: Number(option.dataset.start),
+		end: Number(option.dataset.end),
+	}
+	side.alignInput.min = String(side.clip.start)
+	side.alignInput.max = String(side.clip.end)
+	side.alignInput.value = side.clip.start.toFixed(2)
+
+	const url = new URL(`${hlsPrefix}/${side.clip.sessionId}.m3u8`, document.location.href)
+	if (side.hls == null) {
+		side.video.src = url.toString()
+	} else {
+		side.hls.loadSource(url.toString())
+		side.hls.attachMedia(side.video)
+	}
+	side.annotations.load(side.clip.sessionId).catch(console.error)
+	side.video.addEventListener('loadedmetadata', () => {
+		side.video.currentTime = side.clip.start
+	}, {once: true})
+}
+
+function getAlign(side) {
+	return Number(side.alignInput.value)
+}
+
+/**
+ * Returns the time between the start of the comparison and the alignment point.
+ */
+function getLead() {
+	return Math.max(...sides.map(side => getAlign(side) - side.clip.start))
+}
+
+function getTail() {
+	return Math.max(...sides.map(side => side.clip.end - getAlign(side)))
+}
+
+/**
+ * Returns the position of the comparison relative to the alignment point.
+ */
+function getSharedTime() {
+	for (const side of sides) {
+		const t = side.video.currentTime
+		if (side.startTimer == null && t > side.clip.start && t < side.clip.end) {
+			return t - getAlign(side)
+		}
+	}
+
+	return -getLead()
+}
+
+function seekShared(t) {
+	for (const side of sides) {
+		const target = getAlign(side) + t
+		side.video.currentTime = Math.min(Math.max(target, side.clip.start), side.clip.end)
+	}
+}
+
+function play() {
+	let t = getSharedTime()
+	if (t >= getTail() - 0.05) {
+		t = -getLead()
+	}
+	seekShared(t)
+
+	for (const side of sides) {
+		const target = getAlign(side) + t
+		if (target < side.clip.start) {
+			// This clip starts later relative to the alignment point, wait for it
+			const delayMs = (side.clip.start - target) / side.video.playbackRate * 1000
+			side.startTimer = setTimeout(() => {
+				side.startTimer = null
+				side.video.play().catch(console.error)
+			}, delayMs)
+		} else if (target < side.clip.end) {
+			side.video.play().catch(console.error)
+		}
+	}
+
+	playing = true
+	playButton.innerText = 'Pause'
+}
+
+function pause() {
+	for (const side of sides) {
+		clearTimeout(side.startTimer)
+		side.startTimer = null
+		side.video.pause()
+	}
+
+	playing = false
+	playButton.innerText = 'Play'
+}
+
+for (const side of sides) {
+	side.select.addEventListener('change', () => {
+		pause()
+		loadClip(side)
+	})
+
+	side.alignButton.addEventListener('click', () => {
+		pause()
+		side.alignInput.value = side.video.currentTime.toFixed(2)
+	})
+
+	side.video.addEventListener('timeupdate', () => {
+		if (side.clip == null || side.video.currentTime < side.clip.end) {
+			return
+		}
+
+		side.video.pause()
+		if (sides.every(s => s.video.paused && s.startTimer == null)) {
+			pause()
+		}
+	})
+}
+
+// Keep the clips in sync, they drift apart when one of them stalls while loading
+setInterval(() => {
+	if (!playing || sides.some(side => side.video.paused)) {
+		return
+	}
+
+	const [a, b] = sides
+	const t = a.video.currentTime - getAlign(a)
+	if (Math.abs(b.video.currentTime - getAlign(b) - t) > 0.1) {
+		b.video.currentTime = getAlign(b) + t
+	}
+}, 500)
+
+playButton.addEventListener('click', () => {
+	if (sides.some(side => side.clip == null)) {
+		return
+	}
+
+	if (playing) {
+		pause()
+	} else {
+		play()
+	}
+})
+
+document.getElementById('compare-to-start').addEventListener('click', () => {
+	pause()
+	seekShared(-getLead())
+})
+
+document.getElementById('compare-to-align').addEventListener('click', () => {
+	pause()
+	seekShared(0)
+})
+
+const speedInput = document.getElementById('compare-speed')
+const speedOutput = document.getElementById('compare-speed-output')
+speedInput.addEventListener('input', () => {
+	const rate = Number(speedInput.value)
+	speedOutput.innerText = `${rate}x`
+	for (const side of sides) {
+		side.video.playbackRate = rate
+	}
+})
+
+const modeSelect = document.getElementById('compare-mode')
+modeSelect.addEventListener('change', () => {
+	compareVideos.className = modeSelect.value
+})
+
+const opacityInput = document.getElementById('compare-opacity')
+opacityInput.addEventListener('input', () => {
+	compareVideos.style.setProperty('--overlay-opacity', opacityInput.value)
+})
+
+document.getElementById('compare-export').addEventListener('click', () => {
+	if (sides.some(side => side.clip == null)) {
+		return
+	}
+
+	const [a, b] = sides.map(side => ({
+		...side.clip,
+		align: getAlign(side),
+	}))
+	runJob('/api/jobs/compare', {
+		a,
+		b,
+		mode: modeSelect.value,
+		opacity: Number(opacityInput.value),
+	}, 'Comparison', document.getElementById('clip-jobs')).catch(console.error)
+})
+
+if (sides[1].select.options.length > 1) {
+	// Compare two different clips by default
+	sides[1].select.selectedIndex = 1
+}
+for (const side of sides) {
+	loadClip(side)
+}
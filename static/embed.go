@@ -0,0 +1,9 @@
+// Package static holds the files of the web UI so that they are part of the flipcam binary.
+package static
+
+import "embed"
+
+// Files are the scripts and stylesheets of the web UI.
+//
+//go:embed *.css *.mjs
+var Files embed.FS
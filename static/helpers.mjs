@@ -49,3 +49,91 @@ export function timeToHuman(
 }
 
 
+
+/**
+ * Queues a clip-processing job and shows its progress in container until the result can be
+ * downloaded.
+ * @param {string} url
+ * @param {object} body
+ * @param {string} label
+ * @param {HTMLElement} container
+ */
+export async function runJob(url, body, label, container) {
+	const response = await window.fetch(url, {
+		method: 'POST',
+		headers: {'Content-Type': 'application/json'},
+		body: JSON.stringify(body),
+	})
+	if (response.status !== 200) {
+		window.alert(await response.text())
+		return
+	}
+	let job = await response.json()
+
+	const jobElement = document.createElement('div')
+	container.prepend(jobElement)
+	while (job.state === 'queued' || job.state === 'running') {
+		jobElement.innerText = `${label}: ${job.state}`
+		await new Promise(resolve => setTimeout(resolve, 1000))
+		const jobResponse = await window.fetch(`/api/jobs/${job.id}`)
+		if (jobResponse.status !== 200) {
+			jobElement.innerText = `${label}: ${await jobResponse.text()}`
+			return
+		}
+		job = await jobResponse.json()
+	}
+
+	if (job.state === 'failed') {
+		jobElement.innerText = `${label}: failed, ${job.error}`
+		return
+	}
+
+	const link = document.createElement('a')
+	link.href = job.resultUrl
+	link.innerText = `Download ${label.toLowerCase()}`
+	jobElement.replaceChildren(link)
+}
+
+/**
+ * Difference between the clock of the server and the clock of this device, see syncServerTime.
+ */
+let clockOffsetMs = 0
+
+/**
+ * Estimates the offset between the clock of this device and the clock of the server using
+ * NTP-style round trips. The sample with the shortest round trip is used as it is the least
+ * affected by network delays.
+ * @param {number} samples
+ * @returns {Promise<{offset: number, delay: number}>} in milliseconds
+ */
+export async function syncServerTime(samples = 8) {
+	let best = null
+	for (let i = 0; i < samples; i++) {
+		const sendTime = Date.now()
+		const response = await window.fetch('/api/time', {cache: 'no-store'})
+		const arrivalTime = Date.now()
+		if (response.status !== 200) {
+			throw new Error(`Failed to get server time: ${await response.text()}`)
+		}
+		const {receiveTime, transmitTime} = await response.json()
+
+		const sample = {
+			offset: ((receiveTime - sendTime) + (transmitTime - arrivalTime)) / 2,
+			delay: (arrivalTime - sendTime) - (transmitTime - receiveTime),
+		}
+		if (best == null || sample.delay < best.delay) {
+			best = sample
+		}
+	}
+
+	clockOffsetMs = best.offset
+	return best
+}
+
+/**
+ * Returns the time of the server as a Unix time in milliseconds.
+ * @returns {number}
+ */
+export function serverNow() {
+	return Date.now() + clockOffsetMs
+}
@@ -1,5 +1,6 @@
 import Hls from 'hls'
-import {Time, timeToHuman} from 'helpers'
+import {AnnotationLayer} from 'annotations'
+import {runJob, serverNow, syncServerTime, Time, timeToHuman} from 'helpers'
 
 const videoContainer = document.getElementById('video-container')
 const video = document.getElementById('video')
@@ -55,6 +56,26 @@ function getPlayListUrl() {
 
 	return new URL(pathOrUrl)
 }
+/**
+ * Returns the ID of the session that is being played. The session ID is the name of the
+ * playlist file.
+ * @returns {string}
+ */
+function getSessionId() {
+	const filename = getPlayListUrl().pathname.split('/').pop()
+	return filename.replace(/\.m3u8$/, '')
+}
+
+const cameraSelect = document.getElementById('camera')
+/**
+ * Returns the query string that selects the camera that is being watched. The select is absent if
+ * there is only one camera.
+ * @returns {string}
+ */
+function cameraQuery() {
+	return cameraSelect == null ? '' : `?camera=${encodeURIComponent(cameraSelect.value)}`
+}
+
 const playButton = document.getElementById('playback-start')
 playButton.addEventListener('click', () => {
 	playButton.style.display = 'none'
@@ -76,15 +97,47 @@ ctsLatencyInput.addEventListener('change', e => {
 	ctsLatencyMs = Number(ctsLatencyInput.value)
 })
 
+/**
+ * Prefills the camera-to-server latency with the estimate of the server, if it has one.
+ */
+async function loadLatencyEstimate() {
+	const response = await window.fetch(`/api/latency${cameraQuery()}`)
+	if (response.status !== 200) {
+		throw new Error(`Failed to get latency: ${await response.text()}`)
+	}
+
+	const {cameraToServerMs} = await response.json()
+	if (cameraToServerMs > 0) {
+		ctsLatencyMs = Math.round(cameraToServerMs)
+		ctsLatencyInput.value = String(ctsLatencyMs)
+	}
+}
+
+loadLatencyEstimate().catch(console.error)
+
 /**
  * Latency from the browser -> media server -> camera.
  */
 let latencyFromAirMs = 0
 let latencyDisplayElement = document.getElementById('latency')
 
+const clockOffsetElement = document.getElementById('clock-offset')
+
+/**
+ * Syncs with the clock of the server as the program date time of the segments is set by the
+ * server. The clock of offline devices drifts, so it is synced periodically.
+ */
+async function syncClock() {
+	const {offset, delay} = await syncServerTime()
+	clockOffsetElement.innerText = `${offset.toFixed(0)} ms (±${(delay / 2).toFixed(0)} ms)`
+}
+
+syncClock().catch(console.error)
+setInterval(() => syncClock().catch(console.error), 5 * 60 * 1000)
+
 hls.on(Hls.Events.FRAG_CHANGED, function (event, data) {
 	if (data.frag.programDateTime !== undefined) {
-		latencyFromAirMs = (Date.now() - data.frag.programDateTime + ctsLatencyMs)
+		latencyFromAirMs = (serverNow() - data.frag.programDateTime + ctsLatencyMs)
 		latencyDisplayElement.innerText = '-' + timeToHuman(
 			latencyFromAirMs,
 			Time.Millis,
@@ -98,6 +151,124 @@ document.getElementById('skip-to-live').addEventListener('click', () => {
 	video.currentTime = video.duration - 0.5
 })
 
+/**
+ * @typedef {Object} ThumbnailCue
+ * @property {number} start Seconds
+ * @property {number} end Seconds
+ * @property {string} url
+ * @property {number[]} xywh
+ */
+
+const timelineSprite = document.getElementById('timeline-sprite')
+/** @type {ThumbnailCue[]} */
+let thumbnailCues = []
+let thumbnailsSessionId = null
+
+/**
+ * Loads the WebVTT index of the sprite sheets of the current session.
+ */
+async function loadThumbnails() {
+	const sessionId = getSessionId()
+	const vttUrl = new URL(`/api/sessions/${sessionId}/thumbnails.vtt`, document.location.href)
+	const response = await window.fetch(vttUrl)
+	if (response.status !== 200) {
+		return
+	}
+
+	const cues = []
+	for (const block of (await response.text()).split('\n\n').slice(1)) {
+		const [timing, target] = block.trim().split('\n')
+		const [start, end] = timing.split(' --> ').map(parseVttTime)
+		const [url, fragment] = target.split('#xywh=')
+		cues.push({
+			start,
+			end,
+			url: new URL(url, vttUrl).toString(),
+			xywh: fragment.split(',').map(Number),
+		})
+	}
+	thumbnailCues = cues
+	thumbnailsSessionId = sessionId
+}
+
+/**
+ * @param {string} time E.g. 00:01:02.500
+ * @returns {number} Seconds
+ */
+function parseVttTime(time) {
+	const [h, m, s] = time.split(':')
+	return Number(h) * 3600 + Number(m) * 60 + Number(s)
+}
+
+/**
+ * Shows the thumbnail of the sprite sheet at the given media time.
+ * @param {number} t Seconds
+ */
+function showTimelineSprite(t) {
+	if (thumbnailsSessionId !== getSessionId()) {
+		thumbnailCues = []
+	}
+	const cue = thumbnailCues.find(c => c.start <= t && t < c.end)
+	if (cue === undefined) {
+		timelineSprite.hidden = true
+		return
+	}
+
+	const [x, y, w, h] = cue.xywh
+	timelineSprite.style.backgroundImage = `url("${cue.url}")`
+	timelineSprite.style.backgroundPosition = `-${x}px -${y}px`
+	timelineSprite.style.width = `${w}px`
+	timelineSprite.style.height = `${h}px`
+	timelineSprite.hidden = false
+}
+
+loadThumbnails().catch(console.error)
+setInterval(() => {
+	if (!scrubbing) {
+		loadThumbnails().catch(console.error)
+	}
+}, 30_000)
+
+// The timeline shows a preview of the keyframe while dragging and only seeks on release so that
+// hls.js does not need to fetch full segments for every step.
+const timelineInput = document.getElementById('timeline-input')
+const scrubPreview = document.getElementById('scrub-preview')
+let scrubbing = false
+let scrubPreviewTimeout = null
+video.addEventListener('timeupdate', () => {
+	if (scrubbing || !Number.isFinite(video.duration)) {
+		return
+	}
+	timelineInput.max = String(video.duration)
+	timelineInput.value = String(video.currentTime)
+})
+timelineInput.addEventListener('input', () => {
+	scrubbing = true
+	showTimelineSprite(Number(timelineInput.value))
+	clearTimeout(scrubPreviewTimeout)
+	scrubPreviewTimeout = setTimeout(() => {
+		const t = Number(timelineInput.value).toFixed(1)
+		scrubPreview.src = `/api/sessions/${getSessionId()}/scrub?t=${t}&width=320`
+		scrubPreview.hidden = false
+		timelineSprite.hidden = true
+	}, 100)
+})
+timelineInput.addEventListener('change', () => {
+	clearTimeout(scrubPreviewTimeout)
+	scrubPreview.hidden = true
+	timelineSprite.hidden = true
+	scrubbing = false
+	video.currentTime = Number(timelineInput.value)
+})
+
+/**
+ * Seeks to the given latency behind the moment the camera recorded the video.
+ * @param {number} latencyS
+ */
+function goToLatency(latencyS) {
+	video.currentTime = video.duration - latencyS + ctsLatencyMs / 1000 + 0.5;
+}
+
 const savedLatencies = document.getElementById('saved-latencies')
 const gotoElement = document.getElementById('goto')
 let latencyModeAdd = true
@@ -126,7 +297,7 @@ document.getElementById('save-latency').addEventListener('click', () => {
 			newButton.remove()
 			return;
 		}
-		video.currentTime = video.duration - desiredLatencyS + ctsLatencyMs / 1000 + 0.5;
+		goToLatency(desiredLatencyS)
 	})
 	savedLatencies.append(newButton)
 })
@@ -184,6 +355,18 @@ setInterval(() => {
 	}
 }, 1000)
 
+document.getElementById('snapshot').addEventListener('click', () => {
+	const playingDate = hls.playingDate
+	if (playingDate == null) {
+		return
+	}
+
+	const url = new URL(`/api/sessions/${getSessionId()}/snapshot`, document.location.href)
+	url.searchParams.set('at', playingDate.toISOString())
+	url.searchParams.set('format', 'png')
+	window.open(url, '_blank')
+})
+
 document.getElementById('fullscreen-toggle').addEventListener('click', () => {
 	if (document.fullscreenElement == null) {
 		document.documentElement.requestFullscreen({
@@ -296,6 +479,19 @@ window.addEventListener('resize', () => {
 	updateControls()
 })
 
+/**
+ * Loads the playlist of a restarted muxer.
+ * @param {string} playlistPath
+ * @param {boolean} isPlaying
+ */
+function loadNewPlaylist(playlistPath, isPlaying) {
+	playListUrl.value = playlistPath
+	hls.loadSource(getPlayListUrl().toString())
+	if (isPlaying) {
+		video.play()
+	}
+}
+
 let restarting = false
 document.getElementById('restart-muxer').addEventListener('click', () => {
 	if (restarting) {
@@ -305,15 +501,11 @@ document.getElementById('restart-muxer').addEventListener('click', () => {
 	const isPlaying = !video.paused && !video.ended;
 	(async () => {
 	    try {
-			const response = await window.fetch('/restart-muxer', {
+			const response = await window.fetch(`/restart-muxer${cameraQuery()}`, {
 				method: 'POST',
 			})
 			if (response.status === 200) {
-				playListUrl.value = await response.text()
-				hls.loadSource(getPlayListUrl().toString())
-				if (isPlaying) {
-					video.play()
-				}
+				loadNewPlaylist(await response.text(), isPlaying)
 			}
 		} catch (error) {
 			console.error('Failed to restart muxer: ', error)
@@ -323,6 +515,696 @@ document.getElementById('restart-muxer').addEventListener('click', () => {
 	})().catch(console.error);
 })
 
+const transformInputs = {
+	flipHorizontal: document.getElementById('transform-flip-horizontal'),
+	flipVertical: document.getElementById('transform-flip-vertical'),
+	rotation: document.getElementById('transform-rotation'),
+	cropX: document.getElementById('transform-crop-x'),
+	cropY: document.getElementById('transform-crop-y'),
+	cropWidth: document.getElementById('transform-crop-width'),
+	cropHeight: document.getElementById('transform-crop-height'),
+}
+
+function showTransform(transform) {
+	transformInputs.flipHorizontal.checked = transform.flipHorizontal
+	transformInputs.flipVertical.checked = transform.flipVertical
+	transformInputs.rotation.value = String(transform.rotation)
+	const crop = transform.crop ?? {x: 0, y: 0, width: 1, height: 1}
+	transformInputs.cropX.value = String(Math.round(crop.x * 100))
+	transformInputs.cropY.value = String(Math.round(crop.y * 100))
+	transformInputs.cropWidth.value = String(Math.round(crop.width * 100))
+	transformInputs.cropHeight.value = String(Math.round(crop.height * 100))
+}
+
+function readTransform() {
+	const transform = {
+		flipHorizontal: transformInputs.flipHorizontal.checked,
+		flipVertical: transformInputs.flipVertical.checked,
+		rotation: Number(transformInputs.rotation.value),
+	}
+	const crop = {
+		x: Number(transformInputs.cropX.value) / 100,
+		y: Number(transformInputs.cropY.value) / 100,
+		width: Number(transformInputs.cropWidth.value) / 100,
+		height: Number(transformInputs.cropHeight.value) / 100,
+	}
+	if (crop.width < 1 || crop.height < 1) {
+		transform.crop = crop
+	}
+	return transform
+}
+
+document.getElementById('transform-apply').addEventListener('click', () => {
+	if (restarting) {
+		return
+	}
+	restarting = true
+	const isPlaying = !video.paused && !video.ended;
+	(async () => {
+		try {
+			const response = await window.fetch(`/api/transform${cameraQuery()}`, {
+				method: 'PUT',
+				headers: {'Content-Type': 'application/json'},
+				body: JSON.stringify(readTransform()),
+			})
+			if (response.status !== 200) {
+				window.alert(await response.text())
+				return
+			}
+			const status = await response.json()
+			showTransform(status.transform)
+			loadNewPlaylist(status.playlistPath, isPlaying)
+		} catch (error) {
+			console.error('Failed to apply transform: ', error)
+		} finally {
+			restarting = false
+		}
+	})().catch(console.error);
+})
+
+const overlayInputs = {
+	enabled: document.getElementById('overlay-enabled'),
+	athleteName: document.getElementById('overlay-athlete-name'),
+	position: document.getElementById('overlay-position'),
+	size: document.getElementById('overlay-size'),
+}
+
+function showOverlay(overlay) {
+	overlayInputs.enabled.checked = overlay.enabled
+	overlayInputs.athleteName.value = overlay.athleteName
+	overlayInputs.position.value = overlay.position
+	overlayInputs.size.value = String(overlay.size * 100)
+}
+
+document.getElementById('overlay-apply').addEventListener('click', () => {
+	if (restarting) {
+		return
+	}
+	restarting = true
+	const isPlaying = !video.paused && !video.ended;
+	(async () => {
+		try {
+			const response = await window.fetch(`/api/overlay${cameraQuery()}`, {
+				method: 'PUT',
+				headers: {'Content-Type': 'application/json'},
+				body: JSON.stringify({
+					enabled: overlayInputs.enabled.checked,
+					athleteName: overlayInputs.athleteName.value,
+					position: overlayInputs.position.value,
+					size: Number(overlayInputs.size.value) / 100,
+				}),
+			})
+			if (response.status !== 200) {
+				window.alert(await response.text())
+				return
+			}
+			const status = await response.json()
+			showOverlay(status.overlay)
+			loadNewPlaylist(status.playlistPath, isPlaying)
+		} catch (error) {
+			console.error('Failed to apply overlay: ', error)
+		} finally {
+			restarting = false
+		}
+	})().catch(console.error);
+})
+
+const broadcastDelayInputs = {
+	enabled: document.getElementById('broadcast-delay-enabled'),
+	seconds: document.getElementById('broadcast-delay-seconds'),
+}
+
+function showBroadcastDelay(delay) {
+	broadcastDelayInputs.enabled.checked = delay.enabled
+	broadcastDelayInputs.seconds.value = String(delay.seconds)
+}
+
+/**
+ * The broadcast delay that the player last followed, as JSON.
+ * @type {string | null}
+ */
+let followedBroadcastDelay = null
+
+/**
+ * Jumps to the broadcast delay set by the coach when it changes. The player can't seek before
+ * the video has loaded, the delay is then followed on a next status update.
+ */
+function followBroadcastDelay(delay) {
+	const key = JSON.stringify(delay)
+	if (key === followedBroadcastDelay) {
+		return
+	}
+	if (delay.enabled) {
+		if (!Number.isFinite(video.duration)) {
+			return
+		}
+		goToLatency(delay.seconds)
+	}
+	followedBroadcastDelay = key
+}
+
+document.getElementById('broadcast-delay-apply').addEventListener('click', () => {
+	(async () => {
+		const response = await window.fetch('/api/broadcast-delay', {
+			method: 'PUT',
+			headers: {'Content-Type': 'application/json'},
+			body: JSON.stringify({
+				enabled: broadcastDelayInputs.enabled.checked,
+				seconds: Number(broadcastDelayInputs.seconds.value),
+			}),
+		})
+		if (response.status !== 200) {
+			window.alert(await response.text())
+			return
+		}
+		const delay = await response.json()
+		showBroadcastDelay(delay)
+		followBroadcastDelay(delay)
+	})().catch(console.error)
+})
+
+document.getElementById('session-delete').addEventListener('click', () => {
+	const sessionId = getSessionId()
+	if (!window.confirm(`Delete session ${sessionId} with its clips, annotations, and comments?`)) {
+		return
+	}
+	(async () => {
+		const response = await window.fetch(`/api/sessions/${sessionId}`, {method: 'DELETE'})
+		if (response.status !== 204) {
+			window.alert(await response.text())
+			return
+		}
+		const statusResponse = await window.fetch(`/api/status${cameraQuery()}`)
+		if (statusResponse.status === 200) {
+			const status = await statusResponse.json()
+			loadNewPlaylist(status.playlistPath, false)
+		}
+	})().catch(console.error)
+})
+
+/**
+ * The part of the session that is used by clip-processing jobs. Times are media times in seconds.
+ */
+const clip = {
+	sessionId: null,
+	start: null,
+	end: null,
+}
+const clipStartElement = document.getElementById('clip-start')
+const clipEndElement = document.getElementById('clip-end')
+
+function markClip(key) {
+	const sessionId = getSessionId()
+	if (sessionId !== clip.sessionId) {
+		// A clip can't span multiple sessions
+		clip.sessionId = sessionId
+		clip.start = null
+		clip.end = null
+	}
+	clip[key] = video.currentTime
+	clipStartElement.innerText = clip.start == null ? '-' : `${clip.start.toFixed(1)} s`
+	clipEndElement.innerText = clip.end == null ? '-' : `${clip.end.toFixed(1)} s`
+}
+
+document.getElementById('clip-mark-start').addEventListener('click', () => {
+	markClip('start')
+})
+document.getElementById('clip-mark-end').addEventListener('click', () => {
+	markClip('end')
+})
+
+function getClip() {
+	if (clip.start == null || clip.end == null || clip.sessionId !== getSessionId()) {
+		window.alert('Mark the start and end of the clip first')
+		return null
+	}
+
+	return {
+		sessionId: clip.sessionId,
+		start: Math.min(clip.start, clip.end),
+		end: Math.max(clip.start, clip.end),
+	}
+}
+
+const clipJobsElement = document.getElementById('clip-jobs')
+
+document.getElementById('clip-save').addEventListener('click', () => {
+	const clipRange = getClip()
+	if (clipRange == null) {
+		return
+	}
+
+	const nameInput = document.getElementById('clip-name');
+	(async () => {
+		const response = await window.fetch('/api/clips', {
+			method: 'POST',
+			headers: {'Content-Type': 'application/json'},
+			body: JSON.stringify({
+				...clipRange,
+				name: nameInput.value,
+			}),
+		})
+		if (response.status !== 200) {
+			window.alert(await response.text())
+			return
+		}
+		const savedClip = await response.json()
+		nameInput.value = ''
+		const savedElement = document.createElement('div')
+		savedElement.innerText = `Saved clip ${savedClip.name}`
+		clipJobsElement.prepend(savedElement)
+	})().catch(console.error);
+})
+
+const clipAthleteSelect = document.getElementById('clip-athlete')
+
+async function loadAthletes() {
+	const response = await window.fetch('/api/roster')
+	if (response.status !== 200) {
+		throw new Error(`Failed to load roster: ${await response.text()}`)
+	}
+	const roster = await response.json()
+	clipAthleteSelect.replaceChildren(...roster.athletes.map(athlete => {
+		const option = document.createElement('option')
+		option.value = athlete.id
+		option.innerText = athlete.name
+		return option
+	}))
+}
+
+loadAthletes().catch(console.error)
+
+document.getElementById('clip-assign').addEventListener('click', () => {
+	const clipRange = getClip()
+	if (clipRange == null) {
+		return
+	}
+	const athleteOption = clipAthleteSelect.selectedOptions[0]
+	if (athleteOption == null) {
+		window.alert('Add an athlete on the athletes page first')
+		return
+	}
+
+	(async () => {
+		const response = await window.fetch('/api/assignments', {
+			method: 'POST',
+			headers: {'Content-Type': 'application/json'},
+			body: JSON.stringify({
+				...clipRange,
+				athleteId: athleteOption.value,
+				note: document.getElementById('clip-name').value,
+			}),
+		})
+		if (response.status !== 200) {
+			window.alert(await response.text())
+			return
+		}
+		const assignedElement = document.createElement('div')
+		assignedElement.innerText = `Assigned clip to ${athleteOption.innerText}`
+		clipJobsElement.prepend(assignedElement)
+	})().catch(console.error);
+})
+
+document.getElementById('composite-create').addEventListener('click', () => {
+	const clipRange = getClip()
+	if (clipRange == null) {
+		return
+	}
+
+	runJob('/api/jobs/composite', {
+		...clipRange,
+		interval: Number(document.getElementById('composite-interval').value),
+	}, 'Composite', clipJobsElement).catch(console.error)
+})
+
+document.getElementById('clip-export').addEventListener('click', () => {
+	const clipRange = getClip()
+	if (clipRange == null) {
+		return
+	}
+
+	runJob('/api/jobs/clip', clipRange, 'Clip', clipJobsElement).catch(console.error)
+})
+
+const annotationLayer = new AnnotationLayer(video, document.getElementById('annotation-canvas'))
+const annotationList = document.getElementById('annotation-list')
+const annotationDrawButton = document.getElementById('annotation-draw')
+let annotationDrawing = false
+
+async function loadAnnotations() {
+	await annotationLayer.load(getSessionId())
+	annotationList.replaceChildren(...annotationLayer.annotations.map(annotation => {
+		const item = document.createElement('li')
+		const seekButton = document.createElement('button')
+		seekButton.innerText = `${annotation.time.toFixed(1)} s ${annotation.kind}`
+		seekButton.addEventListener('click', () => {
+			video.pause()
+			video.currentTime = annotation.time
+		})
+		const deleteButton = document.createElement('button')
+		deleteButton.innerText = '×'
+		deleteButton.setAttribute('aria-label', 'Delete annotation')
+		deleteButton.addEventListener('click', () => {
+			(async () => {
+				const url = `/api/sessions/${annotationLayer.sessionId}/annotations/${annotation.id}`
+				const response = await window.fetch(url, {method: 'DELETE'})
+				if (response.status !== 204) {
+					window.alert(await response.text())
+				}
+				await loadAnnotations()
+			})().catch(console.error);
+		})
+		item.append(seekButton, deleteButton)
+		return item
+	}))
+}
+
+loadAnnotations().catch(console.error)
+hls.on(Hls.Events.MANIFEST_PARSED, () => {
+	loadAnnotations().catch(console.error)
+})
+setInterval(() => {
+	// Annotations can be added by other devices
+	if (!annotationDrawing) {
+		loadAnnotations().catch(console.error)
+	}
+}, 10_000)
+
+annotationDrawButton.addEventListener('click', () => {
+	if (annotationDrawing) {
+		annotationLayer.cancelDraw()
+		return
+	}
+
+	const kind = document.getElementById('annotation-kind').value
+	const color = document.getElementById('annotation-color').value
+	const text = document.getElementById('annotation-text').value
+	if (kind === 'text' && text.trim() === '') {
+		window.alert('Enter the text first')
+		return
+	}
+
+	video.pause()
+	annotationDrawing = true
+	annotationDrawButton.innerText = 'Cancel';
+	(async () => {
+		let points
+		try {
+			points = await annotationLayer.draw(kind, color, text)
+		} catch {
+			// Canceled
+			return
+		} finally {
+			annotationDrawing = false
+			annotationDrawButton.innerText = 'Draw'
+		}
+
+		const response = await window.fetch(`/api/sessions/${getSessionId()}/annotations`, {
+			method: 'POST',
+			headers: {'Content-Type': 'application/json'},
+			body: JSON.stringify({
+				kind,
+				color,
+				text: kind === 'text' ? text : '',
+				points,
+				time: video.currentTime,
+				duration: Number(document.getElementById('annotation-duration').value),
+			}),
+		})
+		if (response.status !== 200) {
+			window.alert(await response.text())
+			return
+		}
+		await loadAnnotations()
+	})().catch(console.error);
+})
+
+/**
+ * @typedef {Object} Comment
+ * @property {string} id
+ * @property {number} time Media time in seconds.
+ * @property {string} [text]
+ * @property {string} [audioUrl]
+ */
+
+/** @type {Comment[]} */
+let comments = []
+let commentsSessionId = null
+const commentList = document.getElementById('comment-list')
+const commentDisplay = document.getElementById('comment-display')
+const commentAutoplay = document.getElementById('comment-autoplay')
+const timelineMarkers = document.getElementById('timeline-markers')
+
+async function loadComments() {
+	const sessionId = getSessionId()
+	const response = await window.fetch(`/api/sessions/${sessionId}/comments`)
+	if (response.status !== 200) {
+		return
+	}
+	comments = await response.json()
+	commentsSessionId = sessionId
+	showComments()
+}
+
+function showComments() {
+	commentList.replaceChildren(...comments.map(comment => {
+		const item = document.createElement('li')
+		const seekButton = document.createElement('button')
+		seekButton.innerText = `${comment.time.toFixed(1)} s`
+		seekButton.addEventListener('click', () => {
+			video.currentTime = comment.time
+		})
+		item.append(seekButton)
+
+		if (comment.audioUrl) {
+			const playButton = document.createElement('button')
+			playButton.innerText = '▶'
+			playButton.setAttribute('aria-label', 'Play comment')
+			playButton.addEventListener('click', () => {
+				playComment(comment)
+			})
+			item.append(playButton)
+		}
+		if (comment.text) {
+			item.append(` ${comment.text} `)
+		}
+
+		const deleteButton = document.createElement('button')
+		deleteButton.innerText = '×'
+		deleteButton.setAttribute('aria-label', 'Delete comment')
+		deleteButton.addEventListener('click', () => {
+			(async () => {
+				const url = `/api/sessions/${commentsSessionId}/comments/${comment.id}`
+				const response = await window.fetch(url, {method: 'DELETE'})
+				if (response.status !== 204) {
+					window.alert(await response.text())
+				}
+				await loadComments()
+			})().catch(console.error);
+		})
+		item.append(deleteButton)
+		return item
+	}))
+	showCommentMarkers()
+}
+
+function showCommentMarkers() {
+	if (!Number.isFinite(video.duration) || video.duration === 0) {
+		timelineMarkers.replaceChildren()
+		return
+	}
+
+	timelineMarkers.replaceChildren(...comments.map(comment => {
+		const marker = document.createElement('button')
+		marker.style.left = `${comment.time / video.duration * 100}%`
+		marker.title = comment.text || 'Voice comment'
+		marker.setAttribute('aria-label', `Comment at ${comment.time.toFixed(1)} s`)
+		marker.addEventListener('click', () => {
+			video.currentTime = comment.time
+		})
+		return marker
+	}))
+}
+
+/** @type {HTMLAudioElement | null} */
+let commentAudio = null
+let commentDisplayTimeout = null
+
+/**
+ * @param {Comment} comment
+ */
+function playComment(comment) {
+	commentAudio?.pause()
+	commentAudio = null
+	clearTimeout(commentDisplayTimeout)
+
+	commentDisplay.innerText = comment.text || 'Voice comment'
+	commentDisplay.hidden = false
+	const hide = () => {
+		commentDisplay.hidden = true
+	}
+	if (comment.audioUrl) {
+		commentAudio = new Audio(comment.audioUrl)
+		commentAudio.addEventListener('ended', hide)
+		commentAudio.play().catch(console.error)
+	} else {
+		commentDisplayTimeout = setTimeout(hide, 5000)
+	}
+}
+
+let lastCommentCheckTime = 0
+video.addEventListener('timeupdate', () => {
+	const t = video.currentTime
+	// Only play comments that are passed during playback, not when seeking over them
+	if (!video.paused && commentAutoplay.checked && t > lastCommentCheckTime && t - lastCommentCheckTime < 1.5) {
+		for (const comment of comments) {
+			if (lastCommentCheckTime < comment.time && comment.time <= t) {
+				playComment(comment)
+			}
+		}
+	}
+	lastCommentCheckTime = t
+})
+video.addEventListener('durationchange', showCommentMarkers)
+
+loadComments().catch(console.error)
+hls.on(Hls.Events.MANIFEST_PARSED, () => {
+	loadComments().catch(console.error)
+})
+setInterval(() => {
+	loadComments().catch(console.error)
+}, 10_000)
+
+/**
+ * @param {number} time
+ * @param {string} text
+ * @param {Blob | null} audio
+ */
+async function addComment(time, text, audio) {
+	const form = new FormData()
+	form.set('time', String(time))
+	form.set('text', text)
+	if (audio != null) {
+		form.set('audio', audio, 'comment')
+	}
+
+	const response = await window.fetch(`/api/sessions/${getSessionId()}/comments`, {
+		method: 'POST',
+		body: form,
+	})
+	if (response.status !== 200) {
+		window.alert(await response.text())
+		return
+	}
+	await loadComments()
+}
+
+const commentTextInput = document.getElementById('comment-text')
+document.getElementById('comment-add').addEventListener('click', () => {
+	const text = commentTextInput.value.trim()
+	if (text === '') {
+		return
+	}
+
+	addComment(video.currentTime, text, null)
+		.then(() => {
+			commentTextInput.value = ''
+		})
+		.catch(console.error)
+})
+
+const commentRecordButton = document.getElementById('comment-record')
+/** @type {MediaRecorder | null} */
+let commentRecorder = null
+commentRecordButton.addEventListener('click', () => {
+	if (commentRecorder != null) {
+		commentRecorder.stop()
+		return
+	}
+
+	if (navigator.mediaDevices?.getUserMedia == null) {
+		window.alert('Recording is only possible when the page is loaded over HTTPS')
+		return
+	}
+
+	// The comment applies to the moment the coach started talking about
+	const time = video.currentTime;
+	(async () => {
+		const stream = await navigator.mediaDevices.getUserMedia({audio: true})
+		const recorder = new MediaRecorder(stream)
+		const chunks = []
+		recorder.addEventListener('dataavailable', event => {
+			chunks.push(event.data)
+		})
+		recorder.addEventListener('stop', () => {
+			for (const track of stream.getTracks()) {
+				track.stop()
+			}
+			commentRecorder = null
+			commentRecordButton.innerText = 'Record voice comment'
+			const audio = new Blob(chunks, {type: recorder.mimeType})
+			addComment(time, commentTextInput.value.trim(), audio)
+				.then(() => {
+					commentTextInput.value = ''
+				})
+				.catch(console.error)
+		})
+		recorder.start()
+		commentRecorder = recorder
+		commentRecordButton.innerText = 'Stop recording'
+	})().catch(error => {
+		console.error('Failed to record comment: ', error)
+		window.alert(`Failed to record: ${error.message}`)
+	})
+})
+
+const videoCodecElement = document.getElementById('video-codec')
+let settingsShown = false
+async function updateStatus() {
+	const response = await window.fetch(`/api/status${cameraQuery()}`)
+	if (response.status !== 200) {
+		return
+	}
+	const status = await response.json()
+	if (!settingsShown) {
+		// Only show once so that edits in progress are not overwritten
+		settingsShown = true
+		showTransform(status.transform)
+		showOverlay(status.overlay)
+		showBroadcastDelay(status.broadcastDelay)
+	}
+	followBroadcastDelay(status.broadcastDelay)
+	if (status.videoCodec === '') {
+		videoCodecElement.innerText = '?'
+	} else if (status.transcoding) {
+		videoCodecElement.innerText = `${status.videoCodec} → h264 (transcoding)`
+	} else {
+		videoCodecElement.innerText = status.videoCodec
+	}
+}
+updateStatus().catch(console.error)
+setInterval(() => {
+	updateStatus().catch(console.error)
+}, 5000)
+
+cameraSelect?.addEventListener('change', () => {
+	const isPlaying = !video.paused && !video.ended;
+	document.getElementById('calibrate-link').href = `/calibrate${cameraQuery()}`;
+	(async () => {
+		const response = await window.fetch(`/api/status${cameraQuery()}`)
+		if (response.status !== 200) {
+			window.alert(await response.text())
+			return
+		}
+		const status = await response.json()
+		loadNewPlaylist(status.playlistPath, isPlaying)
+		// The transform and overlay are set per camera
+		settingsShown = false
+		await updateStatus()
+		await loadLatencyEstimate()
+	})().catch(console.error)
+})
+
 /** @var {WakeLockSentinel | null} */
 let wakeLockSentinel = null;
 function updateWakePrevention() {
@@ -0,0 +1,68 @@
+/**
+ * Returns the athlete that is described by the athlete form.
+ * @param {HTMLFormElement} form
+ */
+export function athleteFromForm(form) {
+	const data = new FormData(form)
+	return {
+		name: data.get('name'),
+		groupIds: data.getAll('groupIds'),
+	}
+}
+
+/**
+ * Sends a request to the roster API and reloads the page once it succeeded.
+ * @param {string} url
+ * @param {string} method
+ * @param {object} [body]
+ */
+export async function updateRoster(url, method, body) {
+	const response = await window.fetch(url, {
+		method,
+		headers: {'Content-Type': 'application/json'},
+		body: body == null ? undefined : JSON.stringify(body),
+	})
+	if (response.status !== 200 && response.status !== 204) {
+		window.alert(await response.text())
+		return false
+	}
+
+	return true
+}
+
+const rosterElement = document.getElementById('roster')
+if (rosterElement != null) {
+	document.getElementById('athlete-form').addEventListener('submit', event => {
+		event.preventDefault()
+		updateRoster('/api/athletes', 'POST', athleteFromForm(event.target))
+			.then(ok => ok && window.location.reload())
+			.catch(console.error)
+	})
+
+	document.getElementById('group-form').addEventListener('submit', event => {
+		event.preventDefault()
+		const data = new FormData(event.target)
+		updateRoster('/api/groups', 'POST', {name: data.get('name')})
+			.then(ok => ok && window.location.reload())
+			.catch(console.error)
+	})
+
+	for (const button of rosterElement.querySelectorAll('.athlete-delete')) {
+		button.addEventListener('click', () => {
+			if (!window.confirm('Delete the athlete and their clip list? Recordings are kept.')) {
+				return
+			}
+			updateRoster(`/api/athletes/${button.dataset.id}`, 'DELETE')
+				.then(ok => ok && window.location.reload())
+				.catch(console.error)
+		})
+	}
+
+	for (const button of rosterElement.querySelectorAll('.group-delete')) {
+		button.addEventListener('click', () => {
+			updateRoster(`/api/groups/${button.dataset.id}`, 'DELETE')
+				.then(ok => ok && window.location.reload())
+				.catch(console.error)
+		})
+	}
+}
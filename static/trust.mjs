@@ -0,0 +1,17 @@
+const sections = document.querySelectorAll('.trust-platform')
+
+function showPlatform(platform) {
+	for (const section of sections) {
+		section.hidden = section.dataset.platform !== platform
+	}
+}
+
+// iPads request the desktop site by default and then claim to be a Mac, only the touch screen
+// gives them away.
+if (navigator.userAgent.includes('Macintosh') && navigator.maxTouchPoints > 1) {
+	showPlatform('ios')
+}
+
+for (const button of document.querySelectorAll('#trust-platforms button')) {
+	button.addEventListener('click', () => showPlatform(button.dataset.platform))
+}